@@ -2,11 +2,17 @@ package action_runner
 
 import (
 	"errors"
+	"sync"
 )
 
 type ActionRunner struct {
 	actions []Action
 	cancel  chan chan bool
+	done    chan bool
+
+	currentAction    int
+	performedActions int
+	currentLock      *sync.Mutex
 }
 
 var CancelledError = errors.New("actions cancelled")
@@ -16,6 +22,10 @@ func New(actions []Action) *ActionRunner {
 		actions: actions,
 
 		cancel: make(chan chan bool),
+		done:   make(chan bool),
+
+		currentAction: -1,
+		currentLock:   &sync.Mutex{},
 	}
 }
 
@@ -27,7 +37,9 @@ func (runner *ActionRunner) Perform(result chan<- error) {
 	var cancelled chan bool
 
 actions:
-	for _, action := range runner.actions {
+	for i, action := range runner.actions {
+		runner.setCurrentAction(i)
+
 		subactionResult := make(chan error, 1)
 		go action.Perform(subactionResult)
 
@@ -38,10 +50,19 @@ actions:
 				break actions
 			} else {
 				cleanups = append(cleanups, action.Cleanup)
+				runner.setPerformedActions(len(cleanups))
 			}
 
 		case cancelled = <-runner.cancel:
 			action.Cancel()
+
+			// the action may still have done its work (e.g. created a
+			// container) before noticing; if so, it needs cleaning up too
+			if <-subactionResult == nil {
+				cleanups = append(cleanups, action.Cleanup)
+				runner.setPerformedActions(len(cleanups))
+			}
+
 			performResult = CancelledError
 			break actions
 		}
@@ -51,6 +72,10 @@ actions:
 		cleanups[i]()
 	}
 
+	runner.setCurrentAction(-1)
+
+	close(runner.done)
+
 	if cancelled != nil {
 		cancelled <- true
 	}
@@ -58,8 +83,49 @@ actions:
 	result <- performResult
 }
 
+// Cancel interrupts the action currently being performed and returns a channel
+// that receives once that action has finished and the completed actions have
+// been cleaned up. Cancelling a runner that has already finished does nothing.
 func (runner *ActionRunner) Cancel() <-chan bool {
-	cancelled := make(chan bool)
-	runner.cancel <- cancelled
+	cancelled := make(chan bool, 1)
+
+	select {
+	case runner.cancel <- cancelled:
+	case <-runner.done:
+		cancelled <- true
+	}
+
 	return cancelled
 }
+
+// CurrentAction returns the index of the action being performed (or cleaned
+// up after), or -1 if the runner has not started or has finished.
+func (runner *ActionRunner) CurrentAction() int {
+	runner.currentLock.Lock()
+	defer runner.currentLock.Unlock()
+
+	return runner.currentAction
+}
+
+// PerformedActions returns how many of the actions, from the first, were
+// performed successfully.
+func (runner *ActionRunner) PerformedActions() int {
+	runner.currentLock.Lock()
+	defer runner.currentLock.Unlock()
+
+	return runner.performedActions
+}
+
+func (runner *ActionRunner) setCurrentAction(index int) {
+	runner.currentLock.Lock()
+	defer runner.currentLock.Unlock()
+
+	runner.currentAction = index
+}
+
+func (runner *ActionRunner) setPerformedActions(count int) {
+	runner.currentLock.Lock()
+	defer runner.currentLock.Unlock()
+
+	runner.performedActions = count
+}
@@ -188,7 +188,7 @@ var _ = Describe("ActionRunner", func() {
 						<-interrupt
 						interrupted <- true
 
-						result <- nil
+						result <- errors.New("interrupted")
 					},
 					cancel: func() {
 						interrupt <- true
@@ -234,4 +234,142 @@ var _ = Describe("ActionRunner", func() {
 			Consistently(cleanup).ShouldNot(Receive())
 		})
 	})
+
+	Context("when the cancelled action finishes its work anyway", func() {
+		It("waits for it and cleans it up along with the completed actions", func(done Done) {
+			defer close(done)
+
+			cleanup := make(chan int, 2)
+			interrupt := make(chan bool)
+			finish := make(chan bool)
+
+			runner := New([]Action{
+				FakeAction{
+					cleanup: func() {
+						cleanup <- 1
+					},
+				},
+				FakeAction{
+					perform: func(result chan<- error) {
+						<-finish
+						result <- nil
+					},
+					cancel: func() {
+						interrupt <- true
+					},
+					cleanup: func() {
+						cleanup <- 2
+					},
+				},
+			})
+
+			result := make(chan error)
+			go runner.Perform(result)
+
+			Eventually(runner.CurrentAction).Should(Equal(1))
+
+			cancelled := runner.Cancel()
+
+			<-interrupt
+
+			Consistently(cancelled).ShouldNot(Receive())
+
+			finish <- true
+
+			<-cancelled
+
+			Ω(<-cleanup).Should(Equal(2))
+			Ω(<-cleanup).Should(Equal(1))
+
+			Ω(<-result).Should(Equal(CancelledError))
+			Ω(runner.PerformedActions()).Should(Equal(2))
+		})
+	})
+
+	Describe("CurrentAction", func() {
+		It("reports the index of the action being performed", func(done Done) {
+			defer close(done)
+
+			proceed := make(chan bool)
+
+			runner := New([]Action{
+				FakeAction{},
+				FakeAction{
+					perform: func(result chan<- error) {
+						<-proceed
+						result <- nil
+					},
+				},
+			})
+
+			Ω(runner.CurrentAction()).Should(Equal(-1))
+
+			result := make(chan error)
+			go runner.Perform(result)
+
+			Eventually(runner.CurrentAction).Should(Equal(1))
+
+			proceed <- true
+
+			Ω(<-result).Should(BeNil())
+			Ω(runner.CurrentAction()).Should(Equal(-1))
+		})
+	})
+
+	Describe("PerformedActions", func() {
+		It("counts the actions that were performed before it was cancelled", func(done Done) {
+			defer close(done)
+
+			interrupt := make(chan bool)
+
+			runner := New([]Action{
+				FakeAction{},
+				FakeAction{
+					perform: func(result chan<- error) {
+						<-interrupt
+						result <- errors.New("interrupted")
+					},
+					cancel: func() {
+						close(interrupt)
+					},
+				},
+			})
+
+			Ω(runner.PerformedActions()).Should(Equal(0))
+
+			result := make(chan error)
+			go runner.Perform(result)
+
+			Eventually(runner.CurrentAction).Should(Equal(1))
+
+			runner.Cancel()
+
+			Ω(<-result).Should(Equal(CancelledError))
+			Ω(runner.PerformedActions()).Should(Equal(1))
+		})
+	})
+
+	Context("when the runner is canceled after it has finished", func() {
+		It("returns immediately without cancelling anything", func(done Done) {
+			defer close(done)
+
+			cancelled := make(chan bool, 1)
+
+			runner := New([]Action{
+				FakeAction{
+					cancel: func() {
+						cancelled <- true
+					},
+				},
+			})
+
+			result := make(chan error)
+			go runner.Perform(result)
+
+			Ω(<-result).Should(BeNil())
+
+			Ω(<-runner.Cancel()).Should(BeTrue())
+			Consistently(cancelled).ShouldNot(Receive())
+		})
+	})
 })
@@ -1,10 +1,14 @@
 package actionrunner
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"fmt"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon"
 
+	"github.com/cloudfoundry-incubator/executor/action_runner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/downloader"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/logstreamer"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/uploader"
@@ -15,57 +19,130 @@ import (
 )
 
 type ActionRunnerInterface interface {
-	Run(containerHandle string, streamer logstreamer.LogStreamer, actions []models.ExecutorAction) (result string, err error)
+	Run(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, tracker ProcessTracker, cancel <-chan struct{}) (result string, err error)
+	Resume(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, actionIndex int, processID uint32, tracker ProcessTracker, cancel <-chan struct{}) (result string, err error)
+}
+
+// ProcessTracker is told, by the index of the action in the list being run,
+// which action is starting and which warden process each RunAction starts.
+type ProcessTracker interface {
+	ActionStarted(actionIndex int)
+	ProcessStarted(actionIndex int, processID uint32)
+}
+
+type ErrorCannotResumeAction struct {
+	ActionIndex int
+}
+
+func (e ErrorCannotResumeAction) Error() string {
+	return fmt.Sprintf("cannot resume action %d: it is not a run action", e.ActionIndex)
 }
 
 type ActionRunner struct {
-	wardenClient  gordon.Client
-	backendPlugin backend_plugin.BackendPlugin
-	downloader    downloader.Downloader
-	uploader      uploader.Uploader
-	tempDir       string
-	logger        *steno.Logger
+	wardenClient    gordon.Client
+	backendPlugin   backend_plugin.BackendPlugin
+	downloader      downloader.Downloader
+	uploader        uploader.Uploader
+	tempDir         string
+	killGracePeriod time.Duration
+	logger          *steno.Logger
 }
 
+// New returns an ActionRunner.  killGracePeriod is how long the process of a
+// RunAction that times out has to exit after being asked to stop, before it
+// is killed.
 func New(
 	wardenClient gordon.Client,
 	backendPlugin backend_plugin.BackendPlugin,
 	downloader downloader.Downloader,
 	uploader uploader.Uploader,
 	tempDir string,
+	killGracePeriod time.Duration,
 	logger *steno.Logger,
 ) *ActionRunner {
 	return &ActionRunner{
-		wardenClient:  wardenClient,
-		backendPlugin: backendPlugin,
-		downloader:    downloader,
-		uploader:      uploader,
-		tempDir:       tempDir,
-		logger:        logger,
+		wardenClient:    wardenClient,
+		backendPlugin:   backendPlugin,
+		downloader:      downloader,
+		uploader:        uploader,
+		tempDir:         tempDir,
+		killGracePeriod: killGracePeriod,
+		logger:          logger,
+	}
+}
+
+// Run performs the actions in order.  RunActions are told about the
+// container's port mappings.  Closing cancel interrupts the action in progress
+// and makes Run return action_runner.CancelledError once it has stopped.
+func (runner *ActionRunner) Run(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, tracker ProcessTracker, cancel <-chan struct{}) (string, error) {
+	return runner.run(containerHandle, portMappings, streamer, actions, 0, nil, tracker, cancel)
+}
+
+// Resume picks a list of actions back up at actionIndex, which must be a run
+// action whose process (processID) is still running in the container.  The
+// actions before it are not performed again.
+func (runner *ActionRunner) Resume(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, actionIndex int, processID uint32, tracker ProcessTracker, cancel <-chan struct{}) (string, error) {
+	if actionIndex >= len(actions) {
+		return "", ErrorCannotResumeAction{ActionIndex: actionIndex}
+	}
+
+	_, isRunAction := actions[actionIndex].Action.(models.RunAction)
+	if !isRunAction {
+		return "", ErrorCannotResumeAction{ActionIndex: actionIndex}
 	}
+
+	return runner.run(containerHandle, portMappings, streamer, actions, actionIndex, &processID, tracker, cancel)
 }
 
-func (runner *ActionRunner) Run(containerHandle string, streamer logstreamer.LogStreamer, actions []models.ExecutorAction) (string, error) {
+func (runner *ActionRunner) run(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, startIndex int, attachTo *uint32, tracker ProcessTracker, cancel <-chan struct{}) (string, error) {
 	result := ""
-	for _, action := range actions {
-		var err error
+	for index, action := range actions {
+		if index < startIndex {
+			continue
+		}
+
+		if isCancelled(cancel) {
+			return "", action_runner.CancelledError
+		}
+
+		if tracker != nil {
+			tracker.ActionStarted(index)
+		}
+
+		var step action_runner.Action
 		switch a := action.Action.(type) {
 		case models.RunAction:
-			runAction := run_action.New(
-				a,
-				containerHandle,
-				streamer,
-				runner.backendPlugin,
-				runner.wardenClient,
-				runner.logger,
-			)
-
-			results := make(chan error, 1)
-			runAction.Perform(results)
+			if index == startIndex && attachTo != nil {
+				step = run_action.NewAttached(
+					a,
+					containerHandle,
+					*attachTo,
+					streamer,
+					runner.backendPlugin,
+					runner.wardenClient,
+					runner.killGracePeriod,
+					runner.logger,
+				)
+			} else {
+				var processTracker run_action.ProcessTracker
+				if tracker != nil {
+					processTracker = actionProcessTracker{index, tracker}
+				}
 
-			err = <-results
+				step = run_action.New(
+					a,
+					containerHandle,
+					portMappings,
+					streamer,
+					runner.backendPlugin,
+					runner.wardenClient,
+					processTracker,
+					runner.killGracePeriod,
+					runner.logger,
+				)
+			}
 		case models.DownloadAction:
-			runAction := download_action.New(
+			step = download_action.New(
 				a,
 				containerHandle,
 				runner.downloader,
@@ -74,13 +151,8 @@ func (runner *ActionRunner) Run(containerHandle string, streamer logstreamer.Log
 				runner.wardenClient,
 				runner.logger,
 			)
-
-			results := make(chan error, 1)
-			runAction.Perform(results)
-
-			err = <-results
 		case models.UploadAction:
-			runAction := upload_action.New(
+			step = upload_action.New(
 				a,
 				containerHandle,
 				runner.uploader,
@@ -88,23 +160,57 @@ func (runner *ActionRunner) Run(containerHandle string, streamer logstreamer.Log
 				runner.wardenClient,
 				runner.logger,
 			)
-
-			results := make(chan error, 1)
-			runAction.Perform(results)
-
-			err = <-results
 		case models.FetchResultAction:
 			runner.logger.Infod(map[string]interface{}{"handle": containerHandle}, "runonce.handle.fetch-result-action")
+
+			var err error
 			result, err = runner.performFetchResultAction(containerHandle, a)
+			if err != nil {
+				return "", err
+			}
+
+			continue
+		default:
+			continue
 		}
-		if err != nil {
-			return "", err
+
+		results := make(chan error, 1)
+		go step.Perform(results)
+
+		select {
+		case err := <-results:
+			if err != nil {
+				return "", err
+			}
+
+		case <-cancel:
+			step.Cancel()
+			<-results
+			return "", action_runner.CancelledError
 		}
 	}
 
 	return result, nil
 }
 
+func isCancelled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+type actionProcessTracker struct {
+	actionIndex int
+	tracker     ProcessTracker
+}
+
+func (t actionProcessTracker) ProcessStarted(processID uint32) {
+	t.tracker.ProcessStarted(t.actionIndex, processID)
+}
+
 func (runner *ActionRunner) performFetchResultAction(containerHandle string, action models.FetchResultAction) (string, error) {
 	fetchResultRunner := NewFetchResultRunner(runner.wardenClient, runner.tempDir)
 	return fetchResultRunner.perform(containerHandle, action)
@@ -4,12 +4,13 @@ import (
 	. "github.com/cloudfoundry-incubator/executor/actionrunner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/downloader/fakedownloader"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/uploader/fakeuploader"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
 	"github.com/cloudfoundry-incubator/executor/linuxplugin"
 	steno "github.com/cloudfoundry/gosteno"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/vito/gordon/fake_gordon"
 	"os"
+	"time"
 
 	"testing"
 )
@@ -32,5 +33,5 @@ var _ = BeforeEach(func() {
 	downloader = &fakedownloader.FakeDownloader{}
 	uploader = &fakeuploader.FakeUploader{}
 	linuxPlugin = linuxplugin.New()
-	runner = New(gordon, linuxPlugin, downloader, uploader, os.TempDir(), steno.NewLogger("test-logger"))
+	runner = New(gordon, linuxPlugin, downloader, uploader, os.TempDir(), time.Second, steno.NewLogger("test-logger"))
 })
@@ -1,6 +1,7 @@
 package downloader
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,8 +12,11 @@ import (
 	steno "github.com/cloudfoundry/gosteno"
 )
 
+var ErrDownloadCancelled = errors.New("download cancelled")
+
 type Downloader interface {
-	Download(url *url.URL, destinationFile *os.File) error
+	// Download aborts the transfer when cancel is closed; cancel may be nil.
+	Download(url *url.URL, destinationFile *os.File, cancel <-chan struct{}) error
 }
 
 type URLDownloader struct {
@@ -27,7 +31,7 @@ func New(timeout time.Duration, logger *steno.Logger) Downloader {
 	}
 }
 
-func (downloader *URLDownloader) Download(url *url.URL, destinationFile *os.File) error {
+func (downloader *URLDownloader) Download(url *url.URL, destinationFile *os.File, cancel <-chan struct{}) error {
 	httpTransport := &http.Transport{
 		ResponseHeaderTimeout: downloader.timeout,
 	}
@@ -38,12 +42,28 @@ func (downloader *URLDownloader) Download(url *url.URL, destinationFile *os.File
 	var resp *http.Response
 	var err error
 	for attempt := 0; attempt < 3; attempt++ {
+		if isCancelled(cancel) {
+			return ErrDownloadCancelled
+		}
+
 		downloader.logger.Infof("downloader.attempt #%d", attempt)
-		resp, err = httpClient.Get(url.String())
+
+		var req *http.Request
+		req, err = http.NewRequest("GET", url.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		req.Cancel = cancel
+
+		resp, err = httpClient.Do(req)
 		if err == nil {
 			break
 		}
 	}
+	if isCancelled(cancel) {
+		return ErrDownloadCancelled
+	}
 	if err != nil {
 		return err
 	}
@@ -54,5 +74,18 @@ func (downloader *URLDownloader) Download(url *url.URL, destinationFile *os.File
 	}
 
 	_, err = io.Copy(destinationFile, resp.Body)
+	if err != nil && isCancelled(cancel) {
+		return ErrDownloadCancelled
+	}
+
 	return err
 }
+
+func isCancelled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -59,7 +59,7 @@ var _ = Describe("Downloader", func() {
 			})
 
 			JustBeforeEach(func() {
-				err := downloader.Download(url, file)
+				err := downloader.Download(url, file, nil)
 				Ω(err).ShouldNot(HaveOccurred())
 			})
 
@@ -95,18 +95,54 @@ var _ = Describe("Downloader", func() {
 			})
 
 			It("should retry 3 times", func() {
-				downloader.Download(url, file)
+				downloader.Download(url, file, nil)
 				lock.Lock()
 				Ω(attemptCount).Should(Equal(3))
 				lock.Unlock()
 			})
 
 			It("should return an error", func() {
-				err := downloader.Download(url, file)
+				err := downloader.Download(url, file, nil)
 				Ω(err).Should(HaveOccurred())
 			})
 		})
 
+		Context("when the download is cancelled", func() {
+			var attemptCount int
+			var cancel chan struct{}
+
+			BeforeEach(func() {
+				attemptCount = 0
+				cancel = make(chan struct{})
+
+				testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					lock.Lock()
+					attemptCount++
+					lock.Unlock()
+
+					time.Sleep(300 * time.Millisecond)
+					fmt.Fprintln(w, "Hello, client")
+				}))
+
+				serverUrl := testServer.URL + "/somepath"
+				url, _ = url.Parse(serverUrl)
+
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					close(cancel)
+				}()
+			})
+
+			It("should return ErrDownloadCancelled without retrying", func() {
+				err := downloader.Download(url, file, cancel)
+				Ω(err).Should(Equal(ErrDownloadCancelled))
+
+				lock.Lock()
+				Ω(attemptCount).Should(Equal(1))
+				lock.Unlock()
+			})
+		})
+
 		Context("when the download fails with a protocol error", func() {
 			BeforeEach(func() {
 				// No server to handle things!
@@ -116,7 +152,7 @@ var _ = Describe("Downloader", func() {
 			})
 
 			It("should return the error", func() {
-				err := downloader.Download(url, file)
+				err := downloader.Download(url, file, nil)
 				Ω(err).NotTo(BeNil())
 			})
 		})
@@ -130,7 +166,7 @@ var _ = Describe("Downloader", func() {
 			})
 
 			It("should return the error", func() {
-				err := downloader.Download(url, file)
+				err := downloader.Download(url, file, nil)
 				Ω(err).NotTo(BeNil())
 			})
 		})
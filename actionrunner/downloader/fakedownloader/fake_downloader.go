@@ -10,15 +10,17 @@ import (
 type FakeDownloader struct {
 	DownloadedUrls []*url.URL
 	SourceFile     *os.File
+	Cancel         <-chan struct{}
 	alwaysFail     bool
 }
 
-func (downloader *FakeDownloader) Download(url *url.URL, destinationFile *os.File) error {
+func (downloader *FakeDownloader) Download(url *url.URL, destinationFile *os.File, cancel <-chan struct{}) error {
 	if downloader.alwaysFail {
 		return errors.New("I accidentally the download")
 	}
 
 	downloader.DownloadedUrls = append(downloader.DownloadedUrls, url)
+	downloader.Cancel = cancel
 
 	if downloader.SourceFile != nil {
 		downloader.SourceFile.Seek(0, 0)
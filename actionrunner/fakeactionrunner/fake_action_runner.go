@@ -1,25 +1,53 @@
 package fakeactionrunner
 
 import (
+	"github.com/cloudfoundry-incubator/executor/action_runner"
+	"github.com/cloudfoundry-incubator/executor/actionrunner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/logstreamer"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 type FakeActionRunner struct {
 	ContainerHandle string
+	PortMappings    []models.PortMapping
 	Actions         []models.ExecutorAction
 	Streamer        logstreamer.LogStreamer
+	Tracker         actionrunner.ProcessTracker
+	Cancel          <-chan struct{}
 	RunError        error
 	RunResult       string
+
+	// when set, Run does not return until it is cancelled
+	BlockUntilCancelled bool
+
+	Resumed            bool
+	ResumedActionIndex int
+	ResumedProcessID   uint32
 }
 
 func New() *FakeActionRunner {
 	return &FakeActionRunner{}
 }
 
-func (runner *FakeActionRunner) Run(containerHandle string, streamer logstreamer.LogStreamer, actions []models.ExecutorAction) (string, error) {
+func (runner *FakeActionRunner) Run(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, tracker actionrunner.ProcessTracker, cancel <-chan struct{}) (string, error) {
 	runner.ContainerHandle = containerHandle
+	runner.PortMappings = portMappings
 	runner.Streamer = streamer
 	runner.Actions = actions
+	runner.Tracker = tracker
+	runner.Cancel = cancel
+
+	if runner.BlockUntilCancelled {
+		<-cancel
+		return "", action_runner.CancelledError
+	}
+
 	return runner.RunResult, runner.RunError
 }
+
+func (runner *FakeActionRunner) Resume(containerHandle string, portMappings []models.PortMapping, streamer logstreamer.LogStreamer, actions []models.ExecutorAction, actionIndex int, processID uint32, tracker actionrunner.ProcessTracker, cancel <-chan struct{}) (string, error) {
+	runner.Resumed = true
+	runner.ResumedActionIndex = actionIndex
+	runner.ResumedProcessID = processID
+	return runner.Run(containerHandle, portMappings, streamer, actions, tracker, cancel)
+}
@@ -2,12 +2,12 @@ package actionrunner
 
 import (
 	"fmt"
-	"github.com/vito/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
 	"io/ioutil"
 	"os"
 	"os/user"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 type FetchResultRunner struct {
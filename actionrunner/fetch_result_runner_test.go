@@ -4,7 +4,7 @@ import (
 	"errors"
 	"strings"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -29,7 +29,7 @@ var _ = Describe("FetchResultRunner", func() {
 	})
 
 	JustBeforeEach(func() {
-		result, err = runner.Run("handle-x", nil, actions)
+		result, err = runner.Run("handle-x", nil, nil, actions, nil, nil)
 	})
 
 	Context("when the file exists", func() {
@@ -9,16 +9,18 @@ import (
 type FakeUploader struct {
 	UploadedFiles []*os.File
 	UploadUrls    []*url.URL
+	Cancel        <-chan struct{}
 	alwaysFail    bool
 }
 
-func (uploader *FakeUploader) Upload(sourceFile *os.File, destinationUrl *url.URL) error {
+func (uploader *FakeUploader) Upload(sourceFile *os.File, destinationUrl *url.URL, cancel <-chan struct{}) error {
 	if uploader.alwaysFail {
 		return errors.New("I accidentally the upload")
 	}
 
 	uploader.UploadUrls = append(uploader.UploadUrls, destinationUrl)
 	uploader.UploadedFiles = append(uploader.UploadedFiles, sourceFile)
+	uploader.Cancel = cancel
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package uploader
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,8 +11,11 @@ import (
 	steno "github.com/cloudfoundry/gosteno"
 )
 
+var ErrUploadCancelled = errors.New("upload cancelled")
+
 type Uploader interface {
-	Upload(sourceFile *os.File, destinationUrl *url.URL) error
+	// Upload aborts the transfer when cancel is closed; cancel may be nil.
+	Upload(sourceFile *os.File, destinationUrl *url.URL, cancel <-chan struct{}) error
 }
 
 type URLUploader struct {
@@ -26,7 +30,7 @@ func New(timeout time.Duration, logger *steno.Logger) Uploader {
 	}
 }
 
-func (uploader *URLUploader) Upload(sourceFile *os.File, url *url.URL) error {
+func (uploader *URLUploader) Upload(sourceFile *os.File, url *url.URL, cancel <-chan struct{}) error {
 	httpTransport := &http.Transport{
 		ResponseHeaderTimeout: uploader.timeout,
 	}
@@ -37,12 +41,29 @@ func (uploader *URLUploader) Upload(sourceFile *os.File, url *url.URL) error {
 	var resp *http.Response
 	var err error
 	for attempt := 0; attempt < 3; attempt++ {
+		if isCancelled(cancel) {
+			return ErrUploadCancelled
+		}
+
 		uploader.logger.Infof("uploader.attempt #%d", attempt)
-		resp, err = httpClient.Post(url.String(), "application/octet-stream", sourceFile)
+
+		var req *http.Request
+		req, err = http.NewRequest("POST", url.String(), sourceFile)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Cancel = cancel
+
+		resp, err = httpClient.Do(req)
 		if err == nil {
 			break
 		}
 	}
+	if isCancelled(cancel) {
+		return ErrUploadCancelled
+	}
 	if err != nil {
 		return err
 	}
@@ -54,3 +75,12 @@ func (uploader *URLUploader) Upload(sourceFile *os.File, url *url.URL) error {
 
 	return nil
 }
+
+func isCancelled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
@@ -66,7 +66,7 @@ var _ = Describe("Uploader", func() {
 			})
 
 			JustBeforeEach(func() {
-				uploader.Upload(file, url)
+				uploader.Upload(file, url, nil)
 			})
 
 			It("uploads the file to the url", func() {
@@ -98,18 +98,54 @@ var _ = Describe("Uploader", func() {
 			})
 
 			It("should retry 3 times", func() {
-				uploader.Upload(file, url)
+				uploader.Upload(file, url, nil)
 				lock.Lock()
 				Ω(attemptCount).Should(Equal(3))
 				lock.Unlock()
 			})
 
 			It("should return an error", func() {
-				err := uploader.Upload(file, url)
+				err := uploader.Upload(file, url, nil)
 				Ω(err).Should(HaveOccurred())
 			})
 		})
 
+		Context("when the upload is cancelled", func() {
+			var attemptCount int
+			var cancel chan struct{}
+
+			BeforeEach(func() {
+				attemptCount = 0
+				cancel = make(chan struct{})
+
+				testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					lock.Lock()
+					attemptCount++
+					lock.Unlock()
+
+					time.Sleep(300 * time.Millisecond)
+					fmt.Fprintln(w, "Hello, client")
+				}))
+
+				serverUrl := testServer.URL + "/somepath"
+				url, _ = url.Parse(serverUrl)
+
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					close(cancel)
+				}()
+			})
+
+			It("should return ErrUploadCancelled without retrying", func() {
+				err := uploader.Upload(file, url, cancel)
+				Ω(err).Should(Equal(ErrUploadCancelled))
+
+				lock.Lock()
+				Ω(attemptCount).Should(Equal(1))
+				lock.Unlock()
+			})
+		})
+
 		Context("when the upload fails with a protocol error", func() {
 			BeforeEach(func() {
 				// No server to handle things!
@@ -119,7 +155,7 @@ var _ = Describe("Uploader", func() {
 			})
 
 			It("should return the error", func() {
-				err := uploader.Upload(file, url)
+				err := uploader.Upload(file, url, nil)
 				Ω(err).NotTo(BeNil())
 			})
 		})
@@ -133,7 +169,7 @@ var _ = Describe("Uploader", func() {
 			})
 
 			It("should return the error", func() {
-				err := uploader.Upload(file, url)
+				err := uploader.Upload(file, url, nil)
 				Ω(err).NotTo(BeNil())
 			})
 		})
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/retention"
+	"github.com/cloudfoundry-incubator/executor/runoncehandler"
+	"github.com/cloudfoundry-incubator/executor/runoncequeue"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+type InfoResponse struct {
+	ExecutorID string   `json:"executor_id"`
+	Stack      string   `json:"stack"`
+	Stacks     []string `json:"stacks"`
+	Uptime     string   `json:"uptime"`
+}
+
+type RegistryResponse struct {
+	TotalCapacity     taskregistry.Capacity             `json:"total_capacity"`
+	UsedCapacity      taskregistry.Capacity             `json:"used_capacity"`
+	AvailableCapacity taskregistry.Capacity             `json:"available_capacity"`
+	RunOnces          []models.RunOnce                  `json:"run_onces"`
+	Lifecycles        map[string]taskregistry.Lifecycle `json:"lifecycles"`
+}
+
+// API is a local HTTP management API for an executor:
+//
+//	GET    /info              the executor's ID, default and supported stacks, and uptime
+//	GET    /registry          the task registry, with used and available capacity and each RunOnce's lifecycle
+//	GET    /queue             the depth of the desired RunOnce queue, and how many RunOnces it rejected
+//	GET    /run_onces         the RunOnces in flight and the action each is on
+//	DELETE /run_onces/<guid>  cancel a RunOnce in flight
+//	GET    /retained_containers         the containers of failed RunOnces kept for debugging
+//	DELETE /retained_containers/<guid>  destroy the retained container of a RunOnce before it expires
+type API struct {
+	executorID     string
+	stacks         []string
+	startedAt      time.Time
+	taskRegistry   *taskregistry.TaskRegistry
+	runOnceQueue   *runoncequeue.RunOnceQueue
+	runOnceHandler runoncehandler.RunOnceHandlerInterface
+	sweeper        *retention.Sweeper
+	logger         *steno.Logger
+
+	mux *http.ServeMux
+}
+
+func New(
+	executorID string,
+	stacks []string,
+	startedAt time.Time,
+	taskRegistry *taskregistry.TaskRegistry,
+	runOnceQueue *runoncequeue.RunOnceQueue,
+	runOnceHandler runoncehandler.RunOnceHandlerInterface,
+	sweeper *retention.Sweeper,
+	logger *steno.Logger,
+) *API {
+	api := &API{
+		executorID:     executorID,
+		stacks:         stacks,
+		startedAt:      startedAt,
+		taskRegistry:   taskRegistry,
+		runOnceQueue:   runOnceQueue,
+		runOnceHandler: runOnceHandler,
+		sweeper:        sweeper,
+		logger:         logger,
+
+		mux: http.NewServeMux(),
+	}
+
+	api.mux.HandleFunc("/info", api.info)
+	api.mux.HandleFunc("/registry", api.registry)
+	api.mux.HandleFunc("/queue", api.queue)
+	api.mux.HandleFunc("/run_onces", api.runOnces)
+	api.mux.HandleFunc("/run_onces/", api.runOnce)
+	api.mux.HandleFunc("/retained_containers", api.retainedContainers)
+	api.mux.HandleFunc("/retained_containers/", api.retainedContainer)
+
+	return api
+}
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mux.ServeHTTP(w, r)
+}
+
+func (api *API) info(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+
+	defaultStack := ""
+	if len(api.stacks) > 0 {
+		defaultStack = api.stacks[0]
+	}
+
+	api.writeJSON(w, InfoResponse{
+		ExecutorID: api.executorID,
+		Stack:      defaultStack,
+		Stacks:     api.stacks,
+		Uptime:     time.Since(api.startedAt).String(),
+	})
+}
+
+func (api *API) registry(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+
+	total := api.taskRegistry.TotalCapacity()
+	available := api.taskRegistry.AvailableCapacity()
+
+	api.writeJSON(w, RegistryResponse{
+		TotalCapacity: total,
+		UsedCapacity: taskregistry.Capacity{
+			MemoryMB:   total.MemoryMB - available.MemoryMB,
+			DiskMB:     total.DiskMB - available.DiskMB,
+			CpuWeight:  total.CpuWeight - available.CpuWeight,
+			Containers: total.Containers - available.Containers,
+		},
+		AvailableCapacity: available,
+		RunOnces:          api.taskRegistry.RegisteredRunOnces(),
+		Lifecycles:        api.taskRegistry.RunOnceLifecycles(),
+	})
+}
+
+func (api *API) queue(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+
+	api.writeJSON(w, api.runOnceQueue.Stats())
+}
+
+func (api *API) runOnces(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+
+	api.writeJSON(w, api.runOnceHandler.InFlight())
+}
+
+func (api *API) runOnce(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "DELETE") {
+		return
+	}
+
+	guid := strings.TrimPrefix(r.URL.Path, "/run_onces/")
+	if guid == "" || strings.Contains(guid, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	api.logger.Infod(map[string]interface{}{
+		"runonce-guid": guid,
+	}, "api.cancel-run-once")
+
+	if !api.runOnceHandler.CancelRunOnce(guid) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *API) retainedContainers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+
+	api.writeJSON(w, api.taskRegistry.ListRetainedContainers())
+}
+
+func (api *API) retainedContainer(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "DELETE") {
+		return
+	}
+
+	guid := strings.TrimPrefix(r.URL.Path, "/retained_containers/")
+	if guid == "" || strings.Contains(guid, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	api.logger.Infod(map[string]interface{}{
+		"runonce-guid": guid,
+	}, "api.destroy-retained-container")
+
+	found, err := api.sweeper.Destroy(guid)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *API) writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		api.logger.Errord(map[string]interface{}{
+			"error": err.Error(),
+		}, "api.write-response.failed")
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	w.WriteHeader(http.StatusMethodNotAllowed)
+
+	return false
+}
@@ -0,0 +1,16 @@
+package api_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+
+	"github.com/cloudfoundry/gosteno"
+)
+
+func TestApi(t *testing.T) {
+	RegisterFailHandler(Fail)
+	gosteno.EnterTestMode()
+	RunSpecs(t, "Api Suite")
+}
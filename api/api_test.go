@@ -0,0 +1,237 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	. "github.com/cloudfoundry-incubator/executor/api"
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/retention"
+	"github.com/cloudfoundry-incubator/executor/runoncehandler"
+	"github.com/cloudfoundry-incubator/executor/runoncehandler/fakerunoncehandler"
+	"github.com/cloudfoundry-incubator/executor/runoncequeue"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var _ = Describe("API", func() {
+	var (
+		api            *API
+		taskRegistry   *taskregistry.TaskRegistry
+		runOnceQueue   *runoncequeue.RunOnceQueue
+		runOnceHandler *fakerunoncehandler.FakeRunOnceHandler
+		gordon         *fake_gordon.FakeGordon
+		runOnce        models.RunOnce
+		response       *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		taskRegistry = taskregistry.NewTaskRegistry("/tmp/api_registry", 256, 1024)
+		runOnceHandler = fakerunoncehandler.New()
+
+		runOnce = models.RunOnce{
+			Guid:     "totally-unique",
+			MemoryMB: 64,
+			DiskMB:   128,
+		}
+
+		err := taskRegistry.AddRunOnce(runOnce)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		runOnceQueue = runoncequeue.New(2, 10, taskRegistry)
+
+		gordon = fake_gordon.New()
+		cacheManager, err := cache.New("", 0, steno.NewLogger("test-logger"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		sweeper := retention.NewSweeper(gordon, taskRegistry, cacheManager, steno.NewLogger("test-logger"))
+
+		api = New("some-executor-id", []string{"penguin", "polar-bear"}, time.Now().Add(-time.Hour), taskRegistry, runOnceQueue, runOnceHandler, sweeper, steno.NewLogger("test-logger"))
+
+		response = httptest.NewRecorder()
+	})
+
+	request := func(method string, path string) {
+		req, err := http.NewRequest(method, path, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		api.ServeHTTP(response, req)
+	}
+
+	Describe("GET /info", func() {
+		It("returns the executor's ID, stacks and uptime", func() {
+			request("GET", "/info")
+			Ω(response.Code).Should(Equal(http.StatusOK))
+
+			var info InfoResponse
+			err := json.Unmarshal(response.Body.Bytes(), &info)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(info.ExecutorID).Should(Equal("some-executor-id"))
+			Ω(info.Stack).Should(Equal("penguin"))
+			Ω(info.Stacks).Should(Equal([]string{"penguin", "polar-bear"}))
+
+			uptime, err := time.ParseDuration(info.Uptime)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(uptime).Should(BeNumerically(">=", time.Hour))
+		})
+
+		It("does not allow other methods", func() {
+			request("POST", "/info")
+			Ω(response.Code).Should(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("GET /registry", func() {
+		It("returns the registered RunOnces and the executor's capacity", func() {
+			request("GET", "/registry")
+			Ω(response.Code).Should(Equal(http.StatusOK))
+
+			var registry RegistryResponse
+			err := json.Unmarshal(response.Body.Bytes(), &registry)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(registry.TotalCapacity).Should(Equal(taskregistry.Capacity{MemoryMB: 256, DiskMB: 1024}))
+			Ω(registry.UsedCapacity).Should(Equal(taskregistry.Capacity{MemoryMB: 64, DiskMB: 128}))
+			Ω(registry.AvailableCapacity).Should(Equal(taskregistry.Capacity{MemoryMB: 192, DiskMB: 896}))
+			Ω(registry.RunOnces).Should(Equal([]models.RunOnce{runOnce}))
+		})
+
+		It("returns the lifecycle of each registered RunOnce", func() {
+			taskRegistry.SetRunOnceState(runOnce.Guid, taskregistry.StateClaimed)
+
+			request("GET", "/registry")
+			Ω(response.Code).Should(Equal(http.StatusOK))
+
+			var registry RegistryResponse
+			err := json.Unmarshal(response.Body.Bytes(), &registry)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(registry.Lifecycles).Should(HaveLen(1))
+			Ω(registry.Lifecycles[runOnce.Guid].State).Should(Equal(taskregistry.StateClaimed))
+			Ω(registry.Lifecycles[runOnce.Guid].Transitions).Should(HaveKey(taskregistry.StateClaimed))
+		})
+	})
+
+	Describe("GET /queue", func() {
+		BeforeEach(func() {
+			err := runOnceQueue.Enqueue(models.RunOnce{Guid: "queued"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = runOnceQueue.Enqueue(models.RunOnce{Guid: "too-big", MemoryMB: 1024})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("returns the queue's depth and rejection counts", func() {
+			request("GET", "/queue")
+			Ω(response.Code).Should(Equal(http.StatusOK))
+
+			var stats runoncequeue.Stats
+			err := json.Unmarshal(response.Body.Bytes(), &stats)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(stats).Should(Equal(runoncequeue.Stats{
+				Workers:                       2,
+				Depth:                         1,
+				MaxDepth:                      10,
+				RejectedInsufficientResources: 1,
+			}))
+		})
+	})
+
+	Describe("GET /run_onces", func() {
+		BeforeEach(func() {
+			runOnceHandler.InFlightRunOnces = []runoncehandler.InFlightRunOnce{
+				{Guid: "totally-unique", Action: "execute"},
+			}
+		})
+
+		It("returns the RunOnces in flight", func() {
+			request("GET", "/run_onces")
+			Ω(response.Code).Should(Equal(http.StatusOK))
+
+			var inFlight []runoncehandler.InFlightRunOnce
+			err := json.Unmarshal(response.Body.Bytes(), &inFlight)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(inFlight).Should(Equal(runOnceHandler.InFlightRunOnces))
+		})
+	})
+
+	Describe("DELETE /run_onces/:guid", func() {
+		Context("when the RunOnce is in flight", func() {
+			BeforeEach(func() {
+				runOnceHandler.CancelRunOnceResult = true
+			})
+
+			It("cancels it", func() {
+				request("DELETE", "/run_onces/totally-unique")
+				Ω(response.Code).Should(Equal(http.StatusNoContent))
+
+				Ω(runOnceHandler.CancelledRunOnces).Should(Equal([]string{"totally-unique"}))
+			})
+		})
+
+		Context("when the RunOnce is not in flight", func() {
+			It("returns 404", func() {
+				request("DELETE", "/run_onces/totally-unique")
+				Ω(response.Code).Should(Equal(http.StatusNotFound))
+			})
+		})
+
+		It("does not allow other methods", func() {
+			request("GET", "/run_onces/totally-unique")
+			Ω(response.Code).Should(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("GET /retained_containers", func() {
+		BeforeEach(func() {
+			taskRegistry.RetainContainer(models.RunOnce{Guid: "failed-guid", ContainerHandle: "failed-handle"}, time.Hour)
+		})
+
+		It("returns the retained containers with their RunOnces' guids", func() {
+			request("GET", "/retained_containers")
+			Ω(response.Code).Should(Equal(http.StatusOK))
+
+			var retainedContainers []taskregistry.RetainedContainer
+			err := json.Unmarshal(response.Body.Bytes(), &retainedContainers)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(retainedContainers).Should(HaveLen(1))
+			Ω(retainedContainers[0].RunOnceGuid).Should(Equal("failed-guid"))
+			Ω(retainedContainers[0].ContainerHandle).Should(Equal("failed-handle"))
+		})
+	})
+
+	Describe("DELETE /retained_containers/:guid", func() {
+		Context("when the RunOnce's container is retained", func() {
+			BeforeEach(func() {
+				taskRegistry.RetainContainer(models.RunOnce{Guid: "failed-guid", ContainerHandle: "failed-handle"}, time.Hour)
+			})
+
+			It("destroys it", func() {
+				request("DELETE", "/retained_containers/failed-guid")
+				Ω(response.Code).Should(Equal(http.StatusNoContent))
+
+				Ω(gordon.DestroyedHandles()).Should(Equal([]string{"failed-handle"}))
+				Ω(taskRegistry.ListRetainedContainers()).Should(BeEmpty())
+			})
+		})
+
+		Context("when the RunOnce's container is not retained", func() {
+			It("returns 404", func() {
+				request("DELETE", "/retained_containers/failed-guid")
+				Ω(response.Code).Should(Equal(http.StatusNotFound))
+			})
+		})
+	})
+})
@@ -0,0 +1,52 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces the file with data by way of a synced temp file next to
+// it, so that a crash leaves either the old file or the new one, never a torn
+// one.  The file is readable only by the executor's user.
+func WriteFile(fileName string, data []byte) error {
+	tempFileName := fileName + ".tmp"
+
+	file, err := os.OpenFile(tempFileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tempFileName)
+		return err
+	}
+
+	err = os.Rename(tempFileName, fileName)
+	if err != nil {
+		os.Remove(tempFileName)
+		return err
+	}
+
+	return syncDir(filepath.Dir(fileName))
+}
+
+// syncDir makes the rename durable
+func syncDir(dirName string) error {
+	dir, err := os.Open(dirName)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
@@ -0,0 +1,72 @@
+package atomicfile_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry-incubator/executor/atomicfile"
+)
+
+var _ = Describe("WriteFile", func() {
+	var fileName string
+
+	BeforeEach(func() {
+		fileName = fmt.Sprintf("/tmp/executor_atomicfile_%d", config.GinkgoConfig.ParallelNode)
+		os.Remove(fileName)
+	})
+
+	AfterEach(func() {
+		os.Remove(fileName)
+	})
+
+	It("writes the data to the file", func() {
+		err := WriteFile(fileName, []byte("some data"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		data, err := ioutil.ReadFile(fileName)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).Should(Equal("some data"))
+	})
+
+	It("replaces an existing file", func() {
+		err := ioutil.WriteFile(fileName, []byte("some old, rather longer data"), 0600)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		err = WriteFile(fileName, []byte("some data"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		data, err := ioutil.ReadFile(fileName)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).Should(Equal("some data"))
+	})
+
+	It("makes the file readable only by the executor's user", func() {
+		err := WriteFile(fileName, []byte("some data"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		info, err := os.Stat(fileName)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(info.Mode().Perm()).Should(Equal(os.FileMode(0600)))
+	})
+
+	It("leaves no temporary file behind", func() {
+		err := WriteFile(fileName, []byte("some data"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = os.Stat(fileName + ".tmp")
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("returns an error if the file cannot be written to", func() {
+		err := WriteFile("/tmp", []byte("some data"))
+		Ω(err).Should(HaveOccurred())
+
+		_, err = os.Stat("/tmp.tmp")
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+})
@@ -1,10 +1,12 @@
 package backend_plugin
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 type BackendPlugin interface {
-	BuildRunScript(models.RunAction) string
+	// BuildRunScript builds the script for a RunAction, with the RunOnce's
+	// port mappings in its environment
+	BuildRunScript(models.RunAction, []models.PortMapping) string
 	BuildCreateDirectoryRecursivelyCommand(string) string
 }
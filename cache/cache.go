@@ -0,0 +1,325 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+)
+
+var ErrInvalidKey = errors.New("invalid cache key")
+var ErrInvalidContainerPath = errors.New("cache container path must be absolute")
+var ErrCacheInUse = errors.New("cache is in use")
+var ErrNoBudget = errors.New("caches need a size limit")
+
+// keys name the caches' directories, so they are kept to safe file names
+var validKey = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Entry describes a cache.  LastUsed is in nanoseconds since the epoch.
+type Entry struct {
+	Key       string `json:"key"`
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+	LastUsed  int64  `json:"last_used"`
+	Readers   int    `json:"readers"`
+	Writing   bool   `json:"writing"`
+}
+
+// Manager keeps named cache directories, e.g. one per app or per buildpack,
+// which RunOnces have bind-mounted into their containers.  Any number of
+// RunOnces can read a cache at once, but only one can write to it, and not
+// while others read it.  Once the caches outgrow the size limit, the least
+// recently used ones that are not in use are evicted.  A Manager with no root
+// directory keeps no caches, and RunOnces that ask for them go without.
+type Manager struct {
+	root         string
+	maxSizeBytes int64
+	logger       *steno.Logger
+
+	entries  map[string]*Entry
+	acquired map[string][]models.CacheMount
+
+	lock *sync.Mutex
+}
+
+// New returns a Manager for the caches in root, picking up the ones that are
+// already there, e.g. from before the executor restarted.
+func New(root string, maxSizeMB int, logger *steno.Logger) (*Manager, error) {
+	manager := &Manager{
+		root:         root,
+		maxSizeBytes: int64(maxSizeMB) * 1024 * 1024,
+		logger:       logger,
+
+		entries:  make(map[string]*Entry),
+		acquired: make(map[string][]models.CacheMount),
+
+		lock: &sync.Mutex{},
+	}
+
+	if root == "" {
+		return manager, nil
+	}
+
+	if maxSizeMB <= 0 {
+		return nil, ErrNoBudget
+	}
+
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() || !validKey.MatchString(info.Name()) {
+			continue
+		}
+
+		path := filepath.Join(root, info.Name())
+
+		manager.entries[info.Name()] = &Entry{
+			Key:       info.Name(),
+			Path:      path,
+			SizeBytes: sizeOf(path),
+			LastUsed:  info.ModTime().UnixNano(),
+		}
+	}
+
+	manager.evict()
+
+	return manager, nil
+}
+
+func (manager *Manager) Enabled() bool {
+	return manager.root != ""
+}
+
+// Acquire marks the RunOnce's caches as in use, creating any that do not exist
+// yet, and returns how to bind-mount them into its container.  Either all of
+// the caches are acquired, or none are.
+func (manager *Manager) Acquire(runOnceGuid string, mounts []models.CacheMount) ([]gordon.BindMount, error) {
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+
+	if !manager.Enabled() {
+		manager.logger.Infod(
+			map[string]interface{}{
+				"runonce-guid": runOnceGuid,
+			}, "cache.disabled",
+		)
+
+		return nil, nil
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	keys := make(map[string]bool)
+
+	for _, mount := range mounts {
+		if !validKey.MatchString(mount.Key) || keys[mount.Key] {
+			return nil, ErrInvalidKey
+		}
+
+		if !filepath.IsAbs(mount.ContainerPath) {
+			return nil, ErrInvalidContainerPath
+		}
+
+		keys[mount.Key] = true
+
+		entry, found := manager.entries[mount.Key]
+		if found && (entry.Writing || (!mount.ReadOnly && entry.Readers > 0)) {
+			return nil, ErrCacheInUse
+		}
+	}
+
+	bindMounts := []gordon.BindMount{}
+
+	for i, mount := range mounts {
+		entry, err := manager.entry(mount.Key)
+		if err != nil {
+			manager.release(mounts[:i])
+			return nil, err
+		}
+
+		if mount.ReadOnly {
+			entry.Readers++
+		} else {
+			entry.Writing = true
+		}
+
+		bindMounts = append(bindMounts, gordon.BindMount{
+			SrcPath:  entry.Path,
+			DstPath:  mount.ContainerPath,
+			ReadOnly: mount.ReadOnly,
+		})
+	}
+
+	manager.acquired[runOnceGuid] = mounts
+
+	return bindMounts, nil
+}
+
+// Release marks the RunOnce's caches as no longer in use, and evicts caches
+// if they have outgrown the size limit.
+func (manager *Manager) Release(runOnceGuid string) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	mounts, found := manager.acquired[runOnceGuid]
+	if !found {
+		return
+	}
+
+	delete(manager.acquired, runOnceGuid)
+
+	manager.release(mounts)
+	manager.evict()
+}
+
+// Entries returns the caches, least recently used first.
+func (manager *Manager) Entries() []Entry {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	entries := []Entry{}
+	for _, entry := range manager.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Sort(byLastUsed(entries))
+
+	return entries
+}
+
+func (manager *Manager) entry(key string) (*Entry, error) {
+	entry, found := manager.entries[key]
+	if found {
+		return entry, nil
+	}
+
+	path := filepath.Join(manager.root, key)
+
+	err := os.MkdirAll(path, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	// the umask would keep the RunOnce's user from writing to it
+	err = os.Chmod(path, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &Entry{
+		Key:      key,
+		Path:     path,
+		LastUsed: time.Now().UnixNano(),
+	}
+
+	manager.entries[key] = entry
+
+	return entry, nil
+}
+
+func (manager *Manager) release(mounts []models.CacheMount) {
+	now := time.Now().UnixNano()
+
+	for _, mount := range mounts {
+		entry, found := manager.entries[mount.Key]
+		if !found {
+			continue
+		}
+
+		if mount.ReadOnly {
+			if entry.Readers > 0 {
+				entry.Readers--
+			}
+		} else {
+			entry.Writing = false
+		}
+
+		entry.LastUsed = now
+		entry.SizeBytes = sizeOf(entry.Path)
+	}
+}
+
+// evict removes the least recently used caches that are not in use, until
+// the caches fit in the size limit.
+func (manager *Manager) evict() {
+	size := int64(0)
+	idle := []Entry{}
+
+	for _, entry := range manager.entries {
+		if entry.Readers == 0 && !entry.Writing {
+			idle = append(idle, *entry)
+		} else {
+			// caches in use may have grown since they were acquired
+			entry.SizeBytes = sizeOf(entry.Path)
+		}
+
+		size += entry.SizeBytes
+	}
+
+	sort.Sort(byLastUsed(idle))
+
+	for _, entry := range idle {
+		if size <= manager.maxSizeBytes {
+			return
+		}
+
+		err := os.RemoveAll(entry.Path)
+		if err != nil {
+			manager.logger.Errord(
+				map[string]interface{}{
+					"key":   entry.Key,
+					"error": err.Error(),
+				}, "cache.evict.failed",
+			)
+			continue
+		}
+
+		delete(manager.entries, entry.Key)
+		size -= entry.SizeBytes
+
+		manager.logger.Infod(
+			map[string]interface{}{
+				"key":        entry.Key,
+				"size-bytes": entry.SizeBytes,
+			}, "cache.evicted",
+		)
+	}
+}
+
+func sizeOf(path string) int64 {
+	size := int64(0)
+
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
+
+type byLastUsed []Entry
+
+func (s byLastUsed) Len() int           { return len(s) }
+func (s byLastUsed) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLastUsed) Less(i, j int) bool { return s[i].LastUsed < s[j].LastUsed }
@@ -0,0 +1,220 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	. "github.com/cloudfoundry-incubator/executor/cache"
+)
+
+var _ = Describe("Cache Manager", func() {
+	var root string
+	var manager *Manager
+
+	writeFile := func(key string, sizeKB int) {
+		err := ioutil.WriteFile(filepath.Join(root, key, "stuff"), make([]byte, sizeKB*1024), 0644)
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		root, err = ioutil.TempDir("", "executor-caches")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		manager, err = New(root, 1, steno.NewLogger("test-logger"))
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	Describe("New", func() {
+		It("picks up the caches that are already there", func() {
+			err := os.Mkdir(filepath.Join(root, "app-123"), 0777)
+			Ω(err).ShouldNot(HaveOccurred())
+			writeFile("app-123", 10)
+
+			manager, err = New(root, 1, steno.NewLogger("test-logger"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			entries := manager.Entries()
+			Ω(entries).Should(HaveLen(1))
+			Ω(entries[0].Key).Should(Equal("app-123"))
+			Ω(entries[0].SizeBytes).Should(Equal(int64(10 * 1024)))
+		})
+
+		It("requires a size limit", func() {
+			_, err := New(root, 0, steno.NewLogger("test-logger"))
+			Ω(err).Should(Equal(ErrNoBudget))
+		})
+	})
+
+	Describe("Acquire", func() {
+		It("creates the caches, and returns how to mount them", func() {
+			bindMounts, err := manager.Acquire("run-once-1", []models.CacheMount{
+				{Key: "app-123", ContainerPath: "/tmp/app-cache"},
+				{Key: "buildpack-ruby", ContainerPath: "/tmp/buildpack-cache", ReadOnly: true},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(bindMounts).Should(Equal([]gordon.BindMount{
+				{SrcPath: filepath.Join(root, "app-123"), DstPath: "/tmp/app-cache"},
+				{SrcPath: filepath.Join(root, "buildpack-ruby"), DstPath: "/tmp/buildpack-cache", ReadOnly: true},
+			}))
+
+			info, err := os.Stat(filepath.Join(root, "app-123"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(info.IsDir()).Should(BeTrue())
+		})
+
+		It("rejects keys that are not safe file names", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{
+				{Key: "../etc", ContainerPath: "/tmp/cache"},
+			})
+			Ω(err).Should(Equal(ErrInvalidKey))
+
+			Ω(manager.Entries()).Should(BeEmpty())
+		})
+
+		It("rejects a key given twice", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{
+				{Key: "app-123", ContainerPath: "/tmp/cache-1"},
+				{Key: "app-123", ContainerPath: "/tmp/cache-2"},
+			})
+			Ω(err).Should(Equal(ErrInvalidKey))
+		})
+
+		It("rejects relative container paths", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{
+				{Key: "app-123", ContainerPath: "tmp/cache"},
+			})
+			Ω(err).Should(Equal(ErrInvalidContainerPath))
+		})
+
+		It("lets any number of RunOnces read a cache", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache", ReadOnly: true}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = manager.Acquire("run-once-2", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache", ReadOnly: true}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(manager.Entries()[0].Readers).Should(Equal(2))
+		})
+
+		It("lets only one RunOnce write to a cache, and not while others read it", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache"}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = manager.Acquire("run-once-2", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache"}})
+			Ω(err).Should(Equal(ErrCacheInUse))
+
+			_, err = manager.Acquire("run-once-2", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache", ReadOnly: true}})
+			Ω(err).Should(Equal(ErrCacheInUse))
+
+			manager.Release("run-once-1")
+
+			_, err = manager.Acquire("run-once-2", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache", ReadOnly: true}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = manager.Acquire("run-once-3", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache"}})
+			Ω(err).Should(Equal(ErrCacheInUse))
+		})
+
+		It("acquires none of the caches if any is in use", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{{Key: "buildpack-ruby", ContainerPath: "/cache"}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = manager.Acquire("run-once-2", []models.CacheMount{
+				{Key: "app-123", ContainerPath: "/app-cache"},
+				{Key: "buildpack-ruby", ContainerPath: "/buildpack-cache"},
+			})
+			Ω(err).Should(Equal(ErrCacheInUse))
+
+			_, err = manager.Acquire("run-once-3", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache"}})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		Context("when caching is disabled", func() {
+			BeforeEach(func() {
+				var err error
+
+				manager, err = New("", 0, steno.NewLogger("test-logger"))
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("mounts nothing", func() {
+				Ω(manager.Enabled()).Should(BeFalse())
+
+				bindMounts, err := manager.Acquire("run-once-1", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache"}})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(bindMounts).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Release", func() {
+		It("records the cache's size", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{{Key: "app-123", ContainerPath: "/cache"}})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			writeFile("app-123", 100)
+
+			manager.Release("run-once-1")
+
+			entries := manager.Entries()
+			Ω(entries[0].SizeBytes).Should(Equal(int64(100 * 1024)))
+			Ω(entries[0].Writing).Should(BeFalse())
+		})
+
+		It("evicts the least recently used caches once they outgrow the size limit", func() {
+			use := func(key string) {
+				_, err := manager.Acquire("run-once-"+key, []models.CacheMount{{Key: key, ContainerPath: "/cache"}})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				writeFile(key, 400)
+
+				manager.Release("run-once-" + key)
+			}
+
+			use("app-1")
+			use("app-2")
+			Ω(manager.Entries()).Should(HaveLen(2))
+
+			use("app-3")
+
+			entries := manager.Entries()
+			Ω(entries).Should(HaveLen(2))
+			Ω(entries[0].Key).Should(Equal("app-2"))
+			Ω(entries[1].Key).Should(Equal("app-3"))
+
+			_, err := os.Stat(filepath.Join(root, "app-1"))
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		It("does not evict caches that are in use", func() {
+			_, err := manager.Acquire("run-once-1", []models.CacheMount{{Key: "app-1", ContainerPath: "/cache"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			writeFile("app-1", 800)
+
+			_, err = manager.Acquire("run-once-2", []models.CacheMount{{Key: "app-2", ContainerPath: "/cache"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			writeFile("app-2", 800)
+
+			manager.Release("run-once-2")
+
+			entries := manager.Entries()
+			Ω(entries).Should(HaveLen(1))
+			Ω(entries[0].Key).Should(Equal("app-1"))
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package containerfactory
+
+import (
+	"strings"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/nu7hatch/gouuid"
+)
+
+// the prefix of the handles of the containers that executors create, unless
+// they are told otherwise
+const DefaultHandlePrefix = "executor-"
+
+// ContainerFactory creates the executor's warden containers, with the rootfs
+// of the stack they are for.  Their handles all start with the same prefix, so
+// that containers the executor created can be told apart from any others on
+// the same warden server.
+type ContainerFactory struct {
+	wardenClient gordon.Client
+	handlePrefix string
+	stacks       Stacks
+}
+
+func New(wardenClient gordon.Client, handlePrefix string, stacks Stacks) *ContainerFactory {
+	return &ContainerFactory{
+		wardenClient: wardenClient,
+		handlePrefix: handlePrefix,
+		stacks:       stacks,
+	}
+}
+
+func (factory *ContainerFactory) Stacks() Stacks {
+	return factory.stacks
+}
+
+// Create creates a container for the given stack, with the given directories
+// mounted into it, and returns its handle.  An empty stack is the default one.
+func (factory *ContainerFactory) Create(stack string, bindMounts []gordon.BindMount) (string, error) {
+	rootfs, err := factory.stacks.Rootfs(stack)
+	if err != nil {
+		return "", err
+	}
+
+	handleUuid, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	createResponse, err := factory.wardenClient.CreateContainer(gordon.ContainerSpec{
+		Handle:     factory.handlePrefix + handleUuid.String(),
+		RootFSPath: rootfs,
+		BindMounts: bindMounts,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return createResponse.GetHandle(), nil
+}
+
+// Owns tells whether the container with the given handle was created by an
+// executor with the same handle prefix.  A factory with no prefix owns no
+// containers, so that it never claims containers it did not create.
+func (factory *ContainerFactory) Owns(handle string) bool {
+	return factory.handlePrefix != "" && strings.HasPrefix(handle, factory.handlePrefix)
+}
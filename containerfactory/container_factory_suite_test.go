@@ -0,0 +1,13 @@
+package containerfactory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestContainerFactory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ContainerFactory Suite")
+}
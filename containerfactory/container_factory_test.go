@@ -0,0 +1,116 @@
+package containerfactory_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry-incubator/executor/containerfactory"
+)
+
+var _ = Describe("ContainerFactory", func() {
+	var wardenClient *fake_gordon.FakeGordon
+	var factory *ContainerFactory
+
+	BeforeEach(func() {
+		wardenClient = fake_gordon.New()
+		factory = New(wardenClient, "some-prefix-", NewStacks("lucid64", map[string]string{
+			"trusty64": "/rootfs/trusty64",
+		}))
+	})
+
+	Describe("Create", func() {
+		It("creates a container with a handle that starts with the prefix", func() {
+			handle, err := factory.Create("", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(strings.HasPrefix(handle, "some-prefix-")).Should(BeTrue())
+			Ω(wardenClient.CreatedHandles()).Should(Equal([]string{handle}))
+			Ω(wardenClient.CreatedContainers()[0].Handle).Should(Equal(handle))
+		})
+
+		It("gives every container a different handle", func() {
+			handle1, err := factory.Create("", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			handle2, err := factory.Create("", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(handle1).ShouldNot(Equal(handle2))
+		})
+
+		It("creates the container with the stack's rootfs", func() {
+			_, err := factory.Create("trusty64", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(wardenClient.CreatedContainers()[0].RootFSPath).Should(Equal("/rootfs/trusty64"))
+		})
+
+		It("creates containers for the default stack with warden's default rootfs", func() {
+			_, err := factory.Create("", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = factory.Create("lucid64", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(wardenClient.CreatedContainers()[0].RootFSPath).Should(BeEmpty())
+			Ω(wardenClient.CreatedContainers()[1].RootFSPath).Should(BeEmpty())
+		})
+
+		It("mounts the given directories into the container", func() {
+			bindMounts := []gordon.BindMount{
+				{SrcPath: "/host/cache", DstPath: "/tmp/cache"},
+			}
+
+			_, err := factory.Create("", bindMounts)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(wardenClient.CreatedContainers()[0].BindMounts).Should(Equal(bindMounts))
+		})
+
+		Context("when the stack is not supported", func() {
+			It("returns ErrUnsupportedStack without creating a container", func() {
+				_, err := factory.Create("windows", nil)
+				Ω(err).Should(Equal(ErrUnsupportedStack))
+
+				Ω(wardenClient.CreatedHandles()).Should(BeEmpty())
+			})
+		})
+
+		Context("when warden fails to create the container", func() {
+			BeforeEach(func() {
+				wardenClient.CreateError = errors.New("thou shall not pass")
+			})
+
+			It("returns the error", func() {
+				_, err := factory.Create("", nil)
+				Ω(err).Should(Equal(wardenClient.CreateError))
+			})
+		})
+	})
+
+	Describe("Owns", func() {
+		It("owns handles that start with the prefix", func() {
+			Ω(factory.Owns("some-prefix-abc")).Should(BeTrue())
+		})
+
+		It("does not own any other handles", func() {
+			Ω(factory.Owns("abc")).Should(BeFalse())
+			Ω(factory.Owns("other-prefix-abc")).Should(BeFalse())
+		})
+
+		Context("when there is no prefix", func() {
+			BeforeEach(func() {
+				factory = New(wardenClient, "", NewStacks("lucid64", nil))
+			})
+
+			It("owns nothing", func() {
+				Ω(factory.Owns("abc")).Should(BeFalse())
+			})
+		})
+	})
+})
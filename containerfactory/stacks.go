@@ -0,0 +1,98 @@
+package containerfactory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrUnsupportedStack = errors.New("unsupported stack")
+
+// Stacks are the stacks an executor supports, and the rootfs that containers
+// for each are created with.  An empty rootfs is warden's default one.
+// RunOnces that do not ask for a stack are run on the default stack.
+type Stacks struct {
+	Default  string
+	Rootfses map[string]string
+}
+
+// NewStacks returns the given stacks.  The default stack is supported even if
+// it has no rootfs, in which case it uses warden's default one.
+func NewStacks(defaultStack string, rootfses map[string]string) Stacks {
+	stacks := Stacks{
+		Default:  defaultStack,
+		Rootfses: map[string]string{defaultStack: ""},
+	}
+
+	for stack, rootfs := range rootfses {
+		stacks.Rootfses[stack] = rootfs
+	}
+
+	return stacks
+}
+
+// ParseStacks parses a comma-separated list of stack:rootfs pairs, such as
+// "lucid64:/var/vcap/rootfs/lucid64,trusty64:/var/vcap/rootfs/trusty64".
+func ParseStacks(defaultStack string, stackRootfses string) (Stacks, error) {
+	rootfses := make(map[string]string)
+
+	for _, pair := range strings.Split(stackRootfses, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return Stacks{}, fmt.Errorf("invalid stack rootfs %q: expected stack:rootfs", pair)
+		}
+
+		if _, duplicate := rootfses[parts[0]]; duplicate {
+			return Stacks{}, fmt.Errorf("stack %q is given more than one rootfs", parts[0])
+		}
+
+		rootfses[parts[0]] = parts[1]
+	}
+
+	return NewStacks(defaultStack, rootfses), nil
+}
+
+// Names returns the names of the supported stacks, the default one first.
+func (stacks Stacks) Names() []string {
+	others := []string{}
+	for stack := range stacks.Rootfses {
+		if stack != stacks.Default {
+			others = append(others, stack)
+		}
+	}
+
+	sort.Strings(others)
+
+	return append([]string{stacks.Default}, others...)
+}
+
+// Supports tells whether RunOnces for the given stack can be run.  A RunOnce
+// with no stack is run on the default stack.
+func (stacks Stacks) Supports(stack string) bool {
+	_, supported := stacks.Rootfses[stacks.resolve(stack)]
+	return supported
+}
+
+// Rootfs returns the rootfs of the given stack, or ErrUnsupportedStack.
+func (stacks Stacks) Rootfs(stack string) (string, error) {
+	rootfs, supported := stacks.Rootfses[stacks.resolve(stack)]
+	if !supported {
+		return "", ErrUnsupportedStack
+	}
+
+	return rootfs, nil
+}
+
+func (stacks Stacks) resolve(stack string) string {
+	if stack == "" {
+		return stacks.Default
+	}
+
+	return stack
+}
@@ -0,0 +1,85 @@
+package containerfactory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry-incubator/executor/containerfactory"
+)
+
+var _ = Describe("Stacks", func() {
+	var stacks Stacks
+
+	BeforeEach(func() {
+		stacks = NewStacks("lucid64", map[string]string{
+			"trusty64": "/rootfs/trusty64",
+			"centos6":  "/rootfs/centos6",
+		})
+	})
+
+	Describe("Names", func() {
+		It("returns the default stack first, and the rest in order", func() {
+			Ω(stacks.Names()).Should(Equal([]string{"lucid64", "centos6", "trusty64"}))
+		})
+	})
+
+	Describe("Supports", func() {
+		It("supports the stacks with a rootfs, the default stack and no stack", func() {
+			Ω(stacks.Supports("trusty64")).Should(BeTrue())
+			Ω(stacks.Supports("lucid64")).Should(BeTrue())
+			Ω(stacks.Supports("")).Should(BeTrue())
+		})
+
+		It("does not support any other stack", func() {
+			Ω(stacks.Supports("windows")).Should(BeFalse())
+		})
+	})
+
+	Describe("Rootfs", func() {
+		It("returns the stack's rootfs", func() {
+			rootfs, err := stacks.Rootfs("trusty64")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(rootfs).Should(Equal("/rootfs/trusty64"))
+		})
+
+		It("returns the default stack's rootfs when no stack is given", func() {
+			stacks = NewStacks("lucid64", map[string]string{"lucid64": "/rootfs/lucid64"})
+
+			rootfs, err := stacks.Rootfs("")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(rootfs).Should(Equal("/rootfs/lucid64"))
+		})
+
+		It("returns ErrUnsupportedStack for any other stack", func() {
+			_, err := stacks.Rootfs("windows")
+			Ω(err).Should(Equal(ErrUnsupportedStack))
+		})
+	})
+
+	Describe("ParseStacks", func() {
+		It("parses stack:rootfs pairs", func() {
+			parsed, err := ParseStacks("lucid64", "trusty64:/rootfs/trusty64, centos6:/rootfs/centos6")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(parsed).Should(Equal(stacks))
+		})
+
+		It("supports only the default stack when there are no pairs", func() {
+			parsed, err := ParseStacks("lucid64", "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(parsed.Names()).Should(Equal([]string{"lucid64"}))
+		})
+
+		It("fails on a pair without a rootfs", func() {
+			_, err := ParseStacks("lucid64", "trusty64")
+			Ω(err).Should(HaveOccurred())
+
+			_, err = ParseStacks("lucid64", "trusty64:")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("fails on a stack given twice", func() {
+			_, err := ParseStacks("lucid64", "trusty64:/a,trusty64:/b")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,247 @@
+package containerpool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+// how often the pool checks whether it can be topped up, e.g. because
+// containers have been freed up in the registry
+const DefaultRefillInterval = 1 * time.Second
+
+type ContainerPoolInterface interface {
+	Take(stack string) (handle string, found bool)
+}
+
+// ContainerPool keeps up to a fixed number of idle, pre-created warden
+// containers for each stack, so that RunOnces do not wait for a container to
+// be created.  Idle containers count against the registry's container limit:
+// the pool never holds more than the registry has room for.
+type ContainerPool struct {
+	stacks           []string
+	size             int
+	containerFactory *containerfactory.ContainerFactory
+	wardenClient     gordon.Client
+	taskRegistry     *taskregistry.TaskRegistry
+	refillInterval   time.Duration
+	logger           *steno.Logger
+
+	idle     map[string][]string
+	draining bool
+	started  bool
+
+	refill chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+
+	lock *sync.Mutex
+}
+
+func New(
+	stacks []string,
+	size int,
+	containerFactory *containerfactory.ContainerFactory,
+	wardenClient gordon.Client,
+	taskRegistry *taskregistry.TaskRegistry,
+	refillInterval time.Duration,
+	logger *steno.Logger,
+) *ContainerPool {
+	return &ContainerPool{
+		stacks:           stacks,
+		size:             size,
+		containerFactory: containerFactory,
+		wardenClient:     wardenClient,
+		taskRegistry:     taskRegistry,
+		refillInterval:   refillInterval,
+		logger:           logger,
+
+		idle: make(map[string][]string),
+
+		refill: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+
+		lock: &sync.Mutex{},
+	}
+}
+
+// Start fills the pool, and keeps it topped up in the background until it is
+// drained.  A pool with a size of 0 does nothing.
+func (pool *ContainerPool) Start() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.size <= 0 || pool.started || pool.draining {
+		return
+	}
+
+	pool.started = true
+
+	go pool.refillLoop()
+}
+
+// Take hands out an idle container for the given stack, which is no longer
+// the pool's to destroy.  A RunOnce with no stack gets a container for the
+// first of the pool's stacks.
+func (pool *ContainerPool) Take(stack string) (string, bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if stack == "" && len(pool.stacks) > 0 {
+		stack = pool.stacks[0]
+	}
+
+	idle := pool.idle[stack]
+	if len(idle) == 0 {
+		return "", false
+	}
+
+	handle := idle[0]
+	pool.idle[stack] = idle[1:]
+
+	select {
+	case pool.refill <- struct{}{}:
+	default:
+	}
+
+	return handle, true
+}
+
+// Idle returns how many idle containers the pool holds for each stack.
+func (pool *ContainerPool) Idle() map[string]int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	idle := make(map[string]int)
+	for _, stack := range pool.stacks {
+		idle[stack] = len(pool.idle[stack])
+	}
+
+	return idle
+}
+
+// ContainerHandles returns the handles of the pool's idle containers.
+func (pool *ContainerPool) ContainerHandles() []string {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	handles := []string{}
+	for _, stack := range pool.stacks {
+		handles = append(handles, pool.idle[stack]...)
+	}
+
+	return handles
+}
+
+// Drain stops refilling the pool and destroys its idle containers.
+func (pool *ContainerPool) Drain() {
+	pool.lock.Lock()
+
+	if pool.draining {
+		pool.lock.Unlock()
+		return
+	}
+
+	pool.draining = true
+	started := pool.started
+	close(pool.stop)
+
+	pool.lock.Unlock()
+
+	if started {
+		<-pool.done
+	}
+
+	pool.lock.Lock()
+
+	handles := []string{}
+	for _, stack := range pool.stacks {
+		handles = append(handles, pool.idle[stack]...)
+	}
+	pool.idle = make(map[string][]string)
+
+	pool.lock.Unlock()
+
+	for _, handle := range handles {
+		pool.destroy(handle)
+	}
+}
+
+func (pool *ContainerPool) refillLoop() {
+	defer close(pool.done)
+
+	ticker := time.NewTicker(pool.refillInterval)
+	defer ticker.Stop()
+
+	for {
+		pool.fill()
+
+		select {
+		case <-pool.refill:
+		case <-ticker.C:
+		case <-pool.stop:
+			return
+		}
+	}
+}
+
+// fill creates containers until every stack has its share, or the registry
+// has no room for more.  It gives up until the next refill if warden fails.
+func (pool *ContainerPool) fill() {
+	for _, stack := range pool.stacks {
+		for pool.needsContainer(stack) {
+			handle, err := pool.containerFactory.Create(stack, nil)
+			if err != nil {
+				pool.logger.Errord(
+					map[string]interface{}{
+						"stack": stack,
+						"error": err.Error(),
+					}, "container-pool.create.failed",
+				)
+				return
+			}
+
+			pool.lock.Lock()
+			pool.idle[stack] = append(pool.idle[stack], handle)
+			pool.lock.Unlock()
+		}
+	}
+}
+
+func (pool *ContainerPool) needsContainer(stack string) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.draining || len(pool.idle[stack]) >= pool.size {
+		return false
+	}
+
+	if pool.taskRegistry.TotalCapacity().Containers == 0 {
+		return true
+	}
+
+	idle := 0
+	for _, handles := range pool.idle {
+		idle += len(handles)
+	}
+
+	return idle < pool.taskRegistry.AvailableCapacity().Containers
+}
+
+func (pool *ContainerPool) destroy(handle string) {
+	_, err := pool.wardenClient.Destroy(handle)
+	if err != nil {
+		pool.logger.Errord(
+			map[string]interface{}{
+				"handle": handle,
+				"error":  err.Error(),
+			}, "container-pool.destroy.failed",
+		)
+	}
+}
@@ -0,0 +1,13 @@
+package containerpool_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestContainerPool(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ContainerPool Suite")
+}
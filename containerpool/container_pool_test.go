@@ -0,0 +1,190 @@
+package containerpool_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	. "github.com/cloudfoundry-incubator/executor/containerpool"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var _ = Describe("ContainerPool", func() {
+	var pool *ContainerPool
+	var gordon *fake_gordon.FakeGordon
+	var taskRegistry *taskregistry.TaskRegistry
+	var size int
+
+	BeforeEach(func() {
+		gordon = fake_gordon.New()
+		registryFileName := fmt.Sprintf("/tmp/executor_registry_pool_%d", config.GinkgoConfig.ParallelNode)
+		taskRegistry = taskregistry.NewTaskRegistry(registryFileName, 1024, 1024)
+		size = 2
+	})
+
+	JustBeforeEach(func() {
+		stacks := containerfactory.NewStacks("penguin", map[string]string{"lucid64": "/rootfs/lucid64"})
+		pool = New(stacks.Names(), size, containerfactory.New(gordon, "executor-", stacks), gordon, taskRegistry, 10*time.Millisecond, steno.NewLogger("test-logger"))
+		pool.Start()
+	})
+
+	AfterEach(func() {
+		pool.Drain()
+	})
+
+	It("creates idle containers for each stack", func() {
+		Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+		Ω(gordon.CreatedHandles()).Should(HaveLen(4))
+	})
+
+	It("creates the containers with the factory's handle prefix", func() {
+		Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+
+		for _, container := range gordon.CreatedContainers() {
+			Ω(container.Handle).Should(ContainSubstring("executor-"))
+		}
+	})
+
+	It("creates each stack's containers with its rootfs", func() {
+		Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+
+		rootfses := map[string]int{}
+		for _, container := range gordon.CreatedContainers() {
+			rootfses[container.RootFSPath]++
+		}
+
+		Ω(rootfses).Should(Equal(map[string]int{"": 2, "/rootfs/lucid64": 2}))
+	})
+
+	Describe("ContainerHandles", func() {
+		JustBeforeEach(func() {
+			Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+		})
+
+		It("returns the handles of the idle containers", func() {
+			taken, _ := pool.Take("penguin")
+
+			handles := pool.ContainerHandles()
+			Ω(handles).Should(HaveLen(3))
+			Ω(handles).ShouldNot(ContainElement(taken))
+		})
+	})
+
+	Describe("Take", func() {
+		JustBeforeEach(func() {
+			Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+		})
+
+		It("hands out an idle container for the stack", func() {
+			handle, found := pool.Take("lucid64")
+			Ω(found).Should(BeTrue())
+			Ω(gordon.CreatedHandles()).Should(ContainElement(handle))
+
+			_, found = pool.Take("lucid64")
+			Ω(found).Should(BeTrue())
+		})
+
+		It("hands out a container for the first stack when none is asked for", func() {
+			_, found := pool.Take("")
+			Ω(found).Should(BeTrue())
+
+			Ω(pool.Idle()["penguin"]).Should(Equal(1))
+		})
+
+		It("hands out nothing for a stack it does not hold", func() {
+			_, found := pool.Take("windows")
+			Ω(found).Should(BeFalse())
+		})
+
+		It("refills the pool", func() {
+			pool.Take("penguin")
+
+			Eventually(func() int { return len(gordon.CreatedHandles()) }).Should(Equal(5))
+			Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+		})
+	})
+
+	Context("when the registry limits containers", func() {
+		BeforeEach(func() {
+			registryFileName := fmt.Sprintf("/tmp/executor_registry_pool_%d", config.GinkgoConfig.ParallelNode)
+			taskRegistry = taskregistry.NewTaskRegistryWithPolicy(registryFileName, taskregistry.Capacity{
+				MemoryMB:   1024,
+				DiskMB:     1024,
+				Containers: 3,
+			}, taskregistry.DefaultCapacityPolicy)
+
+			err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "a guid"})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("holds no more idle containers than the registry has room for", func() {
+			Eventually(func() int { return len(gordon.CreatedHandles()) }).Should(Equal(2))
+			Consistently(func() int { return len(gordon.CreatedHandles()) }, 0.05).Should(Equal(2))
+		})
+
+		It("tops up once room is freed", func() {
+			Eventually(func() int { return len(gordon.CreatedHandles()) }).Should(Equal(2))
+
+			taskRegistry.RemoveRunOnce(models.RunOnce{Guid: "a guid"})
+
+			Eventually(func() int { return len(gordon.CreatedHandles()) }).Should(Equal(3))
+		})
+	})
+
+	Context("when the pool has no size", func() {
+		BeforeEach(func() {
+			size = 0
+		})
+
+		It("creates no containers", func() {
+			Consistently(func() int { return len(gordon.CreatedHandles()) }, 0.05).Should(Equal(0))
+
+			_, found := pool.Take("penguin")
+			Ω(found).Should(BeFalse())
+		})
+	})
+
+	Context("when containers cannot be created", func() {
+		BeforeEach(func() {
+			gordon.CreateError = errors.New("no more cgroups")
+		})
+
+		It("leaves the pool empty", func() {
+			Consistently(pool.Idle, 0.05).Should(Equal(map[string]int{"penguin": 0, "lucid64": 0}))
+		})
+	})
+
+	Describe("Drain", func() {
+		JustBeforeEach(func() {
+			Eventually(pool.Idle).Should(Equal(map[string]int{"penguin": 2, "lucid64": 2}))
+		})
+
+		It("destroys the idle containers", func() {
+			taken, _ := pool.Take("penguin")
+
+			pool.Drain()
+
+			Ω(gordon.DestroyedHandles()).Should(HaveLen(len(gordon.CreatedHandles()) - 1))
+			Ω(gordon.DestroyedHandles()).ShouldNot(ContainElement(taken))
+		})
+
+		It("stops handing out and refilling containers", func() {
+			pool.Drain()
+
+			_, found := pool.Take("penguin")
+			Ω(found).Should(BeFalse())
+
+			created := len(gordon.CreatedHandles())
+			Consistently(func() int { return len(gordon.CreatedHandles()) }, 0.05).Should(Equal(created))
+		})
+	})
+})
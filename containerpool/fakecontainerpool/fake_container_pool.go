@@ -0,0 +1,35 @@
+package fakecontainerpool
+
+import (
+	"sync"
+)
+
+type FakeContainerPool struct {
+	IdleHandles map[string][]string
+	TakenStacks []string
+
+	lock *sync.Mutex
+}
+
+func New() *FakeContainerPool {
+	return &FakeContainerPool{
+		IdleHandles: make(map[string][]string),
+		lock:        &sync.Mutex{},
+	}
+}
+
+func (fakePool *FakeContainerPool) Take(stack string) (string, bool) {
+	fakePool.lock.Lock()
+	defer fakePool.lock.Unlock()
+
+	fakePool.TakenStacks = append(fakePool.TakenStacks, stack)
+
+	idle := fakePool.IdleHandles[stack]
+	if len(idle) == 0 {
+		return "", false
+	}
+
+	fakePool.IdleHandles[stack] = idle[1:]
+
+	return idle[0], true
+}
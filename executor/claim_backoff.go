@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+// the longest a full executor waits before trying to claim a RunOnce
+const maxClaimBackoff = 500 * time.Millisecond
+
+// a little randomness, so that equally loaded executors don't all claim at once
+const claimBackoffJitter = 10 * time.Millisecond
+
+// Load is how full an executor is, from 0 (idle) to 1 (full): the largest of
+// the fractions of its memory, disk, cpu weight and containers in use, and of
+// its workers busy.
+func Load(total taskregistry.Capacity, available taskregistry.Capacity, inFlight int, maxInFlight int) float64 {
+	load := 0.0
+
+	for _, resourceLoad := range []float64{
+		usedFraction(total.MemoryMB, available.MemoryMB),
+		usedFraction(total.DiskMB, available.DiskMB),
+		usedFraction(total.CpuWeight, available.CpuWeight),
+		usedFraction(total.Containers, available.Containers),
+	} {
+		if resourceLoad > load {
+			load = resourceLoad
+		}
+	}
+
+	if maxInFlight > 0 {
+		inFlightLoad := float64(inFlight) / float64(maxInFlight)
+		if inFlightLoad > load {
+			load = inFlightLoad
+		}
+	}
+
+	if load > 1 {
+		load = 1
+	}
+
+	return load
+}
+
+// ClaimBackoff is how long an executor with the given load waits before
+// claiming a RunOnce, so that emptier executors tend to win the race.
+func ClaimBackoff(load float64) time.Duration {
+	backoff := time.Duration(load * float64(maxClaimBackoff))
+	jitter := time.Duration(rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(int64(claimBackoffJitter)))
+
+	return backoff + jitter
+}
+
+func (e *Executor) backOffBeforeClaiming() {
+	load := Load(
+		e.taskRegistry.TotalCapacity(),
+		e.taskRegistry.AvailableCapacity(),
+		int(atomic.LoadInt32(&e.runOncesInFlight)),
+		e.runOnceQueue.Stats().Workers,
+	)
+
+	time.Sleep(ClaimBackoff(load))
+}
+
+func usedFraction(total int, available int) float64 {
+	if total <= 0 {
+		return 0
+	}
+
+	return float64(total-available) / float64(total)
+}
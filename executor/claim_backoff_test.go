@@ -0,0 +1,52 @@
+package executor_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry-incubator/executor/executor"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var _ = Describe("Claim backoff", func() {
+	total := taskregistry.Capacity{MemoryMB: 1024, DiskMB: 4096}
+
+	Describe("Load", func() {
+		It("is 0 for an idle executor", func() {
+			Ω(Load(total, total, 0, 10)).Should(BeNumerically("==", 0))
+		})
+
+		It("is the largest of the memory, disk and workers in use", func() {
+			available := taskregistry.Capacity{MemoryMB: 768, DiskMB: 2048}
+
+			Ω(Load(total, available, 0, 10)).Should(BeNumerically("==", 0.5))
+			Ω(Load(total, available, 8, 10)).Should(BeNumerically("==", 0.8))
+		})
+
+		It("counts cpu weight and containers when they are limited", func() {
+			limited := taskregistry.Capacity{MemoryMB: 1024, DiskMB: 4096, CpuWeight: 100, Containers: 4}
+
+			Ω(Load(limited, taskregistry.Capacity{MemoryMB: 1024, DiskMB: 4096, CpuWeight: 30, Containers: 3}, 0, 10)).Should(BeNumerically("==", 0.7))
+			Ω(Load(limited, taskregistry.Capacity{MemoryMB: 1024, DiskMB: 4096, CpuWeight: 100, Containers: 1}, 0, 10)).Should(BeNumerically("==", 0.75))
+		})
+
+		It("is 1 for a full executor", func() {
+			Ω(Load(total, taskregistry.Capacity{}, 0, 10)).Should(BeNumerically("==", 1))
+			Ω(Load(total, total, 10, 10)).Should(BeNumerically("==", 1))
+		})
+	})
+
+	Describe("ClaimBackoff", func() {
+		It("claims almost immediately when idle", func() {
+			Ω(ClaimBackoff(0)).Should(BeNumerically("<", 10*time.Millisecond))
+		})
+
+		It("backs off more the fuller the executor is", func() {
+			Ω(ClaimBackoff(0.5)).Should(BeNumerically(">=", 250*time.Millisecond))
+			Ω(ClaimBackoff(1)).Should(BeNumerically(">=", 500*time.Millisecond))
+			Ω(ClaimBackoff(1)).Should(BeNumerically("<", 510*time.Millisecond))
+		})
+	})
+})
@@ -1,27 +1,54 @@
 package executor
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/outbox"
 	"github.com/cloudfoundry-incubator/executor/runoncehandler"
+	"github.com/cloudfoundry-incubator/executor/runoncequeue"
 	"github.com/cloudfoundry-incubator/executor/taskregistry"
-	"math/rand"
 
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
 	"github.com/nu7hatch/gouuid"
-	"github.com/vito/gordon"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
 )
 
+const presenceCheckInterval = 500 * time.Millisecond
+
+const (
+	DefaultMaxConcurrentRunOnces = 10
+	DefaultMaxQueuedRunOnces     = 100
+)
+
 type Executor struct {
-	id string
+	id        string
+	stacks    []string
+	version   string
+	startedAt time.Time
 
 	bbs          Bbs.ExecutorBBS
 	wardenClient gordon.Client
 
+	runOnceHandler       runoncehandler.RunOnceHandlerInterface
+	runOnceQueue         *runoncequeue.RunOnceQueue
 	runOnceGroup         *sync.WaitGroup
+	runOncesInFlight     int32
+	runOncesQueued       map[string]bool
+	runOncesCancelled    map[string]bool
+	runOncesQueuedLock   *sync.Mutex
 	stopHandlingRunOnces chan bool
+	stopHandlingOnce     *sync.Once
 
 	stopMaintainingPresence chan bool
 
@@ -30,28 +57,254 @@ type Executor struct {
 	taskRegistry *taskregistry.TaskRegistry
 }
 
-func New(bbs Bbs.ExecutorBBS, logger *steno.Logger) *Executor {
-	uuid, err := uuid.NewV4()
-	if err != nil {
-		panic("Failed to generate a random guid....:" + err.Error())
-	}
+var ErrorExecutorIDInUse = errors.New("another executor is maintaining presence with this executor ID")
 
+func New(bbs Bbs.ExecutorBBS, wardenClient gordon.Client, taskRegistry *taskregistry.TaskRegistry, logger *steno.Logger) *Executor {
+	runOnceQueue := runoncequeue.New(DefaultMaxConcurrentRunOnces, DefaultMaxQueuedRunOnces, taskRegistry)
+	return NewWithID(GenerateID(), []string{}, "", bbs, wardenClient, taskRegistry, runOnceQueue, logger)
+}
+
+//NewWithID returns an Executor with a known ID, e.g. one that was kept across
+//restarts with LoadOrCreateID, so that it can pick its claimed RunOnces back up.
+//Desired RunOnces are handled by runOnceQueue's workers.  The stacks and version
+//are advertised in the executor's presence; the first stack is the default.
+func NewWithID(id string, stacks []string, version string, bbs Bbs.ExecutorBBS, wardenClient gordon.Client, taskRegistry *taskregistry.TaskRegistry, runOnceQueue *runoncequeue.RunOnceQueue, logger *steno.Logger) *Executor {
 	return &Executor{
-		id: uuid.String(),
+		id:        id,
+		stacks:    stacks,
+		version:   version,
+		startedAt: time.Now(),
+
+		bbs:              bbs,
+		wardenClient:     wardenClient,
+		runOnceQueue:     runOnceQueue,
+		runOnceGroup:     &sync.WaitGroup{},
+		stopHandlingOnce: &sync.Once{},
 
-		bbs:          bbs,
-		runOnceGroup: &sync.WaitGroup{},
+		runOncesQueued:     map[string]bool{},
+		runOncesCancelled:  map[string]bool{},
+		runOncesQueuedLock: &sync.Mutex{},
 
 		logger: logger,
+
+		taskRegistry: taskRegistry,
+	}
+}
+
+//LoadOrCreateID reads the executor ID kept in idFile.  If there is no such
+//file, a new ID is generated and written to it.
+func LoadOrCreateID(idFile string) (string, error) {
+	contents, err := ioutil.ReadFile(idFile)
+	if err == nil {
+		id := strings.TrimSpace(string(contents))
+		if id == "" {
+			return "", fmt.Errorf("executor ID file %s is empty", idFile)
+		}
+
+		return id, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	id := GenerateID()
+
+	err = ioutil.WriteFile(idFile, []byte(id), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+//GenerateID returns a new, random executor ID
+func GenerateID() string {
+	uuid, err := uuid.NewV4()
+	if err != nil {
+		panic("Failed to generate a random guid....:" + err.Error())
 	}
+
+	return uuid.String()
 }
 
 func (e *Executor) ID() string {
 	return e.id
 }
 
+// StartedAt returns when the executor was created.
+func (e *Executor) StartedAt() time.Time {
+	return e.startedAt
+}
+
+// Reconcile brings the task registry (typically just loaded from a snapshot)
+// in line with the BBS and warden, and should be run on startup before handling.
+// RunOnces that were completed or are no longer desired just release their
+// capacity, as do RunOnces this executor never claimed under its current ID,
+// since they may be running on another executor.  Claimed RunOnces whose
+// container and process survived are resumed with runOnceHandler; the others
+// are completed as failed through completions.
+// Containers created by containerFactory that no remaining RunOnce owns, and
+// that are not retained for debugging, are destroyed.  Retained containers
+// that are gone are forgotten.  RunOnces whose completions are still waiting
+// in the outbox are left for it to deliver.
+func (e *Executor) Reconcile(runOnceHandler runoncehandler.RunOnceHandlerInterface, completions outbox.OutboxInterface, containerFactory *containerfactory.ContainerFactory) error {
+	pendingRunOnces, err := e.bbs.GetAllPendingRunOnces()
+	if err != nil {
+		return err
+	}
+
+	completedRunOnces, err := e.bbs.GetAllCompletedRunOnces()
+	if err != nil {
+		return err
+	}
+
+	listResponse, err := e.wardenClient.List()
+	if err != nil {
+		return err
+	}
+
+	pending := map[string]bool{}
+	for _, runOnce := range pendingRunOnces {
+		pending[runOnce.Guid] = true
+	}
+
+	completed := map[string]bool{}
+	for _, runOnce := range completedRunOnces {
+		completed[runOnce.Guid] = true
+	}
+
+	containers := map[string]bool{}
+	for _, handle := range listResponse.GetHandles() {
+		containers[handle] = true
+	}
+
+	runOncesToResume := []models.RunOnce{}
+	processes := map[string]taskregistry.Process{}
+
+	for _, runOnce := range e.taskRegistry.RegisteredRunOnces() {
+		if completed[runOnce.Guid] || !pending[runOnce.Guid] || completions.IsPending(runOnce.Guid) {
+			e.logger.Infod(map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+			}, "executor.reconcile.releasing-run-once")
+
+			e.taskRegistry.RemoveRunOnce(runOnce)
+			continue
+		}
+
+		if !e.claimedByThisExecutor(runOnce) {
+			e.logger.Infod(map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+				"executor-id":  runOnce.ExecutorID,
+			}, "executor.reconcile.releasing-unclaimed-run-once")
+
+			e.taskRegistry.RemoveRunOnce(runOnce)
+			continue
+		}
+
+		process, hasProcess := e.taskRegistry.Process(runOnce.Guid)
+		if hasProcess && containers[runOnce.ContainerHandle] {
+			runOncesToResume = append(runOncesToResume, runOnce)
+			processes[runOnce.Guid] = process
+			continue
+		}
+
+		runOnce.Failed = true
+		runOnce.FailureReason = "executor restarted before the run once completed"
+
+		err := completions.Complete(runOnce)
+		if err != nil {
+			e.logger.Errord(map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+				"error":        err.Error(),
+			}, "executor.reconcile.complete-run-once.failed")
+		} else {
+			e.logger.Infod(map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+			}, "executor.reconcile.failed-run-once")
+		}
+
+		e.taskRegistry.RemoveRunOnce(runOnce)
+	}
+
+	for _, retainedContainer := range e.taskRegistry.ListRetainedContainers() {
+		if !containers[retainedContainer.ContainerHandle] {
+			e.logger.Infod(map[string]interface{}{
+				"runonce-guid": retainedContainer.RunOnceGuid,
+				"handle":       retainedContainer.ContainerHandle,
+			}, "executor.reconcile.forgetting-retained-container")
+
+			e.taskRegistry.ReleaseRetainedContainer(retainedContainer.RunOnceGuid)
+		}
+	}
+
+	e.destroyUnownedContainers(containerFactory, listResponse.GetHandles(), runOncesToResume)
+
+	e.runOnceHandler = runOnceHandler
+
+	for _, runOnce := range runOncesToResume {
+		process := processes[runOnce.Guid]
+
+		e.runOnceGroup.Add(1)
+
+		go func(runOnce models.RunOnce) {
+			runOnceHandler.Resume(runOnce, process)
+			e.runOnceGroup.Done()
+		}(runOnce)
+	}
+
+	return nil
+}
+
+// claimedByThisExecutor reports whether the registered RunOnce got as far as
+// being claimed, by this executor
+func (e *Executor) claimedByThisExecutor(runOnce models.RunOnce) bool {
+	if runOnce.ExecutorID != e.id {
+		return false
+	}
+
+	lifecycle, registered := e.taskRegistry.RunOnceLifecycle(runOnce.Guid)
+	return registered && lifecycle.State != taskregistry.StateRegistered
+}
+
+func (e *Executor) destroyUnownedContainers(containerFactory *containerfactory.ContainerFactory, handles []string, owners []models.RunOnce) {
+	owned := map[string]bool{}
+	for _, runOnce := range owners {
+		owned[runOnce.ContainerHandle] = true
+	}
+
+	for _, retainedContainer := range e.taskRegistry.ListRetainedContainers() {
+		owned[retainedContainer.ContainerHandle] = true
+	}
+
+	for _, handle := range handles {
+		if owned[handle] || !containerFactory.Owns(handle) {
+			continue
+		}
+
+		e.logger.Infod(map[string]interface{}{
+			"handle": handle,
+		}, "executor.reconcile.destroying-container")
+
+		_, err := e.wardenClient.Destroy(handle)
+		if err != nil {
+			e.logger.Errord(map[string]interface{}{
+				"handle": handle,
+				"error":  err.Error(),
+			}, "executor.reconcile.destroy-container.failed")
+		}
+	}
+}
+
 func (e *Executor) MaintainPresence(heartbeatInterval time.Duration) error {
-	presence, maintainingPresenceErrors, err := e.bbs.MaintainExecutorPresence(heartbeatInterval, e.ID())
+	err := e.waitForStalePresenceToExpire(heartbeatInterval)
+	if err != nil {
+		return err
+	}
+
+	executorPresence := e.executorPresence()
+
+	presence, maintainingPresenceErrors, err := e.bbs.MaintainExecutorPresence(heartbeatInterval, executorPresence)
 	if err != nil {
 		return err
 	}
@@ -59,13 +312,23 @@ func (e *Executor) MaintainPresence(heartbeatInterval time.Duration) error {
 	stop := make(chan bool)
 
 	go func() {
-		select {
-		case <-stop:
-			presence.Remove()
+		ticker := time.NewTicker(presenceRefreshInterval)
+		defer ticker.Stop()
 
-		case <-maintainingPresenceErrors:
-			e.logger.Error("executor.maintaining-presence.failed")
-			close(e.stopHandlingRunOnces)
+		for {
+			select {
+			case <-ticker.C:
+				executorPresence = e.refreshPresence(presence, executorPresence)
+
+			case <-stop:
+				presence.Remove()
+				return
+
+			case <-maintainingPresenceErrors:
+				e.logger.Error("executor.maintaining-presence.failed")
+				e.stopHandlingNewRunOnces()
+				return
+			}
 		}
 	}()
 
@@ -74,10 +337,60 @@ func (e *Executor) MaintainPresence(heartbeatInterval time.Duration) error {
 	return nil
 }
 
+// an executor that restarts with the same ID may find its old presence still
+// around; it will expire within a heartbeat interval.  if it doesn't, some other
+// live executor is maintaining it.
+func (e *Executor) waitForStalePresenceToExpire(heartbeatInterval time.Duration) error {
+	deadline := time.Now().Add(heartbeatInterval + presenceCheckInterval)
+
+	for {
+		present, err := e.bbs.IsExecutorPresent(e.ID())
+		if err != nil {
+			return err
+		}
+
+		if !present {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			e.logger.Errord(map[string]interface{}{
+				"executor-id": e.ID(),
+			}, "executor.maintaining-presence.id-in-use")
+
+			return ErrorExecutorIDInUse
+		}
+
+		time.Sleep(presenceCheckInterval)
+	}
+}
+
 func (e *Executor) Handle(runOnceHandler runoncehandler.RunOnceHandlerInterface) error {
 	ready := make(chan bool)
+	e.runOnceHandler = runOnceHandler
 	e.stopHandlingRunOnces = make(chan bool)
 
+	e.runOnceQueue.Start(func(runOnce models.RunOnce) {
+		defer e.runOnceGroup.Done()
+		defer e.forgetQueuedRunOnce(runOnce)
+
+		if e.isHandlingStopped() || e.wasCancelledWhileQueued(runOnce) {
+			return
+		}
+
+		e.backOffBeforeClaiming()
+
+		// handling may have been stopped while backing off
+		if e.isHandlingStopped() || e.wasCancelledWhileQueued(runOnce) {
+			return
+		}
+
+		atomic.AddInt32(&e.runOncesInFlight, 1)
+		defer atomic.AddInt32(&e.runOncesInFlight, -1)
+
+		runOnceHandler.RunOnce(runOnce, e.id)
+	})
+
 	go func() {
 		runOnces, stop, errors := e.bbs.WatchForDesiredRunOnce()
 		ready <- true
@@ -91,13 +404,7 @@ func (e *Executor) Handle(runOnceHandler runoncehandler.RunOnceHandlerInterface)
 						return
 					}
 
-					e.runOnceGroup.Add(1)
-
-					go func() {
-						e.sleepForARandomInterval()
-						runOnceHandler.RunOnce(runOnce, e.id)
-						e.runOnceGroup.Done()
-					}()
+					e.enqueueRunOnce(runOnce)
 				case <-e.stopHandlingRunOnces:
 					stop <- true
 					return
@@ -110,25 +417,209 @@ func (e *Executor) Handle(runOnceHandler runoncehandler.RunOnceHandlerInterface)
 		}
 	}()
 
+	go e.handleCancelledRunOnces(runOnceHandler)
+	go e.reexamineUnclaimedRunOnces()
+
 	<-ready
 	return nil
 }
 
+// enqueueRunOnce queues a desired RunOnce, unless it is already queued or
+// being handled by this executor.
+func (e *Executor) enqueueRunOnce(runOnce models.RunOnce) {
+	e.runOncesQueuedLock.Lock()
+	defer e.runOncesQueuedLock.Unlock()
+
+	if e.runOncesQueued[runOnce.Guid] {
+		return
+	}
+
+	e.runOnceGroup.Add(1)
+
+	err := e.runOnceQueue.Enqueue(runOnce)
+	if err == nil {
+		e.runOncesQueued[runOnce.Guid] = true
+	} else {
+		e.runOnceGroup.Done()
+
+		e.logger.Debugd(map[string]interface{}{
+			"runonce-guid": runOnce.Guid,
+			"error":        err.Error(),
+		}, "executor.runonce.rejected")
+	}
+}
+
+func (e *Executor) forgetQueuedRunOnce(runOnce models.RunOnce) {
+	e.runOncesQueuedLock.Lock()
+	defer e.runOncesQueuedLock.Unlock()
+
+	delete(e.runOncesQueued, runOnce.Guid)
+	delete(e.runOncesCancelled, runOnce.Guid)
+}
+
+// cancelQueuedRunOnce marks a RunOnce that is waiting in the queue as
+// cancelled, so that it is dropped instead of being claimed.
+func (e *Executor) cancelQueuedRunOnce(guid string) {
+	e.runOncesQueuedLock.Lock()
+	defer e.runOncesQueuedLock.Unlock()
+
+	if e.runOncesQueued[guid] {
+		e.runOncesCancelled[guid] = true
+	}
+}
+
+func (e *Executor) wasCancelledWhileQueued(runOnce models.RunOnce) bool {
+	e.runOncesQueuedLock.Lock()
+	defer e.runOncesQueuedLock.Unlock()
+
+	if !e.runOncesCancelled[runOnce.Guid] {
+		return false
+	}
+
+	e.logger.Infod(map[string]interface{}{
+		"runonce-guid": runOnce.Guid,
+	}, "executor.runonce.cancelled-while-queued")
+
+	return true
+}
+
+// reexamineUnclaimedRunOnces gives RunOnces that were turned away for lack of
+// capacity another chance whenever capacity frees up, rather than leaving them
+// pending until convergence times them out.
+func (e *Executor) reexamineUnclaimedRunOnces() {
+	for {
+		select {
+		case <-e.taskRegistry.CapacityFreed():
+			runOnces, err := e.bbs.GetAllUnclaimedRunOnces()
+			if err != nil {
+				e.logger.Errord(map[string]interface{}{
+					"error": err.Error(),
+				}, "executor.reexamine-unclaimed-run-onces.failed")
+				continue
+			}
+
+			for _, runOnce := range runOnces {
+				e.enqueueRunOnce(runOnce)
+			}
+
+		case <-e.stopHandlingRunOnces:
+			return
+		}
+	}
+}
+
+// handleCancelledRunOnces interrupts RunOnces that are cancelled in the BBS
+// (e.g. by the stager) while they are being handled, and drops those that are
+// still waiting in the queue.
+func (e *Executor) handleCancelledRunOnces(runOnceHandler runoncehandler.RunOnceHandlerInterface) {
+	runOnces, stop, errors := e.bbs.WatchForCancelledRunOnce()
+
+	for {
+	INNER:
+		for {
+			select {
+			case runOnce, ok := <-runOnces:
+				if !ok {
+					return
+				}
+
+				e.cancelQueuedRunOnce(runOnce.Guid)
+
+				go func() {
+					if runOnceHandler.CancelRunOnce(runOnce.Guid) {
+						e.logger.Infod(map[string]interface{}{
+							"runonce-guid": runOnce.Guid,
+						}, "executor.runonce.cancelled")
+					}
+				}()
+			case <-e.stopHandlingRunOnces:
+				if stop != nil {
+					stop <- true
+				}
+				return
+			case <-errors:
+				break INNER
+			}
+		}
+
+		runOnces, stop, errors = e.bbs.WatchForCancelledRunOnce()
+	}
+}
+
 //StopHandlingRunOnces is used mainly in test to avoid having multiple executors
 //running concurrently from polluting the tests
 func (e *Executor) StopHandling() {
+	e.stopMaintainingPresenceAndHandling()
+
+	//wait for any running runOnce goroutines to end
+	e.runOnceGroup.Wait()
+}
+
+//Drain stops picking up new RunOnces and withdraws the executor's presence, then
+//gives the RunOnces in flight up to drainTimeout to finish.  Whatever is still
+//running after that is cancelled, which still runs its cleanups; claimed
+//RunOnces are completed as failed through the outbox.
+func (e *Executor) Drain(drainTimeout time.Duration) {
+	e.stopMaintainingPresenceAndHandling()
+
+	finished := make(chan bool)
+	go func() {
+		e.runOnceGroup.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return
+	case <-time.After(drainTimeout):
+	}
+
+	e.logger.Infod(map[string]interface{}{
+		"timeout": drainTimeout.String(),
+	}, "executor.drain.timed-out")
+
+	if e.runOnceHandler != nil {
+		e.runOnceHandler.Cancel()
+	}
+
+	<-finished
+}
+
+func (e *Executor) stopMaintainingPresenceAndHandling() {
 	// stop maintaining our presence
 	if e.stopMaintainingPresence != nil {
 		close(e.stopMaintainingPresence)
+		e.stopMaintainingPresence = nil
 	}
 
 	//tell the watcher to stop
-	if e.stopHandlingRunOnces != nil {
-		close(e.stopHandlingRunOnces)
+	e.stopHandlingNewRunOnces()
+}
+
+func (e *Executor) stopHandlingNewRunOnces() {
+	if e.stopHandlingRunOnces == nil {
+		return
 	}
 
-	//wait for any running runOnce goroutines to end
-	e.runOnceGroup.Wait()
+	e.stopHandlingOnce.Do(func() {
+		close(e.stopHandlingRunOnces)
+
+		// RunOnces still waiting in the queue were never claimed; leave them
+		// for another executor
+		for _, runOnce := range e.runOnceQueue.Stop() {
+			e.forgetQueuedRunOnce(runOnce)
+			e.runOnceGroup.Done()
+		}
+	})
+}
+
+func (e *Executor) isHandlingStopped() bool {
+	select {
+	case <-e.stopHandlingRunOnces:
+		return true
+	default:
+		return false
+	}
 }
 
 func (e *Executor) ConvergeRunOnces(period time.Duration, timeToClaim time.Duration) chan<- bool {
@@ -171,8 +662,3 @@ func (e *Executor) ConvergeRunOnces(period time.Duration, timeToClaim time.Durat
 
 	return stopChannel
 }
-
-func (e *Executor) sleepForARandomInterval() {
-	interval := rand.New(rand.NewSource(time.Now().UnixNano())).Intn(100)
-	time.Sleep(time.Duration(interval) * time.Millisecond)
-}
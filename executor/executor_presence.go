@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+)
+
+// how often the executor checks whether what it advertises in its presence
+// (e.g. its available capacity) has changed
+const presenceRefreshInterval = 1 * time.Second
+
+func (e *Executor) executorPresence() models.ExecutorPresence {
+	total := e.taskRegistry.TotalCapacity()
+	available := e.taskRegistry.AvailableCapacity()
+
+	defaultStack := ""
+	if len(e.stacks) > 0 {
+		defaultStack = e.stacks[0]
+	}
+
+	return models.ExecutorPresence{
+		ExecutorID: e.id,
+		Stack:      defaultStack,
+		Stacks:     e.stacks,
+		Version:    e.version,
+		StartedAt:  e.startedAt.UnixNano(),
+
+		TotalMemoryMB:     total.MemoryMB,
+		AvailableMemoryMB: available.MemoryMB,
+		TotalDiskMB:       total.DiskMB,
+		AvailableDiskMB:   available.DiskMB,
+
+		RunOncesInFlight: int(atomic.LoadInt32(&e.runOncesInFlight)),
+	}
+}
+
+// refreshPresence writes the executor's presence if it differs from what was
+// last advertised, and returns what is now advertised.
+func (e *Executor) refreshPresence(presence Bbs.PresenceInterface, advertised models.ExecutorPresence) models.ExecutorPresence {
+	executorPresence := e.executorPresence()
+	if reflect.DeepEqual(executorPresence, advertised) {
+		return advertised
+	}
+
+	err := presence.Update(executorPresence.ToJSON())
+	if err != nil {
+		e.logger.Errord(map[string]interface{}{
+			"error": err.Error(),
+		}, "executor.maintaining-presence.update-failed")
+
+		return advertised
+	}
+
+	return executorPresence
+}
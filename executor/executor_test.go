@@ -1,21 +1,27 @@
 package executor_test
 
 import (
+	"errors"
 	"fmt"
 	steno "github.com/cloudfoundry/gosteno"
 	"github.com/cloudfoundry/storeadapter"
 	"github.com/onsi/ginkgo/config"
+	"io/ioutil"
+	"os"
 	"time"
 
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
 	. "github.com/cloudfoundry-incubator/executor/executor"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs/fakebbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/outbox/fakeoutbox"
 	"github.com/cloudfoundry-incubator/executor/runoncehandler/fakerunoncehandler"
+	"github.com/cloudfoundry-incubator/executor/runoncequeue"
 	"github.com/cloudfoundry-incubator/executor/taskregistry"
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fakebbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/vito/gordon/fake_gordon"
 )
 
 var _ = Describe("Executor", func() {
@@ -32,9 +38,11 @@ var _ = Describe("Executor", func() {
 	)
 
 	var fakeRunOnceHandler *fakerunoncehandler.FakeRunOnceHandler
+	var fakeOutbox *fakeoutbox.FakeOutbox
 
 	BeforeEach(func() {
 		fakeRunOnceHandler = fakerunoncehandler.New()
+		fakeOutbox = fakeoutbox.New()
 
 		registryFileName = fmt.Sprintf("/tmp/executor_registry_%d", config.GinkgoConfig.ParallelNode)
 
@@ -51,19 +59,56 @@ var _ = Describe("Executor", func() {
 			DiskMB:   1024,
 		}
 
-		executor = New(bbs, steno.NewLogger("test-logger"))
+		executor = New(bbs, gordon, taskRegistry, steno.NewLogger("test-logger"))
 	})
 
 	Describe("Executor IDs", func() {
 		It("should generate a random ID when created", func() {
-			executor1 := New(bbs, steno.NewLogger("test-logger"))
-			executor2 := New(bbs, steno.NewLogger("test-logger"))
+			executor1 := New(bbs, gordon, taskRegistry, steno.NewLogger("test-logger"))
+			executor2 := New(bbs, gordon, taskRegistry, steno.NewLogger("test-logger"))
 
 			Ω(executor1.ID()).ShouldNot(BeZero())
 			Ω(executor2.ID()).ShouldNot(BeZero())
 
 			Ω(executor1.ID()).ShouldNot(Equal(executor2.ID()))
 		})
+
+		It("should use the given ID when created with one", func() {
+			runOnceQueue := runoncequeue.New(DefaultMaxConcurrentRunOnces, DefaultMaxQueuedRunOnces, taskRegistry)
+			executor := NewWithID("some-executor-id", []string{"some-stack", "some-other-stack"}, "some-version", bbs, gordon, taskRegistry, runOnceQueue, steno.NewLogger("test-logger"))
+			Ω(executor.ID()).Should(Equal("some-executor-id"))
+		})
+
+		Describe("LoadOrCreateID", func() {
+			var idFileName string
+
+			BeforeEach(func() {
+				idFileName = fmt.Sprintf("/tmp/executor_id_%d", config.GinkgoConfig.ParallelNode)
+				os.Remove(idFileName)
+			})
+
+			AfterEach(func() {
+				os.Remove(idFileName)
+			})
+
+			It("should generate an ID and keep it in the file", func() {
+				id, err := LoadOrCreateID(idFileName)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(id).ShouldNot(BeZero())
+
+				reloadedID, err := LoadOrCreateID(idFileName)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(reloadedID).Should(Equal(id))
+			})
+
+			It("should return an error when the file is empty", func() {
+				err := ioutil.WriteFile(idFileName, []byte{}, 0644)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				_, err = LoadOrCreateID(idFileName)
+				Ω(err).Should(HaveOccurred())
+			})
+		})
 	})
 
 	Describe("Handling", func() {
@@ -108,13 +153,164 @@ var _ = Describe("Executor", func() {
 			})
 		})
 
+		Context("when told to stop handling while backing off before claiming", func() {
+			BeforeEach(func() {
+				// nearly fill the registry, so that the executor backs off for a while
+				err := taskRegistry.AddRunOnce(models.RunOnce{
+					Guid:     "big-run-once",
+					MemoryMB: startingMemory - 1,
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				executor.Handle(fakeRunOnceHandler)
+
+				err = bbs.DesireRunOnce(models.RunOnce{Guid: "small-run-once", MemoryMB: 1})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				time.Sleep(100 * time.Millisecond)
+
+				executor.StopHandling()
+			})
+
+			It("does not handle the RunOnce", func() {
+				Ω(fakeRunOnceHandler.NumberOfCalls()).Should(Equal(0))
+			})
+		})
+
+		Context("when a desired RunOnce does not fit in the registry", func() {
+			BeforeEach(func() {
+				executor.Handle(fakeRunOnceHandler)
+
+				runOnce.MemoryMB = startingMemory + 1
+
+				err := bbs.DesireRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				executor.StopHandling()
+			})
+
+			It("should reject it without handling it", func() {
+				Consistently(func() int {
+					return fakeRunOnceHandler.NumberOfCalls()
+				}).Should(Equal(0))
+			})
+		})
+
+		Context("when a desired RunOnce is rejected and capacity later frees up", func() {
+			var otherRunOnce models.RunOnce
+
+			BeforeEach(func() {
+				otherRunOnce = models.RunOnce{
+					Guid:     "some-other-guid",
+					MemoryMB: startingMemory,
+				}
+
+				err := taskRegistry.AddRunOnce(otherRunOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				executor.Handle(fakeRunOnceHandler)
+
+				err = bbs.DesireRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Consistently(func() int {
+					return fakeRunOnceHandler.NumberOfCalls()
+				}).Should(Equal(0))
+			})
+
+			AfterEach(func() {
+				executor.StopHandling()
+			})
+
+			It("should handle the RunOnce once it fits", func() {
+				taskRegistry.RemoveRunOnce(otherRunOnce)
+
+				Eventually(func() int {
+					return fakeRunOnceHandler.NumberOfCalls()
+				}).Should(Equal(1))
+			})
+		})
+
+		Context("when a RunOnce is cancelled", func() {
+			BeforeEach(func() {
+				executor.Handle(fakeRunOnceHandler)
+
+				err := bbs.DesireRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				err = bbs.CancelRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				executor.StopHandling()
+			})
+
+			It("should cancel the RunOnce", func() {
+				Eventually(func() []string {
+					fakeRunOnceHandler.Lock.Lock()
+					defer fakeRunOnceHandler.Lock.Unlock()
+
+					return fakeRunOnceHandler.CancelledRunOnces
+				}).Should(ContainElement(runOnce.Guid))
+			})
+		})
+
+		Context("when a RunOnce is cancelled while it is queued", func() {
+			var queuedRunOnce models.RunOnce
+
+			BeforeEach(func() {
+				fakeRunOnceHandler.BlockUntilCancelled = true
+
+				runOnceQueue := runoncequeue.New(1, 10, taskRegistry)
+				executor = NewWithID("some-executor-id", []string{}, "", bbs, gordon, taskRegistry, runOnceQueue, steno.NewLogger("test-logger"))
+				executor.Handle(fakeRunOnceHandler)
+
+				// keep the only worker busy, so that the next RunOnce waits in the queue
+				err := bbs.DesireRunOnce(models.RunOnce{Guid: "busy-run-once"})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(fakeRunOnceHandler.NumberOfCalls).Should(Equal(1))
+
+				queuedRunOnce = models.RunOnce{Guid: "queued-run-once"}
+
+				err = bbs.DesireRunOnce(queuedRunOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				err = bbs.CancelRunOnce(queuedRunOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(func() []string {
+					fakeRunOnceHandler.Lock.Lock()
+					defer fakeRunOnceHandler.Lock.Unlock()
+
+					return fakeRunOnceHandler.CancelledRunOnces
+				}).Should(ContainElement(queuedRunOnce.Guid))
+			})
+
+			AfterEach(func() {
+				executor.StopHandling()
+			})
+
+			It("should never run it", func() {
+				// let the busy RunOnce finish, freeing the worker
+				fakeRunOnceHandler.Cancel()
+
+				Consistently(func() map[string]string {
+					return fakeRunOnceHandler.HandledRunOnces()
+				}).ShouldNot(HaveKey(queuedRunOnce.Guid))
+			})
+		})
+
 		Context("when two executors are fighting for a RunOnce", func() {
 			var otherExecutor *Executor
 
 			BeforeEach(func() {
 				executor.Handle(fakeRunOnceHandler)
 
-				otherExecutor = New(bbs, steno.NewLogger("test-logger"))
+				otherExecutor = New(bbs, gordon, taskRegistry, steno.NewLogger("test-logger"))
 				otherExecutor.Handle(fakeRunOnceHandler)
 			})
 
@@ -155,6 +351,54 @@ var _ = Describe("Executor", func() {
 		})
 	})
 
+	Describe("Draining", func() {
+		Context("when there are no RunOnces in flight", func() {
+			BeforeEach(func() {
+				err := executor.MaintainPresence(60 * time.Second)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				executor.Handle(fakeRunOnceHandler)
+				executor.Drain(time.Second)
+			})
+
+			It("withdraws the executor's presence", func() {
+				Eventually(func() interface{} {
+					arr, _ := bbs.GetAllExecutors()
+					return arr
+				}).Should(BeEmpty())
+			})
+
+			It("does not handle any new desired RunOnces", func() {
+				err := bbs.DesireRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Consistently(func() int {
+					return fakeRunOnceHandler.NumberOfCalls()
+				}).Should(Equal(0))
+			})
+		})
+
+		Context("when a RunOnce does not finish before the timeout", func() {
+			BeforeEach(func() {
+				fakeRunOnceHandler.BlockUntilCancelled = true
+
+				executor.Handle(fakeRunOnceHandler)
+
+				err := bbs.DesireRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(func() int {
+					return fakeRunOnceHandler.NumberOfCalls()
+				}).Should(Equal(1))
+			})
+
+			It("cancels the RunOnces in flight and waits for them", func() {
+				executor.Drain(100 * time.Millisecond)
+				Ω(fakeRunOnceHandler.WasCancelled()).Should(BeTrue())
+			})
+		})
+	})
+
 	Describe("Maintaining Presence", func() {
 		It("should maintain presence", func() {
 			err := executor.MaintainPresence(60 * time.Second)
@@ -170,6 +414,66 @@ var _ = Describe("Executor", func() {
 			Ω(executors[0]).Should(Equal(executor.ID()))
 		})
 
+		It("should advertise its capacity, stacks and version", func() {
+			runOnceQueue := runoncequeue.New(DefaultMaxConcurrentRunOnces, DefaultMaxQueuedRunOnces, taskRegistry)
+			executor = NewWithID("some-executor-id", []string{"some-stack", "some-other-stack"}, "some-version", bbs, gordon, taskRegistry, runOnceQueue, steno.NewLogger("test-logger"))
+
+			err := executor.MaintainPresence(60 * time.Second)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			node, err := etcdRunner.Adapter().Get("/v1/executor/some-executor-id")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			executorPresence, err := models.NewExecutorPresenceFromJSON(node.Value)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(executorPresence.ExecutorID).Should(Equal("some-executor-id"))
+			Ω(executorPresence.Stack).Should(Equal("some-stack"))
+			Ω(executorPresence.Stacks).Should(Equal([]string{"some-stack", "some-other-stack"}))
+			Ω(executorPresence.Version).Should(Equal("some-version"))
+			Ω(executorPresence.StartedAt).ShouldNot(BeZero())
+			Ω(executorPresence.TotalMemoryMB).Should(Equal(startingMemory))
+			Ω(executorPresence.AvailableMemoryMB).Should(Equal(startingMemory))
+			Ω(executorPresence.TotalDiskMB).Should(Equal(startingDisk))
+			Ω(executorPresence.AvailableDiskMB).Should(Equal(startingDisk))
+			Ω(executorPresence.RunOncesInFlight).Should(BeZero())
+		})
+
+		Context("when the executor's capacity changes", func() {
+			var fakeExecutorBBS *fakebbs.FakeExecutorBBS
+
+			BeforeEach(func() {
+				fakeExecutorBBS = &fakebbs.FakeExecutorBBS{}
+				bbs.ExecutorBBS = fakeExecutorBBS
+
+				err := executor.MaintainPresence(60 * time.Second)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should refresh its presence", func() {
+				runOnce.MemoryMB = 64
+				runOnce.DiskMB = 128
+				err := taskRegistry.AddRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(func() int {
+					return len(fakeExecutorBBS.MaintainingPresencePresence.Updates())
+				}, 3).Should(Equal(1))
+
+				executorPresence, err := models.NewExecutorPresenceFromJSON(fakeExecutorBBS.MaintainingPresencePresence.Updates()[0])
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(executorPresence.AvailableMemoryMB).Should(Equal(startingMemory - 64))
+				Ω(executorPresence.AvailableDiskMB).Should(Equal(startingDisk - 128))
+			})
+
+			It("should not refresh its presence when nothing has changed", func() {
+				Consistently(func() int {
+					return len(fakeExecutorBBS.MaintainingPresencePresence.Updates())
+				}, 2).Should(BeZero())
+			})
+		})
+
 		Context("when maintaining presence fails to start", func() {
 			BeforeEach(func() {
 				etcdRunner.Stop()
@@ -185,6 +489,35 @@ var _ = Describe("Executor", func() {
 			})
 		})
 
+		Context("when the executor's ID is already present", func() {
+			var fakeExecutorBBS *fakebbs.FakeExecutorBBS
+
+			BeforeEach(func() {
+				fakeExecutorBBS = &fakebbs.FakeExecutorBBS{}
+				fakeExecutorBBS.ExecutorPresent = true
+				bbs.ExecutorBBS = fakeExecutorBBS
+			})
+
+			It("should return an error if the presence does not expire within the heartbeat interval", func() {
+				err := executor.MaintainPresence(1 * time.Second)
+				Ω(err).Should(Equal(ErrorExecutorIDInUse))
+
+				Ω(fakeExecutorBBS.IsExecutorPresentChecks).Should(BeNumerically(">", 1))
+				Ω(fakeExecutorBBS.MaintainingPresencePresence).Should(BeNil())
+			})
+
+			Context("and checking for it fails", func() {
+				BeforeEach(func() {
+					fakeExecutorBBS.IsExecutorPresentErr = errors.New("oh no!")
+				})
+
+				It("should return the error", func() {
+					err := executor.MaintainPresence(1 * time.Second)
+					Ω(err).Should(Equal(fakeExecutorBBS.IsExecutorPresentErr))
+				})
+			})
+		})
+
 		Context("when we fail to maintain our presence", func() {
 			BeforeEach(func() {
 				executor.Handle(fakeRunOnceHandler)
@@ -210,6 +543,268 @@ var _ = Describe("Executor", func() {
 		})
 	})
 
+	Describe("Reconciling", func() {
+		var (
+			fakeExecutorBBS  *fakebbs.FakeExecutorBBS
+			containerFactory *containerfactory.ContainerFactory
+			ownedHandle      string
+			unownedHandle    string
+			foreignHandle    string
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			fakeExecutorBBS = &fakebbs.FakeExecutorBBS{}
+			bbs.ExecutorBBS = fakeExecutorBBS
+
+			containerFactory = containerfactory.New(gordon, "executor-", containerfactory.NewStacks("lucid64", nil))
+
+			ownedHandle, err = containerFactory.Create("", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			unownedHandle, err = containerFactory.Create("", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			createResponse, err := gordon.Create()
+			Ω(err).ShouldNot(HaveOccurred())
+			foreignHandle = createResponse.GetHandle()
+
+			runOnce.MemoryMB = 64
+			runOnce.DiskMB = 64
+			runOnce.ContainerHandle = ownedHandle
+			runOnce.ExecutorID = executor.ID()
+			err = taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			taskRegistry.SetRunOnceState(runOnce.Guid, taskregistry.StateClaimed)
+		})
+
+		Context("when the RunOnce has already been completed", func() {
+			BeforeEach(func() {
+				fakeExecutorBBS.PendingRunOnces = []models.RunOnce{runOnce}
+				fakeExecutorBBS.AlreadyCompletedRunOnces = []models.RunOnce{runOnce}
+			})
+
+			It("releases the RunOnce's capacity without completing it again", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(taskRegistry.RunOnces).Should(BeEmpty())
+				Ω(fakeOutbox.CompletedRunOnces).Should(BeEmpty())
+			})
+		})
+
+		Context("when the RunOnce is no longer desired", func() {
+			It("releases the RunOnce's capacity without completing it", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(taskRegistry.RunOnces).Should(BeEmpty())
+				Ω(fakeOutbox.CompletedRunOnces).Should(BeEmpty())
+			})
+		})
+
+		Context("when the RunOnce is still desired", func() {
+			BeforeEach(func() {
+				fakeExecutorBBS.PendingRunOnces = []models.RunOnce{runOnce}
+			})
+
+			It("completes the RunOnce as failed and releases its capacity", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(fakeOutbox.CompletedRunOnces).Should(HaveLen(1))
+				Ω(fakeOutbox.CompletedRunOnces[0].Guid).Should(Equal(runOnce.Guid))
+				Ω(fakeOutbox.CompletedRunOnces[0].Failed).Should(BeTrue())
+				Ω(fakeOutbox.CompletedRunOnces[0].FailureReason).ShouldNot(BeEmpty())
+				Ω(fakeExecutorBBS.CompletedRunOnces).Should(BeEmpty())
+
+				Ω(taskRegistry.RunOnces).Should(BeEmpty())
+			})
+
+			Context("and completing it fails", func() {
+				var otherRunOnce models.RunOnce
+
+				BeforeEach(func() {
+					fakeOutbox.CompleteErr = errors.New("oh no!")
+
+					otherRunOnce = models.RunOnce{Guid: "other-guid", MemoryMB: 64, DiskMB: 64, ExecutorID: executor.ID()}
+					err := taskRegistry.AddRunOnce(otherRunOnce)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					taskRegistry.SetRunOnceState(otherRunOnce.Guid, taskregistry.StateClaimed)
+
+					fakeExecutorBBS.PendingRunOnces = []models.RunOnce{runOnce, otherRunOnce}
+				})
+
+				It("keeps reconciling the other RunOnces", func() {
+					err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(fakeOutbox.CompletedRunOnces).Should(HaveLen(2))
+					Ω(taskRegistry.RunOnces).Should(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the RunOnce was registered but never claimed", func() {
+			BeforeEach(func() {
+				runOnce.ExecutorID = ""
+				taskRegistry.UpdateRunOnce(runOnce)
+				taskRegistry.SetRunOnceState(runOnce.Guid, taskregistry.StateRegistered)
+
+				fakeExecutorBBS.PendingRunOnces = []models.RunOnce{runOnce}
+			})
+
+			It("releases the RunOnce's capacity without completing it", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(taskRegistry.RunOnces).Should(BeEmpty())
+				Ω(fakeOutbox.CompletedRunOnces).Should(BeEmpty())
+			})
+		})
+
+		Context("when the RunOnce's completion is still waiting in the outbox", func() {
+			BeforeEach(func() {
+				fakeExecutorBBS.PendingRunOnces = []models.RunOnce{runOnce}
+				fakeOutbox.PendingRunOnceGuids[runOnce.Guid] = true
+			})
+
+			It("releases the RunOnce's capacity and leaves completing it to the outbox", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(taskRegistry.RunOnces).Should(BeEmpty())
+				Ω(fakeOutbox.CompletedRunOnces).Should(BeEmpty())
+			})
+		})
+
+		Context("when the RunOnce is still desired and its process is still running", func() {
+			BeforeEach(func() {
+				fakeExecutorBBS.PendingRunOnces = []models.RunOnce{runOnce}
+				taskRegistry.RecordProcess(runOnce.Guid, taskregistry.Process{ActionIndex: 1, ProcessID: 42})
+			})
+
+			It("resumes the RunOnce", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(fakeRunOnceHandler.ResumedRunOnces).Should(HaveKey(runOnce.Guid))
+				Ω(fakeRunOnceHandler.ResumedRunOnces()[runOnce.Guid]).Should(Equal(taskregistry.Process{ActionIndex: 1, ProcessID: 42}))
+
+				Ω(fakeOutbox.CompletedRunOnces).Should(BeEmpty())
+				Ω(taskRegistry.RunOnces).Should(HaveLen(1))
+			})
+
+			It("keeps the RunOnce's container and destroys the others", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(gordon.DestroyedHandles()).Should(Equal([]string{unownedHandle}))
+			})
+
+			Context("but its container is gone", func() {
+				BeforeEach(func() {
+					gordon.Destroy(ownedHandle)
+				})
+
+				It("completes the RunOnce as failed instead", func() {
+					err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Ω(fakeOutbox.CompletedRunOnces).Should(HaveLen(1))
+					Ω(fakeOutbox.CompletedRunOnces[0].Failed).Should(BeTrue())
+					Ω(fakeRunOnceHandler.ResumedRunOnces()).Should(BeEmpty())
+				})
+			})
+
+			Context("but it was claimed under another executor ID", func() {
+				BeforeEach(func() {
+					runOnce.ExecutorID = "some-previous-executor-id"
+					taskRegistry.UpdateRunOnce(runOnce)
+				})
+
+				It("releases the RunOnce without resuming or completing it", func() {
+					err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Consistently(fakeRunOnceHandler.ResumedRunOnces).Should(BeEmpty())
+					Ω(fakeOutbox.CompletedRunOnces).Should(BeEmpty())
+					Ω(taskRegistry.RunOnces).Should(BeEmpty())
+				})
+			})
+		})
+
+		It("destroys containers that are not owned by a RunOnce", func() {
+			err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(gordon.DestroyedHandles()).Should(ContainElement(unownedHandle))
+			Ω(gordon.DestroyedHandles()).Should(ContainElement(ownedHandle))
+		})
+
+		It("leaves containers that this executor did not create alone", func() {
+			err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(gordon.DestroyedHandles()).ShouldNot(ContainElement(foreignHandle))
+		})
+
+		Context("when a failed RunOnce's container is retained", func() {
+			BeforeEach(func() {
+				taskRegistry.RetainContainer(models.RunOnce{Guid: "failed-guid", ContainerHandle: unownedHandle}, time.Hour)
+				taskRegistry.RetainContainer(models.RunOnce{Guid: "gone-guid", ContainerHandle: "gone-handle"}, time.Hour)
+			})
+
+			It("keeps the container", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(gordon.DestroyedHandles()).ShouldNot(ContainElement(unownedHandle))
+
+				_, retained := taskRegistry.RetainedContainer("failed-guid")
+				Ω(retained).Should(BeTrue())
+			})
+
+			It("forgets retained containers that are gone", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				_, retained := taskRegistry.RetainedContainer("gone-guid")
+				Ω(retained).Should(BeFalse())
+			})
+		})
+
+		Context("when fetching the pending RunOnces fails", func() {
+			BeforeEach(func() {
+				fakeExecutorBBS.GetAllPendingRunOncesErr = errors.New("oh no!")
+			})
+
+			It("returns the error and leaves the registry alone", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).Should(Equal(fakeExecutorBBS.GetAllPendingRunOncesErr))
+
+				Ω(taskRegistry.RunOnces).Should(HaveLen(1))
+				Ω(gordon.DestroyedHandles()).Should(BeEmpty())
+			})
+		})
+
+		Context("when listing containers fails", func() {
+			BeforeEach(func() {
+				gordon.ListError = errors.New("oh no!")
+			})
+
+			It("returns the error and leaves the registry alone", func() {
+				err := executor.Reconcile(fakeRunOnceHandler, fakeOutbox, containerFactory)
+				Ω(err).Should(Equal(gordon.ListError))
+
+				Ω(taskRegistry.RunOnces).Should(HaveLen(1))
+			})
+		})
+	})
+
 	Describe("Converging RunOnces", func() {
 		var fakeExecutorBBS *fakebbs.FakeExecutorBBS
 		BeforeEach(func() {
@@ -3,7 +3,7 @@ package gordon
 import (
 	"time"
 
-	"github.com/vito/gordon/connection"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/connection"
 	"github.com/vito/gordon/warden"
 )
 
@@ -11,6 +11,7 @@ type Client interface {
 	Connect() error
 
 	Create() (*warden.CreateResponse, error)
+	CreateContainer(spec ContainerSpec) (*warden.CreateResponse, error)
 	Stop(handle string, background, kill bool) (*warden.StopResponse, error)
 	Destroy(handle string) (*warden.DestroyResponse, error)
 	Run(handle, script string) (uint32, <-chan *warden.ProcessPayload, error)
@@ -20,6 +21,7 @@ type Client interface {
 	GetMemoryLimit(handle string) (uint64, error)
 	LimitDisk(handle string, limit uint64) (*warden.LimitDiskResponse, error)
 	GetDiskLimit(handle string) (uint64, error)
+	LimitCpu(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error)
 	List() (*warden.ListResponse, error)
 	Info(handle string) (*warden.InfoResponse, error)
 	CopyIn(handle, src, dst string) (*warden.CopyInResponse, error)
@@ -56,6 +58,13 @@ func (c *client) Create() (*warden.CreateResponse, error) {
 	return conn.Create()
 }
 
+func (c *client) CreateContainer(spec ContainerSpec) (*warden.CreateResponse, error) {
+	conn := c.acquireConnection()
+	defer c.release(conn)
+
+	return conn.CreateContainer(spec.CreateRequest())
+}
+
 func (c *client) Stop(handle string, background, kill bool) (*warden.StopResponse, error) {
 	conn := c.acquireConnection()
 	defer c.release(conn)
@@ -150,6 +159,13 @@ func (c *client) GetDiskLimit(handle string) (uint64, error) {
 	return conn.GetDiskLimit(handle)
 }
 
+func (c *client) LimitCpu(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error) {
+	conn := c.acquireConnection()
+	defer c.release(conn)
+
+	return conn.LimitCpu(handle, limitInShares)
+}
+
 func (c *client) List() (*warden.ListResponse, error) {
 	conn := c.acquireConnection()
 	defer c.release(conn)
@@ -5,9 +5,9 @@ import (
 	"errors"
 	"runtime"
 
+	. "github.com/cloudfoundry-incubator/executor/forks/gordon"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/vito/gordon"
 
 	"code.google.com/p/gogoprotobuf/proto"
 	"github.com/vito/gordon/warden"
@@ -93,6 +93,72 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("Creating a container from a spec", func() {
+		BeforeEach(func() {
+			provider = NewFakeConnectionProvider(
+				warden.Messages(
+					&warden.CreateResponse{Handle: proto.String("some-handle")},
+					&warden.CreateResponse{Handle: proto.String("some-handle")},
+					&warden.CreateResponse{Handle: proto.String("some-handle")},
+				),
+				writeBuffer,
+			)
+
+			client = NewClient(provider)
+			err := client.Connect()
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should send the spec's handle", func() {
+			res, err := client.CreateContainer(ContainerSpec{Handle: "some-handle"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.GetHandle()).Should(Equal("some-handle"))
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(string(warden.Messages(
+				&warden.CreateRequest{Handle: proto.String("some-handle")},
+			).Bytes())))
+		})
+
+		It("should send the spec's rootfs", func() {
+			_, err := client.CreateContainer(ContainerSpec{Handle: "some-handle", RootFSPath: "/some/rootfs"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(string(warden.Messages(
+				&warden.CreateRequest{
+					Handle: proto.String("some-handle"),
+					Rootfs: proto.String("/some/rootfs"),
+				},
+			).Bytes())))
+		})
+
+		It("should send the spec's bind mounts", func() {
+			_, err := client.CreateContainer(ContainerSpec{
+				BindMounts: []BindMount{
+					{SrcPath: "/host/rw", DstPath: "/container/rw"},
+					{SrcPath: "/host/ro", DstPath: "/container/ro", ReadOnly: true},
+				},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(string(writeBuffer.Bytes())).Should(Equal(string(warden.Messages(
+				&warden.CreateRequest{
+					BindMounts: []*warden.CreateRequest_BindMount{
+						{
+							SrcPath: proto.String("/host/rw"),
+							DstPath: proto.String("/container/rw"),
+							Mode:    warden.CreateRequest_BindMount_RW.Enum(),
+						},
+						{
+							SrcPath: proto.String("/host/ro"),
+							DstPath: proto.String("/container/ro"),
+							Mode:    warden.CreateRequest_BindMount_RO.Enum(),
+						},
+					},
+				},
+			).Bytes())))
+		})
+	})
+
 	Describe("Running", func() {
 		BeforeEach(func() {
 			provider = NewFakeConnectionProvider(
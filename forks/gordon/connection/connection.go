@@ -70,7 +70,11 @@ func (c *Connection) Close() {
 }
 
 func (c *Connection) Create() (*warden.CreateResponse, error) {
-	res, err := c.RoundTrip(&warden.CreateRequest{}, &warden.CreateResponse{})
+	return c.CreateContainer(&warden.CreateRequest{})
+}
+
+func (c *Connection) CreateContainer(request *warden.CreateRequest) (*warden.CreateResponse, error) {
+	res, err := c.RoundTrip(request, &warden.CreateResponse{})
 	if err != nil {
 		return nil, err
 	}
@@ -267,6 +271,22 @@ func (c *Connection) GetDiskLimit(handle string) (uint64, error) {
 	return res.(*warden.LimitDiskResponse).GetByteLimit(), nil
 }
 
+func (c *Connection) LimitCpu(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error) {
+	res, err := c.RoundTrip(
+		&warden.LimitCpuRequest{
+			Handle:        proto.String(handle),
+			LimitInShares: proto.Uint64(limitInShares),
+		},
+		&warden.LimitCpuResponse{},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*warden.LimitCpuResponse), nil
+}
+
 func (c *Connection) CopyIn(handle, src, dst string) (*warden.CopyInResponse, error) {
 	res, err := c.RoundTrip(
 		&warden.CopyInRequest{
@@ -2,9 +2,9 @@ package connection_test
 
 import (
 	"bytes"
+	. "github.com/cloudfoundry-incubator/executor/forks/gordon/connection"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	. "github.com/vito/gordon/connection"
 	"math"
 	"time"
 
@@ -55,6 +55,18 @@ var _ = Describe("Connection", func() {
 
 			assertWriteBufferContains(&warden.CreateRequest{})
 		})
+
+		It("should create a container from the given request", func() {
+			resp, err := connection.CreateContainer(&warden.CreateRequest{
+				Handle: proto.String("foohandle"),
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(resp.GetHandle()).Should(Equal("foohandle"))
+
+			assertWriteBufferContains(&warden.CreateRequest{
+				Handle: proto.String("foohandle"),
+			})
+		})
 	})
 
 	Describe("Stopping", func() {
@@ -191,6 +203,25 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("Limiting CPU", func() {
+		BeforeEach(func() {
+			wardenMessages = append(wardenMessages,
+				&warden.LimitCpuResponse{LimitInShares: proto.Uint64(40)},
+			)
+		})
+
+		It("should limit the cpu shares", func() {
+			res, err := connection.LimitCpu("foo", 42)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(res.GetLimitInShares()).Should(BeNumerically("==", 40))
+
+			assertWriteBufferContains(&warden.LimitCpuRequest{
+				Handle:        proto.String("foo"),
+				LimitInShares: proto.Uint64(42),
+			})
+		})
+	})
+
 	Describe("NetIn", func() {
 		BeforeEach(func() {
 			wardenMessages = append(wardenMessages,
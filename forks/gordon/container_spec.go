@@ -0,0 +1,49 @@
+package gordon
+
+import (
+	"code.google.com/p/gogoprotobuf/proto"
+
+	"github.com/vito/gordon/warden"
+)
+
+// ContainerSpec describes a container to create.  Zero values are left for
+// warden to choose.
+type ContainerSpec struct {
+	Handle     string
+	RootFSPath string
+	BindMounts []BindMount
+}
+
+// BindMount mounts a directory on the warden host into the container.
+type BindMount struct {
+	SrcPath  string
+	DstPath  string
+	ReadOnly bool
+}
+
+func (spec ContainerSpec) CreateRequest() *warden.CreateRequest {
+	request := &warden.CreateRequest{}
+
+	if spec.Handle != "" {
+		request.Handle = proto.String(spec.Handle)
+	}
+
+	if spec.RootFSPath != "" {
+		request.Rootfs = proto.String(spec.RootFSPath)
+	}
+
+	for _, bindMount := range spec.BindMounts {
+		mode := warden.CreateRequest_BindMount_RW
+		if bindMount.ReadOnly {
+			mode = warden.CreateRequest_BindMount_RO
+		}
+
+		request.BindMounts = append(request.BindMounts, &warden.CreateRequest_BindMount{
+			SrcPath: proto.String(bindMount.SrcPath),
+			DstPath: proto.String(bindMount.DstPath),
+			Mode:    mode.Enum(),
+		})
+	}
+
+	return request
+}
@@ -2,6 +2,7 @@ package fake_gordon
 
 import (
 	"code.google.com/p/gogoprotobuf/proto"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
 	"github.com/nu7hatch/gouuid"
 	"github.com/vito/gordon/warden"
 	"io/ioutil"
@@ -13,10 +14,12 @@ type FakeGordon struct {
 	Connected    bool
 	ConnectError error
 
-	createdHandles []string
-	CreateError    error
+	createdHandles    []string
+	createdContainers []gordon.ContainerSpec
+	CreateError       error
 
-	StopError error
+	stoppedContainers []*StoppedContainer
+	StopError         error
 
 	destroyedHandles []string
 	DestroyError     error
@@ -25,21 +28,29 @@ type FakeGordon struct {
 
 	LinkError error
 
-	NetInError error
+	netInHandles []string
+	NetInError   error
 
+	memoryLimits     map[string]uint64
 	LimitMemoryError error
 
 	GetMemoryLimitError error
 
+	diskLimits     map[string]uint64
 	LimitDiskError error
 
 	GetDiskLimitError error
 
+	cpuLimits     map[string]uint64
+	LimitCpuError error
+
 	ListError error
 
 	InfoError error
 
-	AttachError error
+	attachedProcesses              []*AttachedProcess
+	attachReturnProcessPayloadChan <-chan *warden.ProcessPayload
+	AttachError                    error
 
 	scriptsThatRan              []*RunningScript
 	runCallbacks                map[*RunningScript]RunCallback
@@ -59,6 +70,17 @@ type FakeGordon struct {
 
 type RunCallback func() (uint32, <-chan *warden.ProcessPayload, error)
 
+type StoppedContainer struct {
+	Handle     string
+	Background bool
+	Kill       bool
+}
+
+type AttachedProcess struct {
+	Handle    string
+	ProcessID uint32
+}
+
 type RunningScript struct {
 	Handle string
 	Script string
@@ -89,8 +111,10 @@ func (f *FakeGordon) Reset() {
 	f.ConnectError = nil
 
 	f.createdHandles = []string{}
+	f.createdContainers = []gordon.ContainerSpec{}
 	f.CreateError = nil
 
+	f.stoppedContainers = []*StoppedContainer{}
 	f.StopError = nil
 
 	f.destroyedHandles = []string{}
@@ -98,13 +122,20 @@ func (f *FakeGordon) Reset() {
 
 	f.SpawnError = nil
 	f.LinkError = nil
+	f.netInHandles = []string{}
 	f.NetInError = nil
+	f.memoryLimits = make(map[string]uint64)
 	f.LimitMemoryError = nil
 	f.GetMemoryLimitError = nil
+	f.diskLimits = make(map[string]uint64)
 	f.LimitDiskError = nil
 	f.GetDiskLimitError = nil
+	f.cpuLimits = make(map[string]uint64)
+	f.LimitCpuError = nil
 	f.ListError = nil
 	f.InfoError = nil
+	f.attachedProcesses = []*AttachedProcess{}
+	f.attachReturnProcessPayloadChan = nil
 	f.AttachError = nil
 
 	f.scriptsThatRan = make([]*RunningScript, 0)
@@ -127,16 +158,24 @@ func (f *FakeGordon) Connect() error {
 }
 
 func (f *FakeGordon) Create() (*warden.CreateResponse, error) {
+	return f.CreateContainer(gordon.ContainerSpec{})
+}
+
+func (f *FakeGordon) CreateContainer(spec gordon.ContainerSpec) (*warden.CreateResponse, error) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	if f.CreateError != nil {
 		return nil, f.CreateError
 	}
 
-	handleUuid, _ := uuid.NewV4()
-	handle := handleUuid.String()[:11]
+	handle := spec.Handle
+	if handle == "" {
+		handleUuid, _ := uuid.NewV4()
+		handle = handleUuid.String()[:11]
+	}
 
 	f.createdHandles = append(f.createdHandles, handle)
+	f.createdContainers = append(f.createdContainers, spec)
 
 	return &warden.CreateResponse{
 		Handle: proto.String(handle),
@@ -150,9 +189,34 @@ func (f *FakeGordon) CreatedHandles() []string {
 	return f.createdHandles
 }
 
+func (f *FakeGordon) CreatedContainers() []gordon.ContainerSpec {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.createdContainers
+}
+
 func (f *FakeGordon) Stop(handle string, background, kill bool) (*warden.StopResponse, error) {
-	panic("NOOP!")
-	return nil, f.StopError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.StopError != nil {
+		return nil, f.StopError
+	}
+
+	f.stoppedContainers = append(f.stoppedContainers, &StoppedContainer{
+		Handle:     handle,
+		Background: background,
+		Kill:       kill,
+	})
+
+	return &warden.StopResponse{}, nil
+}
+
+func (f *FakeGordon) StoppedContainers() []*StoppedContainer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.stoppedContainers
 }
 
 func (f *FakeGordon) Destroy(handle string) (*warden.DestroyResponse, error) {
@@ -174,39 +238,153 @@ func (f *FakeGordon) DestroyedHandles() []string {
 	return f.destroyedHandles
 }
 
+// NetIn maps the nth port asked for, across all containers, from host port
+// 60000+n to container port 61000+n.
 func (f *FakeGordon) NetIn(handle string) (*warden.NetInResponse, error) {
-	panic("NOOP!")
-	return nil, f.NetInError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.NetInError != nil {
+		return nil, f.NetInError
+	}
+
+	f.netInHandles = append(f.netInHandles, handle)
+	n := uint32(len(f.netInHandles))
+
+	return &warden.NetInResponse{
+		HostPort:      proto.Uint32(60000 + n),
+		ContainerPort: proto.Uint32(61000 + n),
+	}, nil
+}
+
+func (f *FakeGordon) NetInHandles() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.netInHandles
 }
 
 func (f *FakeGordon) LimitMemory(handle string, limit uint64) (*warden.LimitMemoryResponse, error) {
-	panic("NOOP!")
-	return nil, f.LimitMemoryError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.LimitMemoryError != nil {
+		return nil, f.LimitMemoryError
+	}
+
+	f.memoryLimits[handle] = limit
+
+	return &warden.LimitMemoryResponse{
+		LimitInBytes: proto.Uint64(limit),
+	}, nil
+}
+
+func (f *FakeGordon) MemoryLimits() map[string]uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.memoryLimits
 }
 
 func (f *FakeGordon) GetMemoryLimit(handle string) (uint64, error) {
-	panic("NOOP!")
-	return 0, f.GetMemoryLimitError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.GetMemoryLimitError != nil {
+		return 0, f.GetMemoryLimitError
+	}
+
+	return f.memoryLimits[handle], nil
 }
 
 func (f *FakeGordon) LimitDisk(handle string, limit uint64) (*warden.LimitDiskResponse, error) {
-	panic("NOOP!")
-	return nil, f.LimitDiskError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.LimitDiskError != nil {
+		return nil, f.LimitDiskError
+	}
+
+	f.diskLimits[handle] = limit
+
+	return &warden.LimitDiskResponse{
+		ByteLimit: proto.Uint64(limit),
+	}, nil
+}
+
+func (f *FakeGordon) DiskLimits() map[string]uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.diskLimits
 }
 
 func (f *FakeGordon) GetDiskLimit(handle string) (uint64, error) {
-	panic("NOOP!")
-	return 0, f.GetDiskLimitError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.GetDiskLimitError != nil {
+		return 0, f.GetDiskLimitError
+	}
+
+	return f.diskLimits[handle], nil
+}
+
+func (f *FakeGordon) LimitCpu(handle string, limitInShares uint64) (*warden.LimitCpuResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.LimitCpuError != nil {
+		return nil, f.LimitCpuError
+	}
+
+	f.cpuLimits[handle] = limitInShares
+
+	return &warden.LimitCpuResponse{
+		LimitInShares: proto.Uint64(limitInShares),
+	}, nil
+}
+
+func (f *FakeGordon) CpuLimits() map[string]uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.cpuLimits
 }
 
 func (f *FakeGordon) List() (*warden.ListResponse, error) {
-	panic("NOOP!")
-	return nil, f.ListError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.ListError != nil {
+		return nil, f.ListError
+	}
+
+	destroyed := make(map[string]bool)
+	for _, handle := range f.destroyedHandles {
+		destroyed[handle] = true
+	}
+
+	handles := []string{}
+	for _, handle := range f.createdHandles {
+		if !destroyed[handle] {
+			handles = append(handles, handle)
+		}
+	}
+
+	return &warden.ListResponse{
+		Handles: handles,
+	}, nil
 }
 
 func (f *FakeGordon) Info(handle string) (*warden.InfoResponse, error) {
-	panic("NOOP!")
-	return nil, f.InfoError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.InfoError != nil {
+		return nil, f.InfoError
+	}
+
+	return &warden.InfoResponse{}, nil
 }
 
 func (f *FakeGordon) CopyIn(handle, src, dst string) (*warden.CopyInResponse, error) {
@@ -284,8 +462,31 @@ func (f *FakeGordon) SetCopyOutFileContent(data []byte) {
 }
 
 func (f *FakeGordon) Attach(handle string, jobID uint32) (<-chan *warden.ProcessPayload, error) {
-	panic("NOOP!")
-	return nil, f.AttachError
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.attachedProcesses = append(f.attachedProcesses, &AttachedProcess{
+		Handle:    handle,
+		ProcessID: jobID,
+	})
+
+	if f.AttachError != nil {
+		return nil, f.AttachError
+	}
+
+	return f.attachReturnProcessPayloadChan, nil
+}
+
+func (f *FakeGordon) AttachedProcesses() []*AttachedProcess {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.attachedProcesses
+}
+
+func (f *FakeGordon) SetAttachReturnValues(processPayloadChan <-chan *warden.ProcessPayload) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.attachReturnProcessPayloadChan = processPayloadChan
 }
 
 func (f *FakeGordon) ScriptsThatRan() []*RunningScript {
@@ -1,7 +1,7 @@
 package bbs
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 
 	"time"
@@ -14,15 +14,24 @@ const SchemaRoot = "/v1/"
 type ExecutorBBS interface {
 	MaintainExecutorPresence(
 		heartbeatInterval time.Duration,
-		executorID string,
+		executorPresence models.ExecutorPresence,
 	) (presence PresenceInterface, disappeared <-chan bool, err error)
+	IsExecutorPresent(executorID string) (bool, error)
 
 	WatchForDesiredRunOnce() (<-chan models.RunOnce, chan<- bool, <-chan error)
+	WatchForCancelledRunOnce() (<-chan models.RunOnce, chan<- bool, <-chan error)
+
+	IsRunOnceCancelled(guid string) (bool, error)
 
 	ClaimRunOnce(models.RunOnce) error
+	UnclaimRunOnce(models.RunOnce) error
 	StartRunOnce(models.RunOnce) error
 	CompleteRunOnce(models.RunOnce) error
 
+	GetAllPendingRunOnces() ([]models.RunOnce, error)
+	GetAllUnclaimedRunOnces() ([]models.RunOnce, error)
+	GetAllCompletedRunOnces() ([]models.RunOnce, error)
+
 	ConvergeRunOnce(timeToClaim time.Duration)
 	MaintainConvergeLock(interval time.Duration, executorID string) (disappeared <-chan bool, stop chan<- chan bool, err error)
 }
@@ -32,6 +41,7 @@ type StagerBBS interface {
 
 	DesireRunOnce(models.RunOnce) error
 	ResolveRunOnce(models.RunOnce) error
+	CancelRunOnce(models.RunOnce) error
 
 	GetAvailableFileServer() (string, error)
 }
@@ -1,8 +1,8 @@
 package bbs_test
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fakebbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs/fakebbs"
 	"github.com/cloudfoundry/storeadapter"
 	"github.com/onsi/ginkgo/config"
 	"os"
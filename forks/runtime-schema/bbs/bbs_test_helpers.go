@@ -2,14 +2,10 @@ package bbs
 
 import (
 	"fmt"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 )
 
-func (self *BBS) GetAllPendingRunOnces() ([]models.RunOnce, error) {
-	return getAllRunOnces(self.store, "pending")
-}
-
 func (self *BBS) GetAllClaimedRunOnces() ([]models.RunOnce, error) {
 	return getAllRunOnces(self.store, "claimed")
 }
@@ -18,10 +14,6 @@ func (self *BBS) GetAllStartingRunOnces() ([]models.RunOnce, error) {
 	return getAllRunOnces(self.store, "running")
 }
 
-func (self *BBS) GetAllCompletedRunOnces() ([]models.RunOnce, error) {
-	return getAllRunOnces(self.store, "completed")
-}
-
 func (self *BBS) GetAllExecutors() ([]string, error) {
 	nodes, err := self.store.ListRecursively(ExecutorSchemaRoot)
 	if err == storeadapter.ErrorKeyNotFound {
@@ -3,7 +3,7 @@ package bbs
 import (
 	"time"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 )
 
@@ -11,16 +11,50 @@ type executorBBS struct {
 	store storeadapter.StoreAdapter
 }
 
-func (self *executorBBS) MaintainExecutorPresence(heartbeatInterval time.Duration, executorId string) (PresenceInterface, <-chan bool, error) {
-	presence := NewPresence(self.store, executorSchemaPath(executorId), []byte{})
+func (self *executorBBS) MaintainExecutorPresence(heartbeatInterval time.Duration, executorPresence models.ExecutorPresence) (PresenceInterface, <-chan bool, error) {
+	presence := NewPresence(self.store, executorSchemaPath(executorPresence.ExecutorID), executorPresence.ToJSON())
 	lostLock, err := presence.Maintain(heartbeatInterval)
 	return presence, lostLock, err
 }
 
+// The executor calls this on startup to make sure that no other live executor
+// is using its ID
+func (self *executorBBS) IsExecutorPresent(executorId string) (bool, error) {
+	_, err := self.store.Get(executorSchemaPath(executorId))
+	if err == storeadapter.ErrorKeyNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (self *executorBBS) WatchForDesiredRunOnce() (<-chan models.RunOnce, chan<- bool, <-chan error) {
 	return watchForRunOnceModificationsOnState(self.store, "pending")
 }
 
+func (self *executorBBS) WatchForCancelledRunOnce() (<-chan models.RunOnce, chan<- bool, <-chan error) {
+	return watchForRunOnceModificationsOnState(self.store, "cancelled")
+}
+
+// The executor calls this just before claiming a runonce, so that it does not
+// pick up one that was cancelled while it was waiting to be claimed
+func (self *executorBBS) IsRunOnceCancelled(guid string) (bool, error) {
+	_, err := self.store.Get(runOnceSchemaPath("cancelled", guid))
+	if err == storeadapter.ErrorKeyNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 // The executor calls this when it wants to claim a runonce
 // stagerBBS will retry this repeatedly if it gets a StoreTimeout error (up to N seconds?)
 // If this fails, the executor should assume that someone else is handling the claim and should bail
@@ -38,6 +72,30 @@ func (self *executorBBS) ClaimRunOnce(runOnce models.RunOnce) error {
 	})
 }
 
+// The executor calls this when it could not set up a runonce it claimed (e.g. its
+// container could not be created) before running any of its actions
+// This gives up the claim and kicks the pending runonce so that another executor
+// can pick it up
+func (self *executorBBS) UnclaimRunOnce(runOnce models.RunOnce) error {
+	return retryIndefinitelyOnStoreTimeout(func() error {
+		err := self.store.Delete(runOnceSchemaPath("claimed", runOnce.Guid))
+		if err != nil && err != storeadapter.ErrorKeyNotFound {
+			return err
+		}
+
+		pendingNode, err := self.store.Get(runOnceSchemaPath("pending", runOnce.Guid))
+		if err == storeadapter.ErrorKeyNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return self.store.SetMulti([]storeadapter.StoreNode{pendingNode})
+	})
+}
+
 // The executor calls this when it is about to run the runonce in the claimed container
 // stagerBBS will retry this repeatedly if it gets a StoreTimeout error (up to N seconds?)
 // If this fails, the executor should assume that someone else is running and should clean up and bail
@@ -71,11 +129,55 @@ func (self *executorBBS) CompleteRunOnce(runOnce models.RunOnce) error {
 	})
 }
 
+// The executor calls this on startup to find out which of the runonces it was
+// tracking before it went away are still desired
+func (self *executorBBS) GetAllPendingRunOnces() ([]models.RunOnce, error) {
+	return getAllRunOnces(self.store, "pending")
+}
+
+// The executor calls this when it has capacity to spare, to find the runonces
+// that are still waiting for an executor: pending, but not yet claimed,
+// running, completed or cancelled
+func (self *executorBBS) GetAllUnclaimedRunOnces() ([]models.RunOnce, error) {
+	pending, err := getAllRunOnces(self.store, "pending")
+	if err != nil {
+		return nil, err
+	}
+
+	taken := map[string]bool{}
+	for _, state := range []string{"claimed", "running", "completed", "cancelled"} {
+		runOnces, err := getAllRunOnces(self.store, state)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, runOnce := range runOnces {
+			taken[runOnce.Guid] = true
+		}
+	}
+
+	unclaimed := []models.RunOnce{}
+	for _, runOnce := range pending {
+		if !taken[runOnce.Guid] {
+			unclaimed = append(unclaimed, runOnce)
+		}
+	}
+
+	return unclaimed, nil
+}
+
+// The executor calls this on startup to find out which of the runonces it was
+// tracking before it went away have already been completed
+func (self *executorBBS) GetAllCompletedRunOnces() ([]models.RunOnce, error) {
+	return getAllRunOnces(self.store, "completed")
+}
+
 // ConvergeRunOnce is run by *one* executor every X seconds (doesn't really matter what X is.. pick something performant)
 // Converge will:
 // 1. Kick (by setting) any pending for guids that only have a pending
 // 2. Kick (by setting) any completed for guids that have a pending
-// 3. Remove any claimed/running/completed for guids that have no corresponding pending
+// 3. Mark as failed any pending that was cancelled before it was claimed
+// 4. Remove any claimed/running/completed/cancelled for guids that have no corresponding pending
 func (self *executorBBS) ConvergeRunOnce(timeToClaim time.Duration) {
 	runOnceState, err := self.store.ListRecursively(RunOnceSchemaRoot)
 	if err != nil {
@@ -96,6 +198,7 @@ func (self *executorBBS) ConvergeRunOnce(timeToClaim time.Duration) {
 	claimed, _ := runOnceState.Lookup("claimed")
 	running, _ := runOnceState.Lookup("running")
 	completed, _ := runOnceState.Lookup("completed")
+	cancelled, _ := runOnceState.Lookup("cancelled")
 
 	unclaimedTimeoutBoundary := time.Now().Add(-timeToClaim).UnixNano()
 
@@ -126,6 +229,13 @@ func (self *executorBBS) ConvergeRunOnce(timeToClaim time.Duration) {
 			continue
 		}
 
+		_, isCancelled := cancelled.Lookup(guid)
+
+		if isCancelled {
+			storeNodesToSet = append(storeNodesToSet, failedRunOnceNodeFromNode(pendingNode, "cancelled"))
+			continue
+		}
+
 		runOnce, err := models.NewRunOnceFromJSON(pendingNode.Value)
 		if err != nil {
 			pendingNode.Value = nil
@@ -142,7 +252,7 @@ func (self *executorBBS) ConvergeRunOnce(timeToClaim time.Duration) {
 
 	}
 
-	for _, node := range []storeadapter.StoreNode{claimed, running, completed} {
+	for _, node := range []storeadapter.StoreNode{claimed, running, completed, cancelled} {
 		for _, node := range node.ChildNodes {
 			guid := node.KeyComponents()[3]
 
@@ -1,8 +1,8 @@
 package bbs_test
 
 import (
-	. "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 	"path"
 
@@ -27,6 +27,59 @@ var _ = Describe("Executor BBS", func() {
 		}
 	})
 
+	Describe("IsExecutorPresent", func() {
+		Context("when the executor is maintaining its presence", func() {
+			var presence PresenceInterface
+
+			BeforeEach(func() {
+				var err error
+				presence, _, err = bbs.MaintainExecutorPresence(time.Minute, models.ExecutorPresence{ExecutorID: "executor-id"})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				presence.Remove()
+			})
+
+			It("should return true", func() {
+				present, err := bbs.IsExecutorPresent("executor-id")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(present).Should(BeTrue())
+			})
+		})
+
+		Context("when the executor is not present", func() {
+			It("should return false", func() {
+				present, err := bbs.IsExecutorPresent("executor-id")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(present).Should(BeFalse())
+			})
+		})
+	})
+
+	Describe("IsRunOnceCancelled", func() {
+		Context("when the RunOnce has been cancelled", func() {
+			BeforeEach(func() {
+				err := bbs.CancelRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should return true", func() {
+				cancelled, err := bbs.IsRunOnceCancelled(runOnce.Guid)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(cancelled).Should(BeTrue())
+			})
+		})
+
+		Context("when the RunOnce has not been cancelled", func() {
+			It("should return false", func() {
+				cancelled, err := bbs.IsRunOnceCancelled(runOnce.Guid)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(cancelled).Should(BeFalse())
+			})
+		})
+	})
+
 	Describe("ConvergeRunOnce", func() {
 		var otherRunOnce models.RunOnce
 
@@ -119,7 +172,7 @@ var _ = Describe("Executor BBS", func() {
 
 					JustBeforeEach(func() {
 						var err error
-						presence, _, err = bbs.MaintainExecutorPresence(10*time.Second, runOnce.ExecutorID)
+						presence, _, err = bbs.MaintainExecutorPresence(10*time.Second, models.ExecutorPresence{ExecutorID: runOnce.ExecutorID})
 						Ω(err).ShouldNot(HaveOccurred())
 					})
 
@@ -177,7 +230,7 @@ var _ = Describe("Executor BBS", func() {
 
 					JustBeforeEach(func() {
 						var err error
-						presence, _, err = bbs.MaintainExecutorPresence(10*time.Second, runOnce.ExecutorID)
+						presence, _, err = bbs.MaintainExecutorPresence(10*time.Second, models.ExecutorPresence{ExecutorID: runOnce.ExecutorID})
 						Ω(err).ShouldNot(HaveOccurred())
 					})
 
@@ -241,6 +294,34 @@ var _ = Describe("Executor BBS", func() {
 				})
 			})
 
+			Context("and it was cancelled before being claimed", func() {
+				JustBeforeEach(func() {
+					err := bbs.CancelRunOnce(runOnce)
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				It("should not kick the pending key", func(done Done) {
+					events, _, _ := bbs.WatchForDesiredRunOnce()
+
+					bbs.ConvergeRunOnce(timeToClaim)
+
+					bbs.DesireRunOnce(otherRunOnce)
+
+					Ω(<-events).Should(Equal(otherRunOnce))
+
+					close(done)
+				})
+
+				It("should mark it as failed", func() {
+					bbs.ConvergeRunOnce(timeToClaim)
+					completedRunOnces, err := bbs.GetAllCompletedRunOnces()
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(completedRunOnces).Should(HaveLen(1))
+					Ω(completedRunOnces[0].Failed).Should(BeTrue())
+					Ω(completedRunOnces[0].FailureReason).Should(Equal("cancelled"))
+				})
+			})
+
 			Context("and there are no other keys", func() {
 				It("should kick the pending key",
 					func(done Done) {
@@ -265,6 +346,9 @@ var _ = Describe("Executor BBS", func() {
 
 				err = bbs.CompleteRunOnce(runOnce)
 				Ω(err).ShouldNot(HaveOccurred())
+
+				err = bbs.CancelRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
 			})
 
 			It("should delete any extra keys", func() {
@@ -278,10 +362,28 @@ var _ = Describe("Executor BBS", func() {
 
 				_, err = store.Get("/v1/run_once/completed/some-guid")
 				Ω(err).Should(HaveOccurred())
+
+				_, err = store.Get("/v1/run_once/cancelled/some-guid")
+				Ω(err).Should(HaveOccurred())
 			})
 		})
 	})
 
+	Describe("WatchForCancelledRunOnce", func() {
+		It("should send an event down the pipe when a RunOnce is cancelled", func(done Done) {
+			events, stop, _ := bbs.WatchForCancelledRunOnce()
+
+			err := bbs.CancelRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Expect(<-events).To(Equal(runOnce))
+
+			stop <- true
+
+			close(done)
+		})
+	})
+
 	Context("MaintainConvergeLock", func() {
 		Describe("Maintain the converge lock", func() {
 
@@ -1,14 +1,35 @@
 package fakebbs
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 
+	"sync"
 	"time"
 )
 
 type FakePresence struct {
-	Removed bool
+	Removed       bool
+	UpdatedValues [][]byte
+	UpdateError   error
+
+	lock sync.Mutex
+}
+
+func (p *FakePresence) Update(value []byte) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.UpdatedValues = append(p.UpdatedValues, value)
+
+	return p.UpdateError
+}
+
+func (p *FakePresence) Updates() [][]byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.UpdatedValues
 }
 
 func (p *FakePresence) Remove() {
@@ -25,44 +46,92 @@ type FakeExecutorBBS struct {
 	MaintainConvergeLockError   error
 
 	MaintainingPresenceHeartbeatInterval time.Duration
-	MaintainingPresenceExecutorID        string
+	MaintainingPresenceExecutorPresence  models.ExecutorPresence
 	MaintainingPresencePresence          *FakePresence
 	MaintainingPresenceErrorChannel      chan bool
 	MaintainingPresenceError             error
 
+	ExecutorPresent         bool
+	IsExecutorPresentErr    error
+	IsExecutorPresentChecks int
+
+	CancelledRunOnceGuids    []string
+	IsRunOnceCancelledErr    error
+	IsRunOnceCancelledChecks []string
+
 	ClaimedRunOnce  models.RunOnce
 	ClaimRunOnceErr error
 
+	UnclaimedRunOnce  models.RunOnce
+	UnclaimRunOnceErr error
+
 	StartedRunOnce  models.RunOnce
 	StartRunOnceErr error
 
 	CompletedRunOnce           models.RunOnce
+	CompletedRunOnces          []models.RunOnce
 	CompleteRunOnceErr         error
 	ConvergeRunOnceTimeToClaim time.Duration
+
+	PendingRunOnces          []models.RunOnce
+	GetAllPendingRunOncesErr error
+
+	UnclaimedRunOnces            []models.RunOnce
+	GetAllUnclaimedRunOncesErr   error
+	GetAllUnclaimedRunOncesCalls int
+
+	AlreadyCompletedRunOnces   []models.RunOnce
+	GetAllCompletedRunOncesErr error
 }
 
 func NewFakeExecutorBBS() *FakeExecutorBBS {
 	return &FakeExecutorBBS{}
 }
 
-func (fakeBBS *FakeExecutorBBS) MaintainExecutorPresence(heartbeatInterval time.Duration, executorID string) (bbs.PresenceInterface, <-chan bool, error) {
+func (fakeBBS *FakeExecutorBBS) MaintainExecutorPresence(heartbeatInterval time.Duration, executorPresence models.ExecutorPresence) (bbs.PresenceInterface, <-chan bool, error) {
 	fakeBBS.MaintainingPresenceHeartbeatInterval = heartbeatInterval
-	fakeBBS.MaintainingPresenceExecutorID = executorID
+	fakeBBS.MaintainingPresenceExecutorPresence = executorPresence
 	fakeBBS.MaintainingPresencePresence = &FakePresence{}
 	fakeBBS.MaintainingPresenceErrorChannel = make(chan bool)
 
 	return fakeBBS.MaintainingPresencePresence, fakeBBS.MaintainingPresenceErrorChannel, fakeBBS.MaintainingPresenceError
 }
 
+func (fakeBBS *FakeExecutorBBS) IsExecutorPresent(executorID string) (bool, error) {
+	fakeBBS.IsExecutorPresentChecks++
+	return fakeBBS.ExecutorPresent, fakeBBS.IsExecutorPresentErr
+}
+
 func (fakeBBS *FakeExecutorBBS) WatchForDesiredRunOnce() (<-chan models.RunOnce, chan<- bool, <-chan error) {
 	return nil, nil, nil
 }
 
+func (fakeBBS *FakeExecutorBBS) WatchForCancelledRunOnce() (<-chan models.RunOnce, chan<- bool, <-chan error) {
+	return nil, nil, nil
+}
+
+func (fakeBBS *FakeExecutorBBS) IsRunOnceCancelled(guid string) (bool, error) {
+	fakeBBS.IsRunOnceCancelledChecks = append(fakeBBS.IsRunOnceCancelledChecks, guid)
+
+	for _, cancelledGuid := range fakeBBS.CancelledRunOnceGuids {
+		if cancelledGuid == guid {
+			return true, fakeBBS.IsRunOnceCancelledErr
+		}
+	}
+
+	return false, fakeBBS.IsRunOnceCancelledErr
+}
+
 func (fakeBBS *FakeExecutorBBS) ClaimRunOnce(runOnce models.RunOnce) error {
 	fakeBBS.ClaimedRunOnce = runOnce
 	return fakeBBS.ClaimRunOnceErr
 }
 
+func (fakeBBS *FakeExecutorBBS) UnclaimRunOnce(runOnce models.RunOnce) error {
+	fakeBBS.UnclaimedRunOnce = runOnce
+	return fakeBBS.UnclaimRunOnceErr
+}
+
 func (fakeBBS *FakeExecutorBBS) StartRunOnce(runOnce models.RunOnce) error {
 	fakeBBS.StartedRunOnce = runOnce
 	return fakeBBS.StartRunOnceErr
@@ -70,9 +139,23 @@ func (fakeBBS *FakeExecutorBBS) StartRunOnce(runOnce models.RunOnce) error {
 
 func (fakeBBS *FakeExecutorBBS) CompleteRunOnce(runOnce models.RunOnce) error {
 	fakeBBS.CompletedRunOnce = runOnce
+	fakeBBS.CompletedRunOnces = append(fakeBBS.CompletedRunOnces, runOnce)
 	return fakeBBS.CompleteRunOnceErr
 }
 
+func (fakeBBS *FakeExecutorBBS) GetAllPendingRunOnces() ([]models.RunOnce, error) {
+	return fakeBBS.PendingRunOnces, fakeBBS.GetAllPendingRunOncesErr
+}
+
+func (fakeBBS *FakeExecutorBBS) GetAllUnclaimedRunOnces() ([]models.RunOnce, error) {
+	fakeBBS.GetAllUnclaimedRunOncesCalls++
+	return fakeBBS.UnclaimedRunOnces, fakeBBS.GetAllUnclaimedRunOncesErr
+}
+
+func (fakeBBS *FakeExecutorBBS) GetAllCompletedRunOnces() ([]models.RunOnce, error) {
+	return fakeBBS.AlreadyCompletedRunOnces, fakeBBS.GetAllCompletedRunOncesErr
+}
+
 func (fakeBBS *FakeExecutorBBS) ConvergeRunOnce(timeToClaim time.Duration) {
 	fakeBBS.ConvergeRunOnceTimeToClaim = timeToClaim
 	fakeBBS.CallsToConverge++
@@ -90,6 +173,9 @@ type FakeStagerBBS struct {
 	ResolvedRunOnce   models.RunOnce
 	ResolveRunOnceErr error
 
+	CancelledRunOnce models.RunOnce
+	CancelRunOnceErr error
+
 	CalledCompletedRunOnce  chan bool
 	CompletedRunOnceChan    chan models.RunOnce
 	CompletedRunOnceErrChan chan error
@@ -117,6 +203,11 @@ func (fakeBBS *FakeStagerBBS) ResolveRunOnce(runOnce models.RunOnce) error {
 	return fakeBBS.ResolveRunOnceErr
 }
 
+func (fakeBBS *FakeStagerBBS) CancelRunOnce(runOnce models.RunOnce) error {
+	fakeBBS.CancelledRunOnce = runOnce
+	return fakeBBS.CancelRunOnceErr
+}
+
 func (fakeBBS *FakeStagerBBS) GetAvailableFileServer() (string, error) {
 	panic("implement me!")
 }
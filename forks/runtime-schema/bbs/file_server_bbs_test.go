@@ -6,8 +6,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	. "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models/factories"
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models/factories"
 	"github.com/cloudfoundry/storeadapter"
 )
 
@@ -17,7 +17,6 @@ var _ = Describe("File Server BBS", func() {
 		fileServerURL string
 		fileServerId  string
 		interval      time.Duration
-		disappeared   <-chan bool
 		err           error
 		presence      PresenceInterface
 	)
@@ -32,7 +31,7 @@ var _ = Describe("File Server BBS", func() {
 			fileServerId = factories.GenerateGuid()
 			interval = 1 * time.Second
 
-			presence, disappeared, err = bbs.MaintainFileServerPresence(interval, fileServerURL, fileServerId)
+			presence, _, err = bbs.MaintainFileServerPresence(interval, fileServerURL, fileServerId)
 			Ω(err).ShouldNot(HaveOccurred())
 		})
 
@@ -58,7 +57,7 @@ var _ = Describe("File Server BBS", func() {
 				fileServerId = factories.GenerateGuid()
 				interval = 1 * time.Second
 
-				presence, disappeared, err = bbs.MaintainFileServerPresence(interval, fileServerURL, fileServerId)
+				presence, _, err = bbs.MaintainFileServerPresence(interval, fileServerURL, fileServerId)
 				Ω(err).ShouldNot(HaveOccurred())
 			})
 
@@ -80,10 +79,10 @@ var _ = Describe("File Server BBS", func() {
 
 				interval = 1 * time.Second
 
-				presence, disappeared, err = bbs.MaintainFileServerPresence(interval, fileServerURL, fileServerId)
+				presence, _, err = bbs.MaintainFileServerPresence(interval, fileServerURL, fileServerId)
 				Ω(err).ShouldNot(HaveOccurred())
 
-				presence, disappeared, err = bbs.MaintainFileServerPresence(interval, otherFileServerURL, otherFileServerId)
+				presence, _, err = bbs.MaintainFileServerPresence(interval, otherFileServerURL, otherFileServerId)
 				Ω(err).ShouldNot(HaveOccurred())
 			})
 
@@ -0,0 +1,143 @@
+package bbs
+
+import (
+	"errors"
+	"github.com/cloudfoundry/storeadapter"
+	"sync"
+	"time"
+)
+
+type PresenceInterface interface {
+	Update(value []byte) error
+	Remove()
+}
+
+type Presence struct {
+	store   storeadapter.StoreAdapter
+	key     string
+	value   []byte
+	ttl     uint64
+	release chan chan bool
+	lock    *sync.Mutex
+}
+
+func NewPresence(store storeadapter.StoreAdapter, key string, value []byte) *Presence {
+	return &Presence{
+		store: store,
+		key:   key,
+		value: value,
+		lock:  &sync.Mutex{},
+	}
+}
+
+// Maintain creates the presence, waiting for any existing node at its key to
+// go away first, and then keeps refreshing its TTL every half interval.  The
+// returned channel receives if the presence is lost.  Any other error creating
+// the presence is returned straight away.
+func (p *Presence) Maintain(interval time.Duration) (<-chan bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.release != nil {
+		return nil, errors.New("Already maintaining a presence")
+	}
+
+	p.ttl = uint64(interval.Seconds())
+	if p.ttl == 0 {
+		return nil, storeadapter.ErrorInvalidTTL
+	}
+
+	for {
+		err := p.store.Create(p.node())
+		if err == nil {
+			break
+		}
+
+		if err != storeadapter.ErrorKeyExists {
+			return nil, err
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+
+	lost := make(chan bool)
+	release := make(chan chan bool)
+
+	go p.maintain(interval/2, lost, release)
+
+	p.release = release
+
+	return lost, nil
+}
+
+// Update replaces the presence's value.  If the presence is being maintained
+// the new value is written straight away.
+func (p *Presence) Update(value []byte) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.value = value
+
+	if p.release == nil {
+		return nil
+	}
+
+	return p.store.Update(p.node())
+}
+
+func (p *Presence) Remove() {
+	p.lock.Lock()
+	release := p.release
+	p.release = nil
+	p.lock.Unlock()
+
+	if release == nil {
+		return
+	}
+
+	stopFinishedChan := make(chan bool)
+	release <- stopFinishedChan
+
+	<-stopFinishedChan
+}
+
+func (p *Presence) maintain(interval time.Duration, lost chan<- bool, release <-chan chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.lock.Lock()
+			err := p.store.Update(p.node())
+			p.lock.Unlock()
+
+			if err != nil {
+				// stop refreshing, but still wait to be released
+				select {
+				case lost <- true:
+					close(<-release)
+				case released := <-release:
+					close(released)
+				}
+				return
+			}
+
+		case released := <-release:
+			p.lock.Lock()
+			p.store.Delete(p.key)
+			p.lock.Unlock()
+
+			close(released)
+			return
+		}
+	}
+}
+
+func (p *Presence) node() storeadapter.StoreNode {
+	return storeadapter.StoreNode{
+		Key:   p.key,
+		Value: p.value,
+		TTL:   p.ttl,
+	}
+}
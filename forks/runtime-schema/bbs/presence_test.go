@@ -1,8 +1,10 @@
 package bbs_test
 
 import (
-	. "github.com/cloudfoundry-incubator/runtime-schema/bbs"
+	"errors"
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
 	"github.com/cloudfoundry/storeadapter"
+	"github.com/cloudfoundry/storeadapter/fakestoreadapter"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -64,6 +66,41 @@ var _ = Describe("Presence", func() {
 			_, err = presence.Maintain(interval)
 			Ω(err).Should(HaveOccurred())
 		})
+
+		Context("when the store fails to create the presence", func() {
+			It("should return the error instead of retrying", func() {
+				disaster := errors.New("oh no!")
+
+				fakeStore := fakestoreadapter.New()
+				fakeStore.CreateErrInjector = fakestoreadapter.NewFakeStoreAdapterErrorInjector(".*", disaster)
+
+				_, err := NewPresence(fakeStore, key, []byte(value)).Maintain(interval)
+				Ω(err).Should(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Update", func() {
+		It("should replace the value in the store", func() {
+			err = presence.Update([]byte("some-other-value"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			node, err := store.Get(key)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(node.Value).Should(Equal([]byte("some-other-value")))
+			Ω(node.TTL).Should(Equal(uint64(interval.Seconds())))
+		})
+
+		It("should keep the new value when it maintains the TTL", func() {
+			err = presence.Update([]byte("some-other-value"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			time.Sleep(2 * time.Second)
+
+			node, err := store.Get(key)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(node.Value).Should(Equal([]byte("some-other-value")))
+		})
 	})
 
 	Describe("Remove", func() {
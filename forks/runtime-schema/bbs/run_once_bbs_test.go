@@ -1,8 +1,8 @@
 package bbs_test
 
 import (
-	. "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -45,18 +45,17 @@ var _ = Describe("RunOnce BBS", func() {
 
 	Describe("MaintainExecutorPresence", func() {
 		var (
-			executorId  string
-			interval    time.Duration
-			disappeared <-chan bool
-			err         error
-			presence    PresenceInterface
+			executorId string
+			interval   time.Duration
+			err        error
+			presence   PresenceInterface
 		)
 
 		BeforeEach(func() {
 			executorId = "stubExecutor"
 			interval = 1 * time.Second
 
-			presence, disappeared, err = bbs.MaintainExecutorPresence(interval, executorId)
+			presence, _, err = bbs.MaintainExecutorPresence(interval, models.ExecutorPresence{ExecutorID: executorId})
 			Ω(err).ShouldNot(HaveOccurred())
 		})
 
@@ -70,6 +69,15 @@ var _ = Describe("RunOnce BBS", func() {
 			Ω(node.Key).Should(Equal("/v1/executor/" + executorId))
 			Ω(node.TTL).Should(Equal(uint64(interval.Seconds()))) // move to config one day
 		})
+
+		It("should store the executor's presence as the value", func() {
+			node, err := store.Get("/v1/executor/" + executorId)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			executorPresence, err := models.NewExecutorPresenceFromJSON(node.Value)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(executorPresence).Should(Equal(models.ExecutorPresence{ExecutorID: executorId}))
+		})
 	})
 
 	Describe("GetAllExecutors", func() {
@@ -79,10 +87,10 @@ var _ = Describe("RunOnce BBS", func() {
 
 			Ω(executors).Should(BeEmpty())
 
-			presenceA, _, err := bbs.MaintainExecutorPresence(1*time.Second, "executor-a")
+			presenceA, _, err := bbs.MaintainExecutorPresence(1*time.Second, models.ExecutorPresence{ExecutorID: "executor-a"})
 			Ω(err).ShouldNot(HaveOccurred())
 
-			presenceB, _, err := bbs.MaintainExecutorPresence(1*time.Second, "executor-b")
+			presenceB, _, err := bbs.MaintainExecutorPresence(1*time.Second, models.ExecutorPresence{ExecutorID: "executor-b"})
 			Ω(err).ShouldNot(HaveOccurred())
 
 			Eventually(func() []string {
@@ -149,6 +157,44 @@ var _ = Describe("RunOnce BBS", func() {
 		})
 	})
 
+	Describe("UnclaimRunOnce", func() {
+		BeforeEach(func() {
+			err := bbs.DesireRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = bbs.ClaimRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("removes /run_once/claimed/<guid>", func() {
+			err := bbs.UnclaimRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = store.Get("/v1/run_once/claimed/some-guid")
+			Ω(err).Should(Equal(storeadapter.ErrorKeyNotFound))
+		})
+
+		It("kicks the pending RunOnce so that it can be claimed again", func(done Done) {
+			events, stop, _ := bbs.WatchForDesiredRunOnce()
+
+			err := bbs.UnclaimRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(<-events).Should(Equal(runOnce))
+
+			err = bbs.ClaimRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			stop <- true
+
+			close(done)
+		})
+
+		Context("when the store is out of commission", func() {
+			itRetriesUntilStoreComesBack((*BBS).UnclaimRunOnce)
+		})
+	})
+
 	Describe("StartRunOnce", func() {
 		BeforeEach(func() {
 			err := bbs.DesireRunOnce(runOnce)
@@ -312,6 +358,43 @@ var _ = Describe("RunOnce BBS", func() {
 		})
 	})
 
+	Describe("GetAllUnclaimedRunOnces", func() {
+		var otherRunOnce models.RunOnce
+
+		BeforeEach(func() {
+			otherRunOnce = runOnce
+			otherRunOnce.Guid = "some-other-guid"
+
+			err := bbs.DesireRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = bbs.DesireRunOnce(otherRunOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("returns the pending RunOnces that nobody has claimed", func() {
+			runOnces, err := bbs.GetAllUnclaimedRunOnces()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(runOnces).Should(HaveLen(2))
+		})
+
+		It("leaves out RunOnces that have been claimed, started, completed or cancelled", func() {
+			err := bbs.ClaimRunOnce(otherRunOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			runOnces, err := bbs.GetAllUnclaimedRunOnces()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(runOnces).Should(Equal([]models.RunOnce{runOnce}))
+
+			err = bbs.CancelRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			runOnces, err = bbs.GetAllUnclaimedRunOnces()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(runOnces).Should(BeEmpty())
+		})
+	})
+
 	Describe("GetAllClaimedRunOnces", func() {
 		It("returns all RunOnces in 'claimed' state", func() {
 			err := bbs.ClaimRunOnce(runOnce)
@@ -1,7 +1,7 @@
 package bbs
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 	"time"
 )
@@ -39,3 +39,18 @@ func (self *stagerBBS) ResolveRunOnce(runOnce models.RunOnce) error {
 		return self.store.Delete(runOnceSchemaPath("pending", runOnce.Guid))
 	})
 }
+
+// The stager calls this when it wants the executor handling a runonce to abort it
+// (e.g. because the user gave up on staging)
+// stagerBBS will retry this repeatedly if it gets a StoreTimeout error (up to N seconds?)
+// The executor completes the runonce as failed once it has been cancelled
+func (self *stagerBBS) CancelRunOnce(runOnce models.RunOnce) error {
+	return retryIndefinitelyOnStoreTimeout(func() error {
+		return self.store.SetMulti([]storeadapter.StoreNode{
+			{
+				Key:   runOnceSchemaPath("cancelled", runOnce.Guid),
+				Value: runOnce.ToJSON(),
+			},
+		})
+	})
+}
@@ -1,8 +1,8 @@
 package bbs_test
 
 import (
-	. "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/cloudfoundry/storeadapter"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -111,6 +111,21 @@ var _ = Describe("Stager BBS", func() {
 		})
 	})
 
+	Describe("CancelRunOnce", func() {
+		It("should create /run_once/cancelled/<guid>", func() {
+			err := bbs.CancelRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			node, err := store.Get("/v1/run_once/cancelled/some-guid")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(node.Value).Should(Equal(runOnce.ToJSON()))
+		})
+
+		Context("when the store is out of commission", func() {
+			itRetriesUntilStoreComesBack((*BBS).CancelRunOnce)
+		})
+	})
+
 	Describe("WatchForCompletedRunOnce", func() {
 		var (
 			events <-chan (models.RunOnce)
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// ExecutorPresence is what an executor advertises about itself while it is
+// present: its capacity, the stacks it supports and how busy it is.  Stack is
+// the stack that RunOnces without one are run on.
+type ExecutorPresence struct {
+	ExecutorID string   `json:"executor_id"`
+	Stack      string   `json:"stack"`
+	Stacks     []string `json:"stacks"`
+	Version    string   `json:"version"`
+	StartedAt  int64    `json:"started_at"` //  the number of nanoseconds elapsed since January 1, 1970 UTC
+
+	TotalMemoryMB     int `json:"total_memory_mb"`
+	AvailableMemoryMB int `json:"available_memory_mb"`
+	TotalDiskMB       int `json:"total_disk_mb"`
+	AvailableDiskMB   int `json:"available_disk_mb"`
+
+	RunOncesInFlight int `json:"run_onces_in_flight"`
+}
+
+func NewExecutorPresenceFromJSON(payload []byte) (ExecutorPresence, error) {
+	var executorPresence ExecutorPresence
+
+	err := json.Unmarshal(payload, &executorPresence)
+	if err != nil {
+		return ExecutorPresence{}, err
+	}
+
+	return executorPresence, nil
+}
+
+func (self ExecutorPresence) ToJSON() []byte {
+	bytes, err := json.Marshal(self)
+	if err != nil {
+		panic(err)
+	}
+
+	return bytes
+}
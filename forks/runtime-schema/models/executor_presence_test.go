@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+)
+
+var _ = Describe("ExecutorPresence", func() {
+	var executorPresence ExecutorPresence
+
+	executorPresencePayload := `{
+		"executor_id":"some-executor-id",
+		"stack":"some-stack",
+		"stacks":["some-stack","some-other-stack"],
+		"version":"1.2.3",
+		"started_at":1393371971000000000,
+		"total_memory_mb":1024,
+		"available_memory_mb":512,
+		"total_disk_mb":4096,
+		"available_disk_mb":2048,
+		"run_onces_in_flight":3
+	}`
+
+	BeforeEach(func() {
+		executorPresence = ExecutorPresence{
+			ExecutorID:        "some-executor-id",
+			Stack:             "some-stack",
+			Stacks:            []string{"some-stack", "some-other-stack"},
+			Version:           "1.2.3",
+			StartedAt:         time.Date(2014, time.February, 25, 23, 46, 11, 00, time.UTC).UnixNano(),
+			TotalMemoryMB:     1024,
+			AvailableMemoryMB: 512,
+			TotalDiskMB:       4096,
+			AvailableDiskMB:   2048,
+			RunOncesInFlight:  3,
+		}
+	})
+
+	Describe("ToJSON", func() {
+		It("should JSONify", func() {
+			json := executorPresence.ToJSON()
+			Ω(string(json)).Should(MatchJSON(executorPresencePayload))
+		})
+	})
+
+	Describe("NewExecutorPresenceFromJSON", func() {
+		It("returns an ExecutorPresence with correct fields", func() {
+			decodedExecutorPresence, err := NewExecutorPresenceFromJSON([]byte(executorPresencePayload))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(decodedExecutorPresence).Should(Equal(executorPresence))
+		})
+
+		Context("with an invalid payload", func() {
+			It("returns the error", func() {
+				decodedExecutorPresence, err := NewExecutorPresenceFromJSON([]byte("butts lol"))
+				Ω(err).Should(HaveOccurred())
+
+				Ω(decodedExecutorPresence).Should(BeZero())
+			})
+		})
+	})
+})
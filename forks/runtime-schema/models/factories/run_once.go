@@ -1,7 +1,7 @@
 package factories
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"github.com/nu7hatch/gouuid"
 )
 
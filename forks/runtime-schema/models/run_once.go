@@ -10,6 +10,7 @@ type RunOnce struct {
 	Stack     string           `json:"stack"`
 	MemoryMB  int              `json:"memory_mb"`
 	DiskMB    int              `json:"disk_mb"`
+	CpuWeight uint             `json:"cpu_weight"`
 	Log       LogConfig        `json:"log"`
 	CreatedAt int64            `json:"created_at"` //  the number of nanoseconds elapsed since January 1, 1970 UTC
 
@@ -23,11 +24,37 @@ type RunOnce struct {
 
 	ContainerHandle string `json:"container_handle"`
 
+	// asks the executor to keep the container if the RunOnce fails, when
+	// it keeps failed containers only for RunOnces that ask
+	KeepFailedContainer bool `json:"keep_failed_container"`
+
+	// executor-managed directories that outlive the RunOnce, e.g. to keep
+	// downloaded dependencies between stagings of the same app
+	Caches []CacheMount `json:"caches"`
+
+	// how many ports to map into the container from the executor's host,
+	// and, once they are, which ports were mapped
+	InboundPorts int           `json:"inbound_ports"`
+	PortMappings []PortMapping `json:"port_mappings"`
+
 	Result        string `json:"result"`
 	Failed        bool   `json:"failed"`
 	FailureReason string `json:"failure_reason"`
 }
 
+// CacheMount asks for the executor's cache with the given key to be mounted
+// at ContainerPath.  RunOnces that give the same key share the cache.
+type CacheMount struct {
+	Key           string `json:"key"`
+	ContainerPath string `json:"container_path"`
+	ReadOnly      bool   `json:"read_only"`
+}
+
+type PortMapping struct {
+	HostPort      uint32 `json:"host_port"`
+	ContainerPort uint32 `json:"container_port"`
+}
+
 type LogConfig struct {
 	Guid       string `json:"guid"`
 	SourceName string `json:"source_name"`
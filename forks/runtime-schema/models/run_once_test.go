@@ -6,7 +6,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	. "github.com/cloudfoundry-incubator/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 var _ = Describe("RunOnce", func() {
@@ -24,11 +24,20 @@ var _ = Describe("RunOnce", func() {
 			}
 		],
 		"container_handle":"17fgsafdfcvc",
+		"keep_failed_container":true,
+		"caches":[
+			{"key":"app-some-app-guid","container_path":"/tmp/cache","read_only":false}
+		],
+		"inbound_ports":1,
+		"port_mappings":[
+			{"host_port":60001,"container_port":8080}
+		],
 		"result": "turboencabulated",
 		"failed":true,
 		"failure_reason":"because i said so",
 		"memory_mb":256,
 		"disk_mb":1024,
+		"cpu_weight":42,
 		"log": {
 			"guid": "123",
 			"source_name": "APP",
@@ -58,14 +67,23 @@ var _ = Describe("RunOnce", func() {
 				SourceName: "APP",
 				Index:      &index,
 			},
-			ExecutorID:      "executor",
-			ContainerHandle: "17fgsafdfcvc",
-			Result:          "turboencabulated",
-			Failed:          true,
-			FailureReason:   "because i said so",
-			MemoryMB:        256,
-			DiskMB:          1024,
-			CreatedAt:       time.Date(2014, time.February, 25, 23, 46, 11, 00, time.UTC).UnixNano(),
+			ExecutorID:          "executor",
+			ContainerHandle:     "17fgsafdfcvc",
+			KeepFailedContainer: true,
+			Result:              "turboencabulated",
+			Failed:              true,
+			FailureReason:       "because i said so",
+			MemoryMB:            256,
+			DiskMB:              1024,
+			CpuWeight:           42,
+			CreatedAt:           time.Date(2014, time.February, 25, 23, 46, 11, 00, time.UTC).UnixNano(),
+			Caches: []CacheMount{
+				{Key: "app-some-app-guid", ContainerPath: "/tmp/cache"},
+			},
+			InboundPorts: 1,
+			PortMappings: []PortMapping{
+				{HostPort: 60001, ContainerPort: 8080},
+			},
 		}
 	})
 
@@ -3,7 +3,7 @@ package linuxplugin
 import (
 	"fmt"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 type LinuxPlugin struct{}
@@ -12,9 +12,21 @@ func New() *LinuxPlugin {
 	return &LinuxPlugin{}
 }
 
-func (p LinuxPlugin) BuildRunScript(run models.RunAction) string {
+// BuildRunScript exports the RunAction's environment, and before it the port
+// mappings: PORT is the first container port, and CONTAINER_PORT_<n> and
+// HOST_PORT_<n> are the nth mapping's ports, counting from 0.
+func (p LinuxPlugin) BuildRunScript(run models.RunAction, portMappings []models.PortMapping) string {
 	script := ""
 
+	for i, portMapping := range portMappings {
+		if i == 0 {
+			script += fmt.Sprintf("export PORT=%d\n", portMapping.ContainerPort)
+		}
+
+		script += fmt.Sprintf("export CONTAINER_PORT_%d=%d\n", i, portMapping.ContainerPort)
+		script += fmt.Sprintf("export HOST_PORT_%d=%d\n", i, portMapping.HostPort)
+	}
+
 	for _, envPair := range run.Env {
 		// naively assumes Go's string quotes are compatible with Bash,
 		// which it's not. See http://golang.org/ref/spec#String_literals
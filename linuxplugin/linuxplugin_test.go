@@ -1,8 +1,8 @@
 package linuxplugin_test
 
 import (
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	. "github.com/cloudfoundry-incubator/executor/linuxplugin"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -22,11 +22,31 @@ var _ = Describe("LinuxPlugin", func() {
 					{"FOO", "1"},
 					{"BAR", "2"},
 				},
-			})).Should(Equal(`export FOO="1"
+			}, nil)).Should(Equal(`export FOO="1"
 export BAR="2"
 sudo reboot`))
 		})
 
+		Context("when the RunOnce has port mappings", func() {
+			It("exports the ports before the RunAction's environment", func() {
+				Ω(plugin.BuildRunScript(models.RunAction{
+					Script: "sudo reboot",
+					Env: [][]string{
+						{"FOO", "1"},
+					},
+				}, []models.PortMapping{
+					{HostPort: 60001, ContainerPort: 8080},
+					{HostPort: 60002, ContainerPort: 8081},
+				})).Should(Equal(`export PORT=8080
+export CONTAINER_PORT_0=8080
+export HOST_PORT_0=60001
+export CONTAINER_PORT_1=8081
+export HOST_PORT_1=60002
+export FOO="1"
+sudo reboot`))
+			})
+		})
+
 		Context("when the environment variables are messed up", func() {
 			It("ignores the messed up env variables", func() {
 				Ω(plugin.BuildRunScript(models.RunAction{
@@ -38,7 +58,7 @@ sudo reboot`))
 						{"BANANA", "TOO", "LONG"},
 						{"BAR", "2"},
 					},
-				})).Should(Equal(`export FOO="1"
+				}, nil)).Should(Equal(`export FOO="1"
 export BAR="2"
 sudo reboot`))
 			})
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -12,17 +13,28 @@ import (
 	"github.com/cloudfoundry-incubator/executor/actionrunner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/downloader"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/uploader"
+	"github.com/cloudfoundry-incubator/executor/api"
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/containerpool"
 	"github.com/cloudfoundry-incubator/executor/executor"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
 	"github.com/cloudfoundry-incubator/executor/linuxplugin"
+	"github.com/cloudfoundry-incubator/executor/outbox"
+	"github.com/cloudfoundry-incubator/executor/reaper"
+	"github.com/cloudfoundry-incubator/executor/retention"
 	"github.com/cloudfoundry-incubator/executor/runoncehandler"
+	"github.com/cloudfoundry-incubator/executor/runoncequeue"
 	"github.com/cloudfoundry-incubator/executor/taskregistry"
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
 	steno "github.com/cloudfoundry/gosteno"
 	"github.com/cloudfoundry/storeadapter/etcdstoreadapter"
 	"github.com/cloudfoundry/storeadapter/workerpool"
-	"github.com/vito/gordon"
 )
 
+// set at build time with -ldflags "-X main.version <version>"
+var version = "dev"
+
 var wardenNetwork = flag.String(
 	"wardenNetwork",
 	"unix",
@@ -59,12 +71,54 @@ var diskMB = flag.Int(
 	"the amount of disk the executor has available in megabytes",
 )
 
+var cpuWeight = flag.Int(
+	"cpuWeight",
+	0,
+	"the total cpu weight RunOnces may declare (0 for no limit)",
+)
+
+var maxContainers = flag.Int(
+	"maxContainers",
+	0,
+	"the most containers the executor runs at once (0 for no limit)",
+)
+
+var reservedMemoryMB = flag.Int(
+	"reservedMemoryMB",
+	0,
+	"the amount of memory, in megabytes, held back from RunOnces for warden and the executor",
+)
+
+var reservedDiskMB = flag.Int(
+	"reservedDiskMB",
+	0,
+	"the amount of disk, in megabytes, held back from RunOnces for warden and the executor",
+)
+
+var memoryOvercommitRatio = flag.Float64(
+	"memoryOvercommitRatio",
+	1.0,
+	"how many times the unreserved memory RunOnces may declare in total",
+)
+
+var diskOvercommitRatio = flag.Float64(
+	"diskOvercommitRatio",
+	1.0,
+	"how many times the unreserved disk RunOnces may declare in total",
+)
+
 var registrySnapshotFile = flag.String(
 	"registrySnapshotFile",
 	"registry_snapshot",
 	"the location, on disk, where the task registry snapshot should be stored",
 )
 
+var registrySnapshotInterval = flag.Duration(
+	"registrySnapshotInterval",
+	0,
+	"how often changes to the task registry are snapshotted to disk (0 snapshots every change)",
+)
+
 var convergenceInterval = flag.Duration(
 	"convergenceInterval",
 	30*time.Second,
@@ -95,10 +149,100 @@ var loggregatorSecret = flag.String(
 	"secret for the loggregator server",
 )
 
+var executorIDFile = flag.String(
+	"executorIDFile",
+	"",
+	"file in which to keep the executor's ID across restarts, so that it can resume its RunOnces (a new ID is generated on every start if not given)",
+)
+
+var listenAddr = flag.String(
+	"listenAddr",
+	"",
+	"address for the local management API to listen on, e.g. 127.0.0.1:1700 (disabled if not given)",
+)
+
 var stack = flag.String(
 	"stack",
 	"default",
-	"the executor stack",
+	"the executor's default stack, for RunOnces that do not ask for one",
+)
+
+var stackRootfses = flag.String(
+	"stackRootfses",
+	"",
+	"comma-separated stack:rootfs pairs, for each stack the executor supports (the default stack uses warden's default rootfs unless it is listed)",
+)
+
+var containerPoolSize = flag.Int(
+	"containerPoolSize",
+	0,
+	"the number of idle containers to keep created ahead of RunOnces (0 disables the pool)",
+)
+
+var retainFailedContainers = flag.String(
+	"retainFailedContainers",
+	retention.RetainNone,
+	"which failed RunOnces have their containers kept for debugging (none, opt-in, all)",
+)
+
+var failedContainerTTL = flag.Duration(
+	"failedContainerTTL",
+	1*time.Hour,
+	"how long the containers of failed RunOnces are kept for debugging",
+)
+
+var cacheDir = flag.String(
+	"cacheDir",
+	"",
+	"the directory to keep the caches that RunOnces mount in; no caches are kept if it is not given",
+)
+
+var cacheDiskMB = flag.Int(
+	"cacheDiskMB",
+	0,
+	"the amount of disk, in megabytes, the caches may use; it is held back from RunOnces",
+)
+
+var containerHandlePrefix = flag.String(
+	"containerHandlePrefix",
+	containerfactory.DefaultHandlePrefix,
+	"the prefix of the handles of the containers the executor creates; containers without it are never reaped",
+)
+
+var orphanReapInterval = flag.Duration(
+	"orphanReapInterval",
+	reaper.DefaultInterval,
+	"how often to look for containers the executor created but no longer tracks",
+)
+
+var orphanGracePeriod = flag.Duration(
+	"orphanGracePeriod",
+	reaper.DefaultGracePeriod,
+	"how long a container the executor no longer tracks is left alone before it is destroyed",
+)
+
+var drainTimeout = flag.Duration(
+	"drainTimeout",
+	15*time.Minute,
+	"time to wait for in-flight run onces to finish when shutting down, before cancelling them",
+)
+
+var maxConcurrentRunOnces = flag.Int(
+	"maxConcurrentRunOnces",
+	executor.DefaultMaxConcurrentRunOnces,
+	"the number of desired run onces to claim and run at a time",
+)
+
+var maxQueuedRunOnces = flag.Int(
+	"maxQueuedRunOnces",
+	executor.DefaultMaxQueuedRunOnces,
+	"the number of desired run onces to queue up when busy; any more are left for other executors",
+)
+
+var killGracePeriod = flag.Duration(
+	"killGracePeriod",
+	10*time.Second,
+	"time a timed out run action's process has to exit after being stopped, before it is killed",
 )
 
 var timeToClaimRunOnce = flag.Duration(
@@ -155,41 +299,195 @@ func main() {
 		os.Exit(1)
 	}
 
-	taskRegistry, err := taskregistry.LoadTaskRegistryFromDisk(*registrySnapshotFile, *memoryMB, *diskMB)
+	// the caches' disk is held back from RunOnces, so that they cannot fill it
+	cacheReservedDiskMB := 0
+	if *cacheDir != "" {
+		cacheReservedDiskMB = *cacheDiskMB
+	}
+
+	capacityPolicy := taskregistry.CapacityPolicy{
+		ReservedMemoryMB:      *reservedMemoryMB,
+		ReservedDiskMB:        *reservedDiskMB + cacheReservedDiskMB,
+		MemoryOvercommitRatio: *memoryOvercommitRatio,
+		DiskOvercommitRatio:   *diskOvercommitRatio,
+	}
+
+	if *cpuWeight < 0 || *maxContainers < 0 {
+		logger.Error("cpu weight and max containers cannot be negative!")
+		os.Exit(1)
+	}
+
+	totalCapacity := taskregistry.Capacity{
+		MemoryMB:   *memoryMB,
+		DiskMB:     *diskMB,
+		CpuWeight:  *cpuWeight,
+		Containers: *maxContainers,
+	}
+
+	err = capacityPolicy.Validate(totalCapacity)
+	if err != nil {
+		logger.Errord(map[string]interface{}{
+			"error": err.Error(),
+		}, "executor.capacity-policy.invalid")
+		os.Exit(1)
+	}
+
+	taskRegistry, err := taskregistry.LoadTaskRegistryFromDiskWithPolicy(*registrySnapshotFile, totalCapacity, capacityPolicy)
 	if err != nil {
 		switch err {
 		case taskregistry.ErrorRegistrySnapshotHasInvalidJSON:
 			logger.Error("corrupt registry snapshot detected.  aborting!")
 			os.Exit(1)
+		case taskregistry.ErrorRegistrySnapshotVersionUnsupported:
+			logger.Error("registry snapshot was written by a newer executor.  aborting!")
+			os.Exit(1)
 		case taskregistry.ErrorNotEnoughMemoryWhenLoadingSnapshot:
 			logger.Error("memory requirements in snapshot exceed the configured memory limit.  aborting!")
 			os.Exit(1)
 		case taskregistry.ErrorNotEnoughDiskWhenLoadingSnapshot:
 			logger.Error("disk requirements in snapshot exceed the configured memory limit.  aborting!")
 			os.Exit(1)
+		case taskregistry.ErrorNotEnoughCpuWeightWhenLoadingSnapshot:
+			logger.Error("cpu weight requirements in snapshot exceed the configured cpu weight.  aborting!")
+			os.Exit(1)
+		case taskregistry.ErrorTooManyContainersWhenLoadingSnapshot:
+			logger.Error("containers in snapshot exceed the configured max containers.  aborting!")
+			os.Exit(1)
 		case taskregistry.ErrorRegistrySnapshotDoesNotExist:
 			logger.Info("Didn't find snapshot.  Creating new registry.")
-			taskRegistry = taskregistry.NewTaskRegistry(*registrySnapshotFile, *memoryMB, *diskMB)
+			taskRegistry = taskregistry.NewTaskRegistryWithPolicy(*registrySnapshotFile, totalCapacity, capacityPolicy)
 		default:
 			logger.Errorf("woah, woah, woah!  what happened with the snapshot?: %s", err.Error())
 			os.Exit(1)
 		}
 	}
 
-	executor := executor.New(bbs, logger)
+	// completions that the BBS has not accepted yet are kept next to the
+	// registry snapshot, and replayed on startup
+	outboxFile := *registrySnapshotFile + ".outbox"
+	completionOutbox, err := outbox.LoadOutboxFromDisk(outboxFile, bbs, outbox.DefaultRetryInterval, outbox.DefaultMaxRetryInterval, logger)
+	if err != nil {
+		logger.Errord(map[string]interface{}{
+			"error":          err.Error(),
+			"outboxLocation": outboxFile,
+		}, "executor.outbox.load-failed")
+		os.Exit(1)
+	}
+
+	linuxPlugin := linuxplugin.New()
+	downloader := downloader.New(10*time.Minute, logger)
+	uploader := uploader.New(10*time.Minute, logger)
+	theFlash := actionrunner.New(wardenClient, linuxPlugin, downloader, uploader, *tempDir, *killGracePeriod, logger)
+
+	stacks, err := containerfactory.ParseStacks(*stack, *stackRootfses)
+	if err != nil {
+		logger.Errord(map[string]interface{}{
+			"error":         err.Error(),
+			"stackRootfses": *stackRootfses,
+		}, "executor.stacks.invalid")
+		os.Exit(1)
+	}
+
+	containerFactory := containerfactory.New(wardenClient, *containerHandlePrefix, stacks)
+
+	containerPool := containerpool.New(stacks.Names(), *containerPoolSize, containerFactory, wardenClient, taskRegistry, containerpool.DefaultRefillInterval, logger)
+
+	cacheManager, err := cache.New(*cacheDir, *cacheDiskMB, logger)
+	if err != nil {
+		logger.Errord(map[string]interface{}{
+			"error":    err.Error(),
+			"cacheDir": *cacheDir,
+		}, "executor.caches.load-failed")
+		os.Exit(1)
+	}
+
+	retentionPolicy, err := retention.NewPolicy(*retainFailedContainers, *failedContainerTTL)
+	if err != nil {
+		logger.Errord(map[string]interface{}{
+			"error": err.Error(),
+			"mode":  *retainFailedContainers,
+		}, "executor.retention-policy.invalid")
+		os.Exit(1)
+	}
+
+	runOnceHandler := runoncehandler.New(
+		bbs,
+		wardenClient,
+		containerFactory,
+		containerPool,
+		cacheManager,
+		retentionPolicy,
+		taskRegistry,
+		theFlash,
+		completionOutbox,
+		*loggregatorServer,
+		*loggregatorSecret,
+		logger,
+	)
+
+	executorID := executor.GenerateID()
+	if *executorIDFile != "" {
+		executorID, err = executor.LoadOrCreateID(*executorIDFile)
+		if err != nil {
+			logger.Errord(map[string]interface{}{
+				"error":          err.Error(),
+				"executorIDFile": *executorIDFile,
+			}, "executor.id.load-failed")
+			os.Exit(1)
+		}
+	}
+
+	runOnceQueue := runoncequeue.New(*maxConcurrentRunOnces, *maxQueuedRunOnces, taskRegistry)
+
+	executor := executor.NewWithID(executorID, stacks.Names(), version, bbs, wardenClient, taskRegistry, runOnceQueue, logger)
+
+	err = executor.Reconcile(runOnceHandler, completionOutbox, containerFactory)
+	if err != nil {
+		logger.Errorf("failed to reconcile the registry snapshot: %s", err.Error())
+		os.Exit(1)
+	}
+
+	completionOutbox.Start()
+	containerPool.Start()
+
+	sweeper := retention.NewSweeper(wardenClient, taskRegistry, cacheManager, logger)
+	sweeper.ReacquireCaches()
+	stopSweeping := sweeper.Start(retention.DefaultSweepInterval)
+
+	orphanReaper := reaper.New(wardenClient, containerFactory, *orphanGracePeriod, logger, taskRegistry, containerPool)
+	stopReaping := orphanReaper.Start(*orphanReapInterval)
+
+	stopSnapshotting := taskRegistry.StartSnapshotting(*registrySnapshotInterval, logger)
+
 	err = executor.MaintainPresence(*heartbeatInterval)
 	if err != nil {
 		logger.Errorf("failed to start maintaining presence: %s", err.Error())
 		os.Exit(1)
 	}
 
-	logger.Infof("Starting executor: ID=%s, stack=%s", executor.ID(), *stack)
+	logger.Infof("Starting executor: ID=%s, stacks=%s, version=%s", executor.ID(), strings.Join(stacks.Names(), ","), version)
 
 	signals := make(chan os.Signal, 1)
 
 	go func() {
 		<-signals
 
+		logger.Infod(
+			map[string]interface{}{
+				"timeout": drainTimeout.String(),
+			},
+			"executor.draining",
+		)
+
+		executor.Drain(*drainTimeout)
+		completionOutbox.Stop()
+		containerPool.Drain()
+
+		stopSweeping <- true
+		stopReaping <- true
+
+		stopSnapshotting <- true
+
 		err := taskRegistry.WriteToDisk()
 		if err != nil {
 			logger.Errord(
@@ -215,22 +513,6 @@ func main() {
 
 	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
 
-	linuxPlugin := linuxplugin.New()
-	downloader := downloader.New(10*time.Minute, logger)
-	uploader := uploader.New(10*time.Minute, logger)
-	theFlash := actionrunner.New(wardenClient, linuxPlugin, downloader, uploader, *tempDir, logger)
-
-	runOnceHandler := runoncehandler.New(
-		bbs,
-		wardenClient,
-		taskRegistry,
-		theFlash,
-		*loggregatorServer,
-		*loggregatorSecret,
-		*stack,
-		logger,
-	)
-
 	err = executor.Handle(runOnceHandler)
 	if err != nil {
 		logger.Errorf("failed to start handling run onces: %s", err.Error())
@@ -239,7 +521,23 @@ func main() {
 
 	logger.Infof("Watching for RunOnces!")
 
+	if *listenAddr != "" {
+		go serveAPI(api.New(executor.ID(), stacks.Names(), executor.StartedAt(), taskRegistry, runOnceQueue, runOnceHandler, sweeper, logger), logger)
+	}
+
 	executor.ConvergeRunOnces(*convergenceInterval, *timeToClaimRunOnce)
 
 	select {}
 }
+
+func serveAPI(handler http.Handler, logger *steno.Logger) {
+	logger.Infof("Serving the management API on %s", *listenAddr)
+
+	err := http.ListenAndServe(*listenAddr, handler)
+	if err != nil {
+		logger.Errord(map[string]interface{}{
+			"error":      err.Error(),
+			"listenAddr": *listenAddr,
+		}, "executor.api.listen-failed")
+	}
+}
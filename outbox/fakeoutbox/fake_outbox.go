@@ -0,0 +1,45 @@
+package fakeoutbox
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+)
+
+type FakeOutbox struct {
+	CompletedRunOnces []models.RunOnce
+	CompleteErr       error
+
+	PendingRunOnceGuids map[string]bool
+
+	// when set, Complete does not record the RunOnce until this is closed
+	BlockComplete chan bool
+
+	lock *sync.Mutex
+}
+
+func New() *FakeOutbox {
+	return &FakeOutbox{
+		PendingRunOnceGuids: make(map[string]bool),
+		lock:                &sync.Mutex{},
+	}
+}
+
+func (fakeOutbox *FakeOutbox) Complete(runOnce models.RunOnce) error {
+	if fakeOutbox.BlockComplete != nil {
+		<-fakeOutbox.BlockComplete
+	}
+
+	fakeOutbox.lock.Lock()
+	defer fakeOutbox.lock.Unlock()
+
+	fakeOutbox.CompletedRunOnces = append(fakeOutbox.CompletedRunOnces, runOnce)
+	return fakeOutbox.CompleteErr
+}
+
+func (fakeOutbox *FakeOutbox) IsPending(runOnceGuid string) bool {
+	fakeOutbox.lock.Lock()
+	defer fakeOutbox.lock.Unlock()
+
+	return fakeOutbox.PendingRunOnceGuids[runOnceGuid]
+}
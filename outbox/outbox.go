@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/storeadapter"
+
+	"github.com/cloudfoundry-incubator/executor/atomicfile"
+)
+
+var ErrorOutboxHasInvalidJSON = errors.New("Completion outbox has invalid JSON")
+
+const (
+	DefaultRetryInterval    = 1 * time.Second
+	DefaultMaxRetryInterval = 1 * time.Minute
+)
+
+type OutboxInterface interface {
+	Complete(runOnce models.RunOnce) error
+	IsPending(runOnceGuid string) bool
+}
+
+// Outbox keeps completed RunOnces on disk until the BBS has accepted them, so
+// that a completion is not lost if etcd is unreachable at the time, or if the
+// executor restarts before it gets through.
+type Outbox struct {
+	fileName string
+	bbs      Bbs.ExecutorBBS
+	logger   *steno.Logger
+
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+
+	completions map[string]models.RunOnce
+	lock        *sync.Mutex
+
+	kick     chan struct{}
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func New(fileName string, bbs Bbs.ExecutorBBS, retryInterval time.Duration, maxRetryInterval time.Duration, logger *steno.Logger) *Outbox {
+	return &Outbox{
+		fileName: fileName,
+		bbs:      bbs,
+		logger:   logger,
+
+		retryInterval:    retryInterval,
+		maxRetryInterval: maxRetryInterval,
+
+		completions: make(map[string]models.RunOnce),
+		lock:        &sync.Mutex{},
+
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+	}
+}
+
+// LoadOutboxFromDisk returns an outbox holding the completions that were not
+// yet delivered when the executor last stopped.  A missing file is an empty
+// outbox.
+func LoadOutboxFromDisk(fileName string, bbs Bbs.ExecutorBBS, retryInterval time.Duration, maxRetryInterval time.Duration, logger *steno.Logger) (*Outbox, error) {
+	outbox := New(fileName, bbs, retryInterval, maxRetryInterval, logger)
+
+	bytes, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return outbox, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var completions []models.RunOnce
+	err = json.Unmarshal(bytes, &completions)
+	if err != nil {
+		return nil, ErrorOutboxHasInvalidJSON
+	}
+
+	for _, runOnce := range completions {
+		outbox.completions[runOnce.Guid] = runOnce
+	}
+
+	return outbox, nil
+}
+
+// Complete records the completed RunOnce on disk and hands it to the delivery
+// loop.  The completion is delivered even if recording it fails, but it will
+// not survive a restart.
+func (outbox *Outbox) Complete(runOnce models.RunOnce) error {
+	outbox.lock.Lock()
+	outbox.completions[runOnce.Guid] = runOnce
+	err := outbox.writeToDisk()
+	outbox.lock.Unlock()
+
+	outbox.kickDelivery()
+
+	return err
+}
+
+// IsPending returns true if the RunOnce's completion has not yet been accepted
+// by the BBS.
+func (outbox *Outbox) IsPending(runOnceGuid string) bool {
+	outbox.lock.Lock()
+	defer outbox.lock.Unlock()
+
+	_, pending := outbox.completions[runOnceGuid]
+	return pending
+}
+
+// Start delivers the completions in the outbox, and any that are added later,
+// to the BBS until it is stopped.  Failed deliveries are retried with
+// exponential backoff.
+func (outbox *Outbox) Start() {
+	outbox.kickDelivery()
+
+	go func() {
+		interval := outbox.retryInterval
+		var retry <-chan time.Time
+
+		for {
+			select {
+			case <-outbox.kick:
+			case <-retry:
+			case <-outbox.stop:
+				return
+			}
+
+			if outbox.deliver() {
+				interval = outbox.retryInterval
+				retry = nil
+				continue
+			}
+
+			retry = time.After(interval)
+
+			interval *= 2
+			if interval > outbox.maxRetryInterval {
+				interval = outbox.maxRetryInterval
+			}
+		}
+	}()
+}
+
+// Stop stops delivering completions.  Those not yet delivered stay on disk.
+func (outbox *Outbox) Stop() {
+	outbox.stopOnce.Do(func() {
+		close(outbox.stop)
+	})
+}
+
+func (outbox *Outbox) kickDelivery() {
+	select {
+	case outbox.kick <- struct{}{}:
+	default:
+	}
+}
+
+// deliver tries to complete every RunOnce in the outbox, and returns false if
+// any of them failed
+func (outbox *Outbox) deliver() bool {
+	outbox.lock.Lock()
+	completions := []models.RunOnce{}
+	for _, runOnce := range outbox.completions {
+		completions = append(completions, runOnce)
+	}
+	outbox.lock.Unlock()
+
+	delivered := true
+
+	for _, runOnce := range completions {
+		err := outbox.bbs.CompleteRunOnce(runOnce)
+
+		// a RunOnce that is already completed was delivered before a restart
+		if err != nil && err != storeadapter.ErrorKeyExists {
+			outbox.logger.Errord(map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+				"error":        err.Error(),
+			}, "runonce.complete.failed")
+
+			delivered = false
+			continue
+		}
+
+		outbox.remove(runOnce)
+	}
+
+	return delivered
+}
+
+func (outbox *Outbox) remove(runOnce models.RunOnce) {
+	outbox.lock.Lock()
+	defer outbox.lock.Unlock()
+
+	delete(outbox.completions, runOnce.Guid)
+
+	err := outbox.writeToDisk()
+	if err != nil {
+		outbox.logger.Errord(map[string]interface{}{
+			"error":          err.Error(),
+			"outboxLocation": outbox.fileName,
+		}, "executor.outbox.write-failed")
+	}
+}
+
+func (outbox *Outbox) writeToDisk() error {
+	completions := []models.RunOnce{}
+	for _, runOnce := range outbox.completions {
+		completions = append(completions, runOnce)
+	}
+
+	data, err := json.Marshal(completions)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(outbox.fileName, data)
+}
@@ -0,0 +1,236 @@
+package outbox_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs/fakebbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/storeadapter"
+
+	. "github.com/cloudfoundry-incubator/executor/outbox"
+)
+
+// flakyBBS fails to complete RunOnces until it is told to succeed
+type flakyBBS struct {
+	*fakebbs.FakeExecutorBBS
+
+	completeErr error
+	completed   []models.RunOnce
+	attempts    int
+	lock        *sync.Mutex
+}
+
+func (bbs *flakyBBS) CompleteRunOnce(runOnce models.RunOnce) error {
+	bbs.lock.Lock()
+	defer bbs.lock.Unlock()
+
+	bbs.attempts++
+
+	if bbs.completeErr != nil {
+		return bbs.completeErr
+	}
+
+	bbs.completed = append(bbs.completed, runOnce)
+	return nil
+}
+
+func (bbs *flakyBBS) setCompleteErr(err error) {
+	bbs.lock.Lock()
+	defer bbs.lock.Unlock()
+
+	bbs.completeErr = err
+}
+
+func (bbs *flakyBBS) Completed() []models.RunOnce {
+	bbs.lock.Lock()
+	defer bbs.lock.Unlock()
+
+	return bbs.completed
+}
+
+func (bbs *flakyBBS) Attempts() int {
+	bbs.lock.Lock()
+	defer bbs.lock.Unlock()
+
+	return bbs.attempts
+}
+
+var _ = Describe("Outbox", func() {
+	var (
+		outbox   *Outbox
+		bbs      *flakyBBS
+		fileName string
+		runOnce  models.RunOnce
+	)
+
+	BeforeEach(func() {
+		fileName = fmt.Sprintf("/tmp/executor_outbox_%d", config.GinkgoConfig.ParallelNode)
+		os.Remove(fileName)
+
+		bbs = &flakyBBS{
+			FakeExecutorBBS: fakebbs.NewFakeExecutorBBS(),
+			lock:            &sync.Mutex{},
+		}
+
+		runOnce = models.RunOnce{
+			Guid:          "totally-unique",
+			Result:        "some-result-payload",
+			Failed:        true,
+			FailureReason: "because i said so",
+		}
+
+		outbox = New(fileName, bbs, 10*time.Millisecond, 40*time.Millisecond, steno.NewLogger("test-logger"))
+	})
+
+	AfterEach(func() {
+		outbox.Stop()
+		os.Remove(fileName)
+	})
+
+	Describe("Complete", func() {
+		It("records the completion on disk", func() {
+			err := outbox.Complete(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(outbox.IsPending(runOnce.Guid)).Should(BeTrue())
+
+			loaded, err := LoadOutboxFromDisk(fileName, bbs, time.Second, time.Second, steno.NewLogger("test-logger"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loaded.IsPending(runOnce.Guid)).Should(BeTrue())
+		})
+
+		It("replaces the outbox file atomically, readable only by the executor", func() {
+			err := outbox.Complete(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			info, err := os.Stat(fileName)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(info.Mode().Perm()).Should(Equal(os.FileMode(0600)))
+
+			_, err = os.Stat(fileName + ".tmp")
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		Context("when the outbox is delivering", func() {
+			BeforeEach(func() {
+				outbox.Start()
+			})
+
+			It("completes the RunOnce in the BBS, with its result", func() {
+				err := outbox.Complete(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(bbs.Completed).Should(Equal([]models.RunOnce{runOnce}))
+				Eventually(func() bool { return outbox.IsPending(runOnce.Guid) }).Should(BeFalse())
+			})
+
+			It("forgets the completion on disk once it is delivered", func() {
+				err := outbox.Complete(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(func() bool { return outbox.IsPending(runOnce.Guid) }).Should(BeFalse())
+
+				loaded, err := LoadOutboxFromDisk(fileName, bbs, time.Second, time.Second, steno.NewLogger("test-logger"))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(loaded.IsPending(runOnce.Guid)).Should(BeFalse())
+			})
+
+			Context("when the BBS fails to complete the RunOnce", func() {
+				BeforeEach(func() {
+					bbs.setCompleteErr(errors.New("etcd is away"))
+				})
+
+				It("keeps retrying until the BBS accepts it", func() {
+					err := outbox.Complete(runOnce)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Eventually(bbs.Attempts).Should(BeNumerically(">=", 3))
+					Ω(outbox.IsPending(runOnce.Guid)).Should(BeTrue())
+
+					bbs.setCompleteErr(nil)
+
+					Eventually(bbs.Completed).Should(Equal([]models.RunOnce{runOnce}))
+					Eventually(func() bool { return outbox.IsPending(runOnce.Guid) }).Should(BeFalse())
+				})
+			})
+
+			Context("when the RunOnce was already completed", func() {
+				BeforeEach(func() {
+					bbs.setCompleteErr(storeadapter.ErrorKeyExists)
+				})
+
+				It("forgets the completion", func() {
+					err := outbox.Complete(runOnce)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					Eventually(func() bool { return outbox.IsPending(runOnce.Guid) }).Should(BeFalse())
+					Ω(bbs.Attempts()).Should(Equal(1))
+				})
+			})
+		})
+
+		Context("when the outbox cannot be written", func() {
+			BeforeEach(func() {
+				outbox = New("/tmp/this/directory/does/not/exist/outbox", bbs, time.Second, time.Second, steno.NewLogger("test-logger"))
+			})
+
+			It("returns an error, but still delivers the completion", func() {
+				err := outbox.Complete(runOnce)
+				Ω(err).Should(HaveOccurred())
+
+				outbox.Start()
+
+				Eventually(bbs.Completed).Should(Equal([]models.RunOnce{runOnce}))
+			})
+		})
+	})
+
+	Describe("LoadOutboxFromDisk", func() {
+		Context("when the outbox has undelivered completions", func() {
+			BeforeEach(func() {
+				err := outbox.Complete(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("replays them once started", func() {
+				loaded, err := LoadOutboxFromDisk(fileName, bbs, 10*time.Millisecond, 40*time.Millisecond, steno.NewLogger("test-logger"))
+				Ω(err).ShouldNot(HaveOccurred())
+
+				loaded.Start()
+				defer loaded.Stop()
+
+				Eventually(bbs.Completed).Should(Equal([]models.RunOnce{runOnce}))
+			})
+		})
+
+		Context("when there is no outbox file", func() {
+			It("returns an empty outbox", func() {
+				loaded, err := LoadOutboxFromDisk(fileName, bbs, time.Second, time.Second, steno.NewLogger("test-logger"))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(loaded.IsPending(runOnce.Guid)).Should(BeFalse())
+			})
+		})
+
+		Context("when the outbox file is corrupt", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(fileName, []byte("ß"), os.ModePerm)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, err := LoadOutboxFromDisk(fileName, bbs, time.Second, time.Second, steno.NewLogger("test-logger"))
+				Ω(err).Should(Equal(ErrorOutboxHasInvalidJSON))
+			})
+		})
+	})
+})
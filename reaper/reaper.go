@@ -0,0 +1,170 @@
+package reaper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+)
+
+// how often the reaper looks for orphaned containers
+const DefaultInterval = 1 * time.Minute
+
+// how long an orphaned container is left alone, in case it is still being
+// handed to a RunOnce
+const DefaultGracePeriod = 5 * time.Minute
+
+// ContainerTracker is anything that holds on to containers, such as the task
+// registry or the container pool.
+type ContainerTracker interface {
+	ContainerHandles() []string
+}
+
+// Stats counts what the reaper has done.
+type Stats struct {
+	Reaped          int `json:"reaped"`
+	DestroyFailures int `json:"destroy_failures"`
+}
+
+// Reaper destroys orphaned containers: containers that the executor created,
+// but that nothing tracks any more, e.g. because the executor crashed before
+// it could destroy them.  Warden does not say when a container was created, so
+// a container is only destroyed once it has been seen orphaned for longer than
+// the grace period.  Containers the executor does not own are never touched.
+type Reaper struct {
+	wardenClient     gordon.Client
+	containerFactory *containerfactory.ContainerFactory
+	trackers         []ContainerTracker
+	gracePeriod      time.Duration
+	logger           *steno.Logger
+
+	orphanedSince map[string]time.Time
+	stats         Stats
+
+	lock *sync.Mutex
+}
+
+func New(
+	wardenClient gordon.Client,
+	containerFactory *containerfactory.ContainerFactory,
+	gracePeriod time.Duration,
+	logger *steno.Logger,
+	trackers ...ContainerTracker,
+) *Reaper {
+	return &Reaper{
+		wardenClient:     wardenClient,
+		containerFactory: containerFactory,
+		trackers:         trackers,
+		gracePeriod:      gracePeriod,
+		logger:           logger,
+
+		orphanedSince: make(map[string]time.Time),
+
+		lock: &sync.Mutex{},
+	}
+}
+
+// Start reaps orphaned containers every interval, until it is told to stop.
+func (reaper *Reaper) Start(interval time.Duration) chan<- bool {
+	stop := make(chan bool, 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				reaper.Reap()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return stop
+}
+
+// Reap destroys the executor's containers that have been orphaned for longer
+// than the grace period.
+func (reaper *Reaper) Reap() {
+	reaper.lock.Lock()
+	defer reaper.lock.Unlock()
+
+	listResponse, err := reaper.wardenClient.List()
+	if err != nil {
+		reaper.logger.Errord(
+			map[string]interface{}{
+				"error": err.Error(),
+			}, "executor.reaper.list-failed",
+		)
+		return
+	}
+
+	// the trackers are asked after warden, so that a container created in
+	// between is never mistaken for an orphan
+	tracked := make(map[string]bool)
+	for _, tracker := range reaper.trackers {
+		for _, handle := range tracker.ContainerHandles() {
+			tracked[handle] = true
+		}
+	}
+
+	now := time.Now()
+	orphanedSince := make(map[string]time.Time)
+
+	for _, handle := range listResponse.GetHandles() {
+		if tracked[handle] || !reaper.containerFactory.Owns(handle) {
+			continue
+		}
+
+		since, seen := reaper.orphanedSince[handle]
+		if !seen {
+			since = now
+		}
+
+		if now.Sub(since) < reaper.gracePeriod || !reaper.destroy(handle, now.Sub(since)) {
+			orphanedSince[handle] = since
+		}
+	}
+
+	reaper.orphanedSince = orphanedSince
+}
+
+func (reaper *Reaper) Stats() Stats {
+	reaper.lock.Lock()
+	defer reaper.lock.Unlock()
+
+	return reaper.stats
+}
+
+func (reaper *Reaper) destroy(handle string, orphanedFor time.Duration) bool {
+	_, err := reaper.wardenClient.Destroy(handle)
+	if err != nil {
+		reaper.stats.DestroyFailures++
+
+		reaper.logger.Errord(
+			map[string]interface{}{
+				"handle": handle,
+				"error":  err.Error(),
+			}, "executor.reaper.destroy-failed",
+		)
+
+		return false
+	}
+
+	reaper.stats.Reaped++
+
+	reaper.logger.Infod(
+		map[string]interface{}{
+			"handle":       handle,
+			"orphaned-for": orphanedFor.String(),
+			"reaped":       reaper.stats.Reaped,
+		}, "executor.reaper.destroyed-container",
+	)
+
+	return true
+}
@@ -0,0 +1,139 @@
+package reaper_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	. "github.com/cloudfoundry-incubator/executor/reaper"
+)
+
+type fakeTracker struct {
+	handles []string
+}
+
+func (tracker *fakeTracker) ContainerHandles() []string {
+	return tracker.handles
+}
+
+var _ = Describe("Reaper", func() {
+	var wardenClient *fake_gordon.FakeGordon
+	var gracePeriod time.Duration
+	var registry *fakeTracker
+	var reaper *Reaper
+
+	createContainer := func(handle string) {
+		_, err := wardenClient.CreateContainer(gordon.ContainerSpec{Handle: handle})
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		wardenClient = fake_gordon.New()
+		gracePeriod = 0
+		registry = &fakeTracker{handles: []string{"executor-running"}}
+
+		createContainer("executor-running")
+		createContainer("executor-pooled")
+		createContainer("executor-orphaned")
+		createContainer("someone-elses")
+	})
+
+	JustBeforeEach(func() {
+		reaper = New(
+			wardenClient,
+			containerfactory.New(wardenClient, "executor-", containerfactory.NewStacks("lucid64", nil)),
+			gracePeriod,
+			steno.NewLogger("test-logger"),
+			registry,
+			&fakeTracker{handles: []string{"executor-pooled"}},
+		)
+	})
+
+	Describe("Reap", func() {
+		It("destroys the executor's containers that nothing tracks", func() {
+			reaper.Reap()
+
+			Ω(wardenClient.DestroyedHandles()).Should(Equal([]string{"executor-orphaned"}))
+			Ω(reaper.Stats()).Should(Equal(Stats{Reaped: 1}))
+		})
+
+		Context("when there is a grace period", func() {
+			BeforeEach(func() {
+				gracePeriod = 50 * time.Millisecond
+			})
+
+			It("leaves orphaned containers alone until they have been orphaned for longer", func() {
+				reaper.Reap()
+				Ω(wardenClient.DestroyedHandles()).Should(BeEmpty())
+
+				time.Sleep(gracePeriod)
+
+				reaper.Reap()
+				Ω(wardenClient.DestroyedHandles()).Should(Equal([]string{"executor-orphaned"}))
+			})
+
+			It("starts over for containers that were tracked in the meantime", func() {
+				reaper.Reap()
+
+				time.Sleep(gracePeriod)
+
+				registry.handles = []string{"executor-running", "executor-orphaned"}
+				reaper.Reap()
+
+				registry.handles = []string{"executor-running"}
+				reaper.Reap()
+				Ω(wardenClient.DestroyedHandles()).Should(BeEmpty())
+
+				time.Sleep(gracePeriod)
+
+				reaper.Reap()
+				Ω(wardenClient.DestroyedHandles()).Should(Equal([]string{"executor-orphaned"}))
+			})
+		})
+
+		Context("when warden fails to destroy a container", func() {
+			BeforeEach(func() {
+				wardenClient.DestroyError = errors.New("oh no")
+			})
+
+			It("counts the failure, and tries again next time", func() {
+				reaper.Reap()
+				Ω(reaper.Stats()).Should(Equal(Stats{DestroyFailures: 1}))
+
+				wardenClient.DestroyError = nil
+
+				reaper.Reap()
+				Ω(wardenClient.DestroyedHandles()).Should(Equal([]string{"executor-orphaned"}))
+				Ω(reaper.Stats()).Should(Equal(Stats{Reaped: 1, DestroyFailures: 1}))
+			})
+		})
+
+		Context("when warden fails to list its containers", func() {
+			BeforeEach(func() {
+				wardenClient.ListError = errors.New("oh no")
+			})
+
+			It("destroys nothing", func() {
+				reaper.Reap()
+				Ω(wardenClient.DestroyedHandles()).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Start", func() {
+		It("reaps every interval until it is stopped", func() {
+			stop := reaper.Start(10 * time.Millisecond)
+
+			Eventually(wardenClient.DestroyedHandles).Should(Equal([]string{"executor-orphaned"}))
+
+			stop <- true
+		})
+	})
+})
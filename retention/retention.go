@@ -0,0 +1,171 @@
+package retention
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var ErrUnknownRetentionMode = errors.New("unknown container retention mode")
+
+// which failed RunOnces have their containers kept
+const (
+	RetainNone  = "none"
+	RetainOptIn = "opt-in"
+	RetainAll   = "all"
+)
+
+// how often the sweeper looks for retained containers that have expired
+const DefaultSweepInterval = 1 * time.Minute
+
+// Policy decides which failed RunOnces have their containers kept, and for how
+// long.
+type Policy struct {
+	Mode string
+	TTL  time.Duration
+}
+
+var DefaultPolicy = Policy{Mode: RetainNone}
+
+func NewPolicy(mode string, ttl time.Duration) (Policy, error) {
+	switch mode {
+	case RetainNone, RetainOptIn, RetainAll:
+		return Policy{Mode: mode, TTL: ttl}, nil
+	default:
+		return Policy{}, ErrUnknownRetentionMode
+	}
+}
+
+// ShouldRetain reports whether the RunOnce's container should be kept rather
+// than destroyed.
+func (policy Policy) ShouldRetain(runOnce models.RunOnce) bool {
+	if !runOnce.Failed || runOnce.ContainerHandle == "" || policy.TTL <= 0 {
+		return false
+	}
+
+	switch policy.Mode {
+	case RetainAll:
+		return true
+	case RetainOptIn:
+		return runOnce.KeepFailedContainer
+	default:
+		return false
+	}
+}
+
+// Sweeper destroys retained containers once they expire, or when asked to,
+// and frees the caches that were mounted into them.
+type Sweeper struct {
+	wardenClient gordon.Client
+	taskRegistry *taskregistry.TaskRegistry
+	cacheManager *cache.Manager
+	logger       *steno.Logger
+
+	lock *sync.Mutex
+}
+
+func NewSweeper(wardenClient gordon.Client, taskRegistry *taskregistry.TaskRegistry, cacheManager *cache.Manager, logger *steno.Logger) *Sweeper {
+	return &Sweeper{
+		wardenClient: wardenClient,
+		taskRegistry: taskRegistry,
+		cacheManager: cacheManager,
+		logger:       logger,
+
+		lock: &sync.Mutex{},
+	}
+}
+
+// Start sweeps expired containers every interval, until it is told to stop.
+func (sweeper *Sweeper) Start(interval time.Duration) chan<- bool {
+	stop := make(chan bool, 1)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sweeper.Sweep()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return stop
+}
+
+// ReacquireCaches marks the caches of the containers retained before a restart
+// as in use again, so that they are not evicted from under them.
+func (sweeper *Sweeper) ReacquireCaches() {
+	for _, retainedContainer := range sweeper.taskRegistry.ListRetainedContainers() {
+		_, err := sweeper.cacheManager.Acquire(retainedContainer.RunOnceGuid, retainedContainer.Caches)
+		if err != nil {
+			sweeper.logger.Errord(
+				map[string]interface{}{
+					"runonce-guid": retainedContainer.RunOnceGuid,
+					"error":        err.Error(),
+				}, "runonce.retained-container.caches-failed",
+			)
+		}
+	}
+}
+
+// Sweep destroys every retained container that has expired.
+func (sweeper *Sweeper) Sweep() {
+	now := time.Now().UnixNano()
+
+	for _, retainedContainer := range sweeper.taskRegistry.ListRetainedContainers() {
+		if retainedContainer.ExpiresAt > now {
+			continue
+		}
+
+		sweeper.Destroy(retainedContainer.RunOnceGuid)
+	}
+}
+
+// Destroy destroys the retained container of the RunOnce with the given guid
+// and frees its resources and caches.  It returns false if there is no such container.
+// If warden fails to destroy it, it stays retained so that it is tried again.
+func (sweeper *Sweeper) Destroy(runOnceGuid string) (bool, error) {
+	sweeper.lock.Lock()
+	defer sweeper.lock.Unlock()
+
+	retainedContainer, retained := sweeper.taskRegistry.RetainedContainer(runOnceGuid)
+	if !retained {
+		return false, nil
+	}
+
+	_, err := sweeper.wardenClient.Destroy(retainedContainer.ContainerHandle)
+	if err != nil {
+		sweeper.logger.Errord(
+			map[string]interface{}{
+				"runonce-guid": runOnceGuid,
+				"handle":       retainedContainer.ContainerHandle,
+				"error":        err.Error(),
+			}, "runonce.retained-container.destroy-failed",
+		)
+
+		return true, err
+	}
+
+	sweeper.taskRegistry.ReleaseRetainedContainer(runOnceGuid)
+	sweeper.cacheManager.Release(runOnceGuid)
+
+	sweeper.logger.Infod(
+		map[string]interface{}{
+			"runonce-guid": runOnceGuid,
+			"handle":       retainedContainer.ContainerHandle,
+		}, "runonce.retained-container.destroyed",
+	)
+
+	return true, nil
+}
@@ -0,0 +1,13 @@
+package retention_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRetention(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Retention Suite")
+}
@@ -0,0 +1,213 @@
+package retention_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/cache"
+	. "github.com/cloudfoundry-incubator/executor/retention"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var _ = Describe("Retention", func() {
+	Describe("Policy", func() {
+		var failedRunOnce models.RunOnce
+
+		BeforeEach(func() {
+			failedRunOnce = models.RunOnce{
+				Guid:            "a guid",
+				ContainerHandle: "some-handle",
+				Failed:          true,
+			}
+		})
+
+		It("rejects unknown modes", func() {
+			_, err := NewPolicy("sometimes", time.Hour)
+			Ω(err).Should(Equal(ErrUnknownRetentionMode))
+		})
+
+		It("keeps nothing by default", func() {
+			Ω(DefaultPolicy.ShouldRetain(failedRunOnce)).Should(BeFalse())
+		})
+
+		Context("when keeping every failed container", func() {
+			var policy Policy
+
+			BeforeEach(func() {
+				var err error
+				policy, err = NewPolicy(RetainAll, time.Hour)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("keeps the containers of failed RunOnces", func() {
+				Ω(policy.ShouldRetain(failedRunOnce)).Should(BeTrue())
+			})
+
+			It("does not keep the containers of RunOnces that succeeded", func() {
+				failedRunOnce.Failed = false
+				Ω(policy.ShouldRetain(failedRunOnce)).Should(BeFalse())
+			})
+
+			It("does not keep anything for RunOnces that never got a container", func() {
+				failedRunOnce.ContainerHandle = ""
+				Ω(policy.ShouldRetain(failedRunOnce)).Should(BeFalse())
+			})
+
+			It("keeps nothing when the TTL is 0", func() {
+				policy.TTL = 0
+				Ω(policy.ShouldRetain(failedRunOnce)).Should(BeFalse())
+			})
+		})
+
+		Context("when keeping the failed containers of RunOnces that ask", func() {
+			var policy Policy
+
+			BeforeEach(func() {
+				var err error
+				policy, err = NewPolicy(RetainOptIn, time.Hour)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("keeps only the containers of RunOnces that ask", func() {
+				Ω(policy.ShouldRetain(failedRunOnce)).Should(BeFalse())
+
+				failedRunOnce.KeepFailedContainer = true
+				Ω(policy.ShouldRetain(failedRunOnce)).Should(BeTrue())
+			})
+		})
+	})
+
+	Describe("Sweeper", func() {
+		var sweeper *Sweeper
+		var gordon *fake_gordon.FakeGordon
+		var taskRegistry *taskregistry.TaskRegistry
+		var cacheManager *cache.Manager
+		var registryFileName string
+		var cacheRoot string
+		var caches []models.CacheMount
+
+		BeforeEach(func() {
+			gordon = fake_gordon.New()
+			registryFileName = fmt.Sprintf("/tmp/executor_registry_retention_%d", config.GinkgoConfig.ParallelNode)
+			taskRegistry = taskregistry.NewTaskRegistry(registryFileName, 1024, 1024)
+
+			var err error
+			cacheRoot, err = ioutil.TempDir("", "executor-caches")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			cacheManager, err = cache.New(cacheRoot, 10, steno.NewLogger("test-logger"))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			caches = []models.CacheMount{{Key: "app-123", ContainerPath: "/tmp/cache"}}
+
+			taskRegistry.RetainContainer(models.RunOnce{Guid: "expired", ContainerHandle: "expired-handle"}, -time.Second)
+			taskRegistry.RetainContainer(models.RunOnce{Guid: "fresh", ContainerHandle: "fresh-handle", Caches: caches}, time.Hour)
+
+			sweeper = NewSweeper(gordon, taskRegistry, cacheManager, steno.NewLogger("test-logger"))
+		})
+
+		AfterEach(func() {
+			os.Remove(registryFileName)
+			os.Remove(registryFileName + ".previous")
+			os.RemoveAll(cacheRoot)
+		})
+
+		Describe("ReacquireCaches", func() {
+			It("marks the retained containers' caches as in use", func() {
+				sweeper.ReacquireCaches()
+
+				Ω(cacheManager.Entries()).Should(HaveLen(1))
+				Ω(cacheManager.Entries()[0].Writing).Should(BeTrue())
+			})
+		})
+
+		Describe("Sweep", func() {
+			It("destroys and releases the expired containers", func() {
+				sweeper.Sweep()
+
+				Ω(gordon.DestroyedHandles()).Should(Equal([]string{"expired-handle"}))
+
+				_, retained := taskRegistry.RetainedContainer("expired")
+				Ω(retained).Should(BeFalse())
+
+				_, retained = taskRegistry.RetainedContainer("fresh")
+				Ω(retained).Should(BeTrue())
+			})
+		})
+
+		Describe("Start", func() {
+			It("sweeps periodically until stopped", func() {
+				stop := sweeper.Start(10 * time.Millisecond)
+				defer func() { stop <- true }()
+
+				Eventually(gordon.DestroyedHandles).Should(Equal([]string{"expired-handle"}))
+			})
+		})
+
+		Describe("Destroy", func() {
+			It("destroys a container before it expires", func() {
+				found, err := sweeper.Destroy("fresh")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(found).Should(BeTrue())
+
+				Ω(gordon.DestroyedHandles()).Should(Equal([]string{"fresh-handle"}))
+
+				_, retained := taskRegistry.RetainedContainer("fresh")
+				Ω(retained).Should(BeFalse())
+			})
+
+			It("frees the container's caches", func() {
+				_, err := cacheManager.Acquire("fresh", caches)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				_, err = sweeper.Destroy("fresh")
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(cacheManager.Entries()[0].Writing).Should(BeFalse())
+			})
+
+			It("reports containers it does not know about", func() {
+				found, err := sweeper.Destroy("unknown")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(found).Should(BeFalse())
+			})
+
+			Context("when warden fails to destroy the container", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					gordon.DestroyError = disaster
+				})
+
+				It("keeps the container retained, to be tried again", func() {
+					found, err := sweeper.Destroy("fresh")
+					Ω(err).Should(Equal(disaster))
+					Ω(found).Should(BeTrue())
+
+					_, retained := taskRegistry.RetainedContainer("fresh")
+					Ω(retained).Should(BeTrue())
+				})
+
+				It("keeps holding on to the container's caches", func() {
+					_, err := cacheManager.Acquire("fresh", caches)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					sweeper.Destroy("fresh")
+
+					Ω(cacheManager.Entries()[0].Writing).Should(BeTrue())
+				})
+			})
+		})
+	})
+})
@@ -1,16 +1,23 @@
 package claim_action
 
 import (
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"errors"
+
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 )
 
+var ErrRunOnceCancelled = errors.New("run once was cancelled before it was claimed")
+
 type ClaimAction struct {
-	runOnce    *models.RunOnce
-	logger     *steno.Logger
-	executorID string
-	bbs        Bbs.ExecutorBBS
+	runOnce      *models.RunOnce
+	logger       *steno.Logger
+	executorID   string
+	bbs          Bbs.ExecutorBBS
+	taskRegistry taskregistry.TaskRegistryInterface
 }
 
 func New(
@@ -18,19 +25,23 @@ func New(
 	logger *steno.Logger,
 	executorID string,
 	bbs Bbs.ExecutorBBS,
+	taskRegistry taskregistry.TaskRegistryInterface,
 ) *ClaimAction {
 	return &ClaimAction{
-		runOnce:    runOnce,
-		logger:     logger,
-		executorID: executorID,
-		bbs:        bbs,
+		runOnce:      runOnce,
+		logger:       logger,
+		executorID:   executorID,
+		bbs:          bbs,
+		taskRegistry: taskRegistry,
 	}
 }
 
+// Perform claims the RunOnce for the executor, unless it has been cancelled
+// while it was waiting to be claimed.
 func (action ClaimAction) Perform(result chan<- error) {
 	action.runOnce.ExecutorID = action.executorID
 
-	err := action.bbs.ClaimRunOnce(*action.runOnce)
+	err := action.claim()
 	if err != nil {
 		action.logger.Errord(
 			map[string]interface{}{
@@ -38,6 +49,9 @@ func (action ClaimAction) Perform(result chan<- error) {
 				"error":        err.Error(),
 			}, "runonce.claim.failed",
 		)
+	} else {
+		action.taskRegistry.UpdateRunOnce(*action.runOnce)
+		action.taskRegistry.SetRunOnceState(action.runOnce.Guid, taskregistry.StateClaimed)
 	}
 
 	result <- err
@@ -45,4 +59,17 @@ func (action ClaimAction) Perform(result chan<- error) {
 
 func (action ClaimAction) Cancel() {}
 
+func (action ClaimAction) claim() error {
+	cancelled, err := action.bbs.IsRunOnceCancelled(action.runOnce.Guid)
+	if err != nil {
+		return err
+	}
+
+	if cancelled {
+		return ErrRunOnceCancelled
+	}
+
+	return action.bbs.ClaimRunOnce(*action.runOnce)
+}
+
 func (action ClaimAction) Cleanup() {}
@@ -6,11 +6,13 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fakebbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs/fakebbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
 
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler/claim_action"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+	"github.com/cloudfoundry-incubator/executor/taskregistry/faketaskregistry"
 )
 
 var _ = Describe("ClaimAction", func() {
@@ -19,6 +21,7 @@ var _ = Describe("ClaimAction", func() {
 
 	var runOnce models.RunOnce
 	var bbs *fakebbs.FakeExecutorBBS
+	var taskRegistry *faketaskregistry.FakeTaskRegistry
 
 	BeforeEach(func() {
 		result = make(chan error)
@@ -36,12 +39,14 @@ var _ = Describe("ClaimAction", func() {
 		}
 
 		bbs = fakebbs.NewFakeExecutorBBS()
+		taskRegistry = faketaskregistry.New()
 
 		action = New(
 			&runOnce,
 			steno.NewLogger("test-logger"),
 			"executor-id",
 			bbs,
+			taskRegistry,
 		)
 	})
 
@@ -54,6 +59,51 @@ var _ = Describe("ClaimAction", func() {
 			Ω(bbs.ClaimedRunOnce.ExecutorID).Should(Equal("executor-id"))
 		})
 
+		It("moves the RunOnce to claimed in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(taskRegistry.RunOnceStates[runOnce.Guid]).Should(Equal([]taskregistry.LifecycleState{taskregistry.StateClaimed}))
+			Ω(taskRegistry.UpdatedRunOnces).Should(HaveLen(1))
+			Ω(taskRegistry.UpdatedRunOnces[0].ExecutorID).Should(Equal("executor-id"))
+		})
+
+		It("checks that the RunOnce has not been cancelled before claiming it", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(bbs.IsRunOnceCancelledChecks).Should(Equal([]string{runOnce.Guid}))
+		})
+
+		Context("when the RunOnce has been cancelled", func() {
+			BeforeEach(func() {
+				bbs.CancelledRunOnceGuids = []string{runOnce.Guid}
+			})
+
+			It("sends back an error without claiming it", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(Equal(ErrRunOnceCancelled))
+
+				Ω(bbs.ClaimedRunOnce).Should(BeZero())
+				Ω(taskRegistry.RunOnceStates[runOnce.Guid]).Should(BeEmpty())
+			})
+		})
+
+		Context("when checking for cancellation fails", func() {
+			disaster := errors.New("store is down")
+
+			BeforeEach(func() {
+				bbs.IsRunOnceCancelledErr = disaster
+			})
+
+			It("sends back the error without claiming it", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(Equal(disaster))
+
+				Ω(bbs.ClaimedRunOnce).Should(BeZero())
+			})
+		})
+
 		Context("when registering fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -65,6 +115,13 @@ var _ = Describe("ClaimAction", func() {
 				go action.Perform(result)
 				Ω(<-result).Should(Equal(disaster))
 			})
+
+			It("does not move the RunOnce to claimed", func() {
+				go action.Perform(result)
+				<-result
+
+				Ω(taskRegistry.RunOnceStates[runOnce.Guid]).Should(BeEmpty())
+			})
 		})
 	})
 })
@@ -1,37 +1,46 @@
 package complete_action
 
 import (
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/outbox"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 )
 
 type CompleteAction struct {
-	runOnce *models.RunOnce
-	logger  *steno.Logger
-	bbs     Bbs.ExecutorBBS
+	runOnce      *models.RunOnce
+	logger       *steno.Logger
+	outbox       outbox.OutboxInterface
+	taskRegistry taskregistry.TaskRegistryInterface
 }
 
 func New(
 	runOnce *models.RunOnce,
 	logger *steno.Logger,
-	bbs Bbs.ExecutorBBS,
+	outbox outbox.OutboxInterface,
+	taskRegistry taskregistry.TaskRegistryInterface,
 ) *CompleteAction {
 	return &CompleteAction{
-		runOnce: runOnce,
-		logger:  logger,
-		bbs:     bbs,
+		runOnce:      runOnce,
+		logger:       logger,
+		outbox:       outbox,
+		taskRegistry: taskRegistry,
 	}
 }
 
+// Perform hands the completed RunOnce to the outbox, which keeps delivering it
+// to the BBS until it gets through.
 func (action CompleteAction) Perform(result chan<- error) {
-	err := action.bbs.CompleteRunOnce(*action.runOnce)
+	action.taskRegistry.SetRunOnceState(action.runOnce.Guid, taskregistry.StateCompleting)
+
+	err := action.outbox.Complete(*action.runOnce)
 	if err != nil {
 		action.logger.Errord(
 			map[string]interface{}{
 				"runonce-guid": action.runOnce.Guid,
 				"error":        err.Error(),
-			}, "runonce.claim.failed",
+			}, "runonce.complete.record-failed",
 		)
 	}
 
@@ -6,11 +6,13 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fakebbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
 
+	"github.com/cloudfoundry-incubator/executor/outbox/fakeoutbox"
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler/complete_action"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+	"github.com/cloudfoundry-incubator/executor/taskregistry/faketaskregistry"
 )
 
 var _ = Describe("CompleteAction", func() {
@@ -18,7 +20,8 @@ var _ = Describe("CompleteAction", func() {
 	var result chan error
 
 	var runOnce models.RunOnce
-	var bbs *fakebbs.FakeExecutorBBS
+	var outbox *fakeoutbox.FakeOutbox
+	var taskRegistry *faketaskregistry.FakeTaskRegistry
 
 	BeforeEach(func() {
 		result = make(chan error)
@@ -46,31 +49,43 @@ var _ = Describe("CompleteAction", func() {
 			FailureReason: "because i said so",
 		}
 
-		bbs = fakebbs.NewFakeExecutorBBS()
+		outbox = fakeoutbox.New()
+		taskRegistry = faketaskregistry.New()
 
 		action = New(
 			&runOnce,
 			steno.NewLogger("test-logger"),
-			bbs,
+			outbox,
+			taskRegistry,
 		)
 	})
 
 	Describe("Perform", func() {
-		It("completes the RunOnce in the BBS and updates the RunOnce's Failed/FailureReason/Result", func() {
+		It("completes the RunOnce through the outbox with its Failed/FailureReason/Result", func() {
 			go action.Perform(result)
 			Ω(<-result).Should(BeNil())
 
-			Ω(bbs.CompletedRunOnce.Guid).Should(Equal(runOnce.Guid))
-			Ω(bbs.CompletedRunOnce.Result).Should(Equal(runOnce.Result))
-			Ω(bbs.CompletedRunOnce.Failed).Should(Equal(runOnce.Failed))
-			Ω(bbs.CompletedRunOnce.FailureReason).Should(Equal(runOnce.FailureReason))
+			Ω(outbox.CompletedRunOnces).Should(HaveLen(1))
+			completedRunOnce := outbox.CompletedRunOnces[0]
+
+			Ω(completedRunOnce.Guid).Should(Equal(runOnce.Guid))
+			Ω(completedRunOnce.Result).Should(Equal(runOnce.Result))
+			Ω(completedRunOnce.Failed).Should(Equal(runOnce.Failed))
+			Ω(completedRunOnce.FailureReason).Should(Equal(runOnce.FailureReason))
+		})
+
+		It("moves the RunOnce to completing in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(taskRegistry.RunOnceStates[runOnce.Guid]).Should(Equal([]taskregistry.LifecycleState{taskregistry.StateCompleting}))
 		})
 
-		Context("when completing fails", func() {
+		Context("when the completion cannot be recorded", func() {
 			disaster := errors.New("oh no!")
 
 			BeforeEach(func() {
-				bbs.CompleteRunOnceErr = disaster
+				outbox.CompleteErr = disaster
 			})
 
 			It("sends back the error", func() {
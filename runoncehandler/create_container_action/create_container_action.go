@@ -1,32 +1,74 @@
 package create_container_action
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"errors"
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/connection"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon"
+
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/containerpool"
+	"github.com/cloudfoundry-incubator/executor/retention"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 )
 
+// LimitError is sent back when warden rejects, or does not apply, the limits
+// the RunOnce declared.  Unlike failing to reach warden, the RunOnce would fail
+// the same way on any executor, so it should be completed as failed rather
+// than handed back.
+type LimitError struct {
+	Message string
+}
+
+func (err LimitError) Error() string {
+	return err.Message
+}
+
 type ContainerAction struct {
-	runOnce      *models.RunOnce
-	logger       *steno.Logger
-	wardenClient gordon.Client
+	runOnce          *models.RunOnce
+	logger           *steno.Logger
+	wardenClient     gordon.Client
+	containerFactory *containerfactory.ContainerFactory
+	containerPool    containerpool.ContainerPoolInterface
+	cacheManager     *cache.Manager
+	retentionPolicy  retention.Policy
+	taskRegistry     taskregistry.TaskRegistryInterface
 }
 
 func New(
 	runOnce *models.RunOnce,
 	logger *steno.Logger,
 	wardenClient gordon.Client,
+	containerFactory *containerfactory.ContainerFactory,
+	containerPool containerpool.ContainerPoolInterface,
+	cacheManager *cache.Manager,
+	retentionPolicy retention.Policy,
+	taskRegistry taskregistry.TaskRegistryInterface,
 ) *ContainerAction {
 	return &ContainerAction{
-		runOnce:      runOnce,
-		logger:       logger,
-		wardenClient: wardenClient,
+		runOnce:          runOnce,
+		logger:           logger,
+		wardenClient:     wardenClient,
+		containerFactory: containerFactory,
+		containerPool:    containerPool,
+		cacheManager:     cacheManager,
+		retentionPolicy:  retentionPolicy,
+		taskRegistry:     taskRegistry,
 	}
 }
 
+// Perform gives the RunOnce an idle container from the pool, or creates one if
+// the pool has none or the RunOnce mounts caches, and limits it to what the
+// RunOnce declared, with as many inbound ports mapped into it as it asked for.
+// If the container cannot be limited or its ports cannot be mapped, it is
+// destroyed and the error is sent back, as nothing has run in it yet; limits
+// that warden rejects or does not apply are sent back as a LimitError.
 func (action ContainerAction) Perform(result chan<- error) {
-	createResponse, err := action.wardenClient.Create()
-
+	handle, err := action.containerHandle()
 	if err != nil {
 		action.logger.Errord(
 			map[string]interface{}{
@@ -35,16 +77,84 @@ func (action ContainerAction) Perform(result chan<- error) {
 			},
 			"runonce.container-create.failed",
 		)
-	} else {
-		action.runOnce.ContainerHandle = createResponse.GetHandle()
+
+		result <- err
+		return
+	}
+
+	action.runOnce.ContainerHandle = handle
+
+	// remember which container belongs to the RunOnce, in case the executor
+	// restarts while it is running
+	action.taskRegistry.UpdateRunOnce(*action.runOnce)
+	action.taskRegistry.SetRunOnceState(action.runOnce.Guid, taskregistry.StateContainerCreated)
+
+	err = action.limitContainer()
+	if err != nil {
+		action.logger.Errord(
+			map[string]interface{}{
+				"runonce-guid": action.runOnce.Guid,
+				"handle":       action.runOnce.ContainerHandle,
+				"error":        err.Error(),
+			},
+			"runonce.container-limit.failed",
+		)
+
+		action.destroyContainer()
+		action.cacheManager.Release(action.runOnce.Guid)
+
+		result <- err
+		return
 	}
 
-	result <- err
+	err = action.mapPorts()
+	if err != nil {
+		action.logger.Errord(
+			map[string]interface{}{
+				"runonce-guid": action.runOnce.Guid,
+				"handle":       action.runOnce.ContainerHandle,
+				"error":        err.Error(),
+			},
+			"runonce.container-net-in.failed",
+		)
+
+		action.destroyContainer()
+		action.cacheManager.Release(action.runOnce.Guid)
+
+		result <- err
+		return
+	}
+
+	result <- nil
 }
 
+// Cancel does not interrupt creating the container; once it is created, it is
+// destroyed by Cleanup like any other.
 func (action ContainerAction) Cancel() {}
 
+// Cleanup destroys the container and frees the RunOnce's caches for others to
+// use, unless the RunOnce failed and the retention policy keeps it for
+// debugging; then the container and its caches are left to be swept up later.
 func (action ContainerAction) Cleanup() {
+	if action.retentionPolicy.ShouldRetain(*action.runOnce) {
+		action.taskRegistry.RetainContainer(*action.runOnce, action.retentionPolicy.TTL)
+
+		action.logger.Infod(
+			map[string]interface{}{
+				"runonce-guid": action.runOnce.Guid,
+				"handle":       action.runOnce.ContainerHandle,
+				"ttl":          action.retentionPolicy.TTL.String(),
+			},
+			"runonce.container.retained",
+		)
+		return
+	}
+
+	action.destroyContainer()
+	action.cacheManager.Release(action.runOnce.Guid)
+}
+
+func (action ContainerAction) destroyContainer() {
 	_, err := action.wardenClient.Destroy(action.runOnce.ContainerHandle)
 	if err != nil {
 		action.logger.Errord(
@@ -57,3 +167,124 @@ func (action ContainerAction) Cleanup() {
 		)
 	}
 }
+
+// containerHandle takes a container from the pool, unless the RunOnce mounts
+// caches: they can only be mounted when the container is created.
+func (action ContainerAction) containerHandle() (string, error) {
+	bindMounts, err := action.cacheManager.Acquire(action.runOnce.Guid, action.runOnce.Caches)
+	if err != nil {
+		return "", err
+	}
+
+	if len(bindMounts) == 0 {
+		handle, found := action.containerPool.Take(action.runOnce.Stack)
+		if found {
+			return handle, nil
+		}
+	}
+
+	handle, err := action.containerFactory.Create(action.runOnce.Stack, bindMounts)
+	if err != nil {
+		action.cacheManager.Release(action.runOnce.Guid)
+		return "", err
+	}
+
+	return handle, nil
+}
+
+func (action ContainerAction) limitContainer() error {
+	handle := action.runOnce.ContainerHandle
+
+	if action.runOnce.MemoryMB > 0 {
+		limitInBytes := megabytesToBytes(action.runOnce.MemoryMB)
+
+		_, err := action.wardenClient.LimitMemory(handle, limitInBytes)
+		if err != nil {
+			return limitFailed(err, fmt.Sprintf("failed to limit container memory to %d MB: %s", action.runOnce.MemoryMB, err.Error()))
+		}
+
+		actualLimit, err := action.wardenClient.GetMemoryLimit(handle)
+		if err != nil {
+			return limitFailed(err, fmt.Sprintf("failed to verify container memory limit: %s", err.Error()))
+		}
+
+		if actualLimit != limitInBytes {
+			return LimitError{Message: fmt.Sprintf("container memory limit was not applied: wanted %d bytes, got %d bytes", limitInBytes, actualLimit)}
+		}
+	}
+
+	if action.runOnce.DiskMB > 0 {
+		limitInBytes := megabytesToBytes(action.runOnce.DiskMB)
+
+		_, err := action.wardenClient.LimitDisk(handle, limitInBytes)
+		if err != nil {
+			return limitFailed(err, fmt.Sprintf("failed to limit container disk to %d MB: %s", action.runOnce.DiskMB, err.Error()))
+		}
+
+		actualLimit, err := action.wardenClient.GetDiskLimit(handle)
+		if err != nil {
+			return limitFailed(err, fmt.Sprintf("failed to verify container disk limit: %s", err.Error()))
+		}
+
+		if actualLimit != limitInBytes {
+			return LimitError{Message: fmt.Sprintf("container disk limit was not applied: wanted %d bytes, got %d bytes", limitInBytes, actualLimit)}
+		}
+	}
+
+	if action.runOnce.CpuWeight > 0 {
+		limitInShares := uint64(action.runOnce.CpuWeight)
+
+		response, err := action.wardenClient.LimitCpu(handle, limitInShares)
+		if err != nil {
+			return limitFailed(err, fmt.Sprintf("failed to limit container cpu weight to %d: %s", action.runOnce.CpuWeight, err.Error()))
+		}
+
+		if response.GetLimitInShares() != limitInShares {
+			return LimitError{Message: fmt.Sprintf("container cpu weight was not applied: wanted %d shares, got %d shares", limitInShares, response.GetLimitInShares())}
+		}
+	}
+
+	return nil
+}
+
+// mapPorts maps a host port to a container port for each inbound port the
+// RunOnce asked for, and records the mappings on the RunOnce so that its run
+// actions can find them.
+func (action ContainerAction) mapPorts() error {
+	portMappings := []models.PortMapping{}
+
+	for i := 0; i < action.runOnce.InboundPorts; i++ {
+		response, err := action.wardenClient.NetIn(action.runOnce.ContainerHandle)
+		if err != nil {
+			return fmt.Errorf("failed to map inbound port %d of %d: %s", i+1, action.runOnce.InboundPorts, err.Error())
+		}
+
+		portMappings = append(portMappings, models.PortMapping{
+			HostPort:      response.GetHostPort(),
+			ContainerPort: response.GetContainerPort(),
+		})
+	}
+
+	if len(portMappings) == 0 {
+		return nil
+	}
+
+	action.runOnce.PortMappings = portMappings
+	action.taskRegistry.UpdateRunOnce(*action.runOnce)
+
+	return nil
+}
+
+// limitFailed turns a failed limit call into a LimitError if warden rejected
+// it, as opposed to not being reachable
+func limitFailed(err error, message string) error {
+	if _, rejected := err.(*connection.WardenError); rejected {
+		return LimitError{Message: message}
+	}
+
+	return errors.New(message)
+}
+
+func megabytesToBytes(megabytes int) uint64 {
+	return uint64(megabytes) * 1024 * 1024
+}
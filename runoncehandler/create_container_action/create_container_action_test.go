@@ -2,15 +2,27 @@ package create_container_action_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	wardenclient "github.com/cloudfoundry-incubator/executor/forks/gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/connection"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon/fake_gordon"
 
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/containerpool/fakecontainerpool"
+	"github.com/cloudfoundry-incubator/executor/retention"
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler/create_container_action"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+	"github.com/cloudfoundry-incubator/executor/taskregistry/faketaskregistry"
 )
 
 var _ = Describe("CreateContainerAction", func() {
@@ -19,9 +31,22 @@ var _ = Describe("CreateContainerAction", func() {
 
 	var runOnce models.RunOnce
 	var gordon *fake_gordon.FakeGordon
+	var containerPool *fakecontainerpool.FakeContainerPool
+	var cacheRoot string
+	var cacheManager *cache.Manager
+	var taskRegistry *faketaskregistry.FakeTaskRegistry
 
 	BeforeEach(func() {
 		gordon = fake_gordon.New()
+		containerPool = fakecontainerpool.New()
+
+		var err error
+		cacheRoot, err = ioutil.TempDir("", "executor-caches")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		cacheManager, err = cache.New(cacheRoot, 10, steno.NewLogger("test-logger"))
+		Ω(err).ShouldNot(HaveOccurred())
+		taskRegistry = faketaskregistry.New()
 
 		result = make(chan error)
 
@@ -36,6 +61,10 @@ var _ = Describe("CreateContainerAction", func() {
 				},
 			},
 
+			MemoryMB:  256,
+			DiskMB:    1024,
+			CpuWeight: 5,
+
 			ExecutorID: "some-executor-id",
 		}
 
@@ -43,15 +72,316 @@ var _ = Describe("CreateContainerAction", func() {
 			&runOnce,
 			steno.NewLogger("test-logger"),
 			gordon,
+			containerfactory.New(gordon, "executor-", containerfactory.NewStacks("penguin", nil)),
+			containerPool,
+			cacheManager,
+			retention.DefaultPolicy,
+			taskRegistry,
 		)
 	})
 
+	AfterEach(func() {
+		os.RemoveAll(cacheRoot)
+	})
+
 	Describe("Perform", func() {
 		It("creates a container and updates the RunOnce's ContainerHandle", func() {
 			go action.Perform(result)
 			Ω(<-result).Should(BeNil())
 
 			Ω(gordon.CreatedHandles()).Should(HaveLen(1))
+			Ω(runOnce.ContainerHandle).Should(Equal(gordon.CreatedHandles()[0]))
+		})
+
+		It("creates the container with the executor's handle prefix", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(runOnce.ContainerHandle).Should(ContainSubstring("executor-"))
+		})
+
+		Context("when the RunOnce mounts caches", func() {
+			BeforeEach(func() {
+				runOnce.Caches = []models.CacheMount{
+					{Key: "app-123", ContainerPath: "/tmp/cache"},
+				}
+
+				containerPool.IdleHandles["penguin"] = []string{"pooled-handle"}
+			})
+
+			It("creates a container with the caches mounted, instead of taking one from the pool", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(containerPool.TakenStacks).Should(BeEmpty())
+				Ω(gordon.CreatedContainers()).Should(HaveLen(1))
+				Ω(gordon.CreatedContainers()[0].BindMounts).Should(Equal([]wardenclient.BindMount{
+					{SrcPath: filepath.Join(cacheRoot, "app-123"), DstPath: "/tmp/cache"},
+				}))
+			})
+
+			Context("when a cache is in use", func() {
+				BeforeEach(func() {
+					_, err := cacheManager.Acquire("another-run-once", runOnce.Caches)
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				It("sends back the error without creating a container", func() {
+					go action.Perform(result)
+					Ω(<-result).Should(Equal(cache.ErrCacheInUse))
+
+					Ω(gordon.CreatedHandles()).Should(BeEmpty())
+				})
+			})
+
+			Context("when the container cannot be created", func() {
+				BeforeEach(func() {
+					gordon.CreateError = errors.New("no more cgroups")
+				})
+
+				It("frees the caches", func() {
+					go action.Perform(result)
+					Ω(<-result).Should(Equal(gordon.CreateError))
+
+					Ω(cacheManager.Entries()[0].Writing).Should(BeFalse())
+				})
+			})
+		})
+
+		Context("when the pool has an idle container for the RunOnce's stack", func() {
+			BeforeEach(func() {
+				containerPool.IdleHandles["penguin"] = []string{"pooled-handle"}
+			})
+
+			It("takes the container instead of creating one", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(gordon.CreatedHandles()).Should(BeEmpty())
+				Ω(runOnce.ContainerHandle).Should(Equal("pooled-handle"))
+				Ω(containerPool.TakenStacks).Should(Equal([]string{"penguin"}))
+			})
+
+			It("limits the container to what the RunOnce declared", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(gordon.MemoryLimits()["pooled-handle"]).Should(BeNumerically("==", 256*1024*1024))
+				Ω(gordon.DiskLimits()["pooled-handle"]).Should(BeNumerically("==", 1024*1024*1024))
+			})
+		})
+
+		It("records the ContainerHandle in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(taskRegistry.UpdatedRunOnces).Should(HaveLen(1))
+			Ω(taskRegistry.UpdatedRunOnces[0].ContainerHandle).Should(Equal(gordon.CreatedHandles()[0]))
+		})
+
+		It("moves the RunOnce to container-created in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(taskRegistry.RunOnceStates[runOnce.Guid]).Should(Equal([]taskregistry.LifecycleState{taskregistry.StateContainerCreated}))
+		})
+
+		It("limits the container's memory and disk to what the RunOnce declared", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(gordon.MemoryLimits()[runOnce.ContainerHandle]).Should(BeNumerically("==", 256*1024*1024))
+			Ω(gordon.DiskLimits()[runOnce.ContainerHandle]).Should(BeNumerically("==", 1024*1024*1024))
+			Ω(runOnce.Failed).Should(BeFalse())
+		})
+
+		It("limits the container's cpu shares to the RunOnce's cpu weight", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(gordon.CpuLimits()[runOnce.ContainerHandle]).Should(BeNumerically("==", 5))
+		})
+
+		Context("when the RunOnce does not declare memory, disk or cpu weight", func() {
+			BeforeEach(func() {
+				runOnce.MemoryMB = 0
+				runOnce.DiskMB = 0
+				runOnce.CpuWeight = 0
+			})
+
+			It("does not limit the container", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(gordon.MemoryLimits()).Should(BeEmpty())
+				Ω(gordon.DiskLimits()).Should(BeEmpty())
+				Ω(gordon.CpuLimits()).Should(BeEmpty())
+			})
+		})
+
+		Context("when limiting memory fails", func() {
+			BeforeEach(func() {
+				gordon.LimitMemoryError = errors.New("out of cgroups")
+			})
+
+			It("sends back the error with the reason", func() {
+				go action.Perform(result)
+
+				err := <-result
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("failed to limit container memory"))
+				Ω(err.Error()).Should(ContainSubstring("out of cgroups"))
+			})
+
+			It("destroys the container, as nothing has run in it", func() {
+				go action.Perform(result)
+				<-result
+
+				Ω(gordon.DestroyedHandles()).Should(Equal([]string{runOnce.ContainerHandle}))
+			})
+
+			It("frees the RunOnce's caches", func() {
+				runOnce.Caches = []models.CacheMount{
+					{Key: "app-123", ContainerPath: "/tmp/cache"},
+				}
+
+				go action.Perform(result)
+				<-result
+
+				Ω(cacheManager.Entries()[0].Writing).Should(BeFalse())
+			})
+
+			It("does not send back a LimitError, as warden may just be unreachable", func() {
+				go action.Perform(result)
+
+				err := <-result
+				_, isLimitError := err.(LimitError)
+				Ω(isLimitError).Should(BeFalse())
+			})
+
+			Context("because warden rejects the limit", func() {
+				BeforeEach(func() {
+					gordon.LimitMemoryError = &connection.WardenError{Message: "out of cgroups"}
+				})
+
+				It("sends back a LimitError with the reason", func() {
+					go action.Perform(result)
+
+					err := <-result
+					Ω(err).Should(BeAssignableToTypeOf(LimitError{}))
+					Ω(err.Error()).Should(ContainSubstring("failed to limit container memory"))
+					Ω(err.Error()).Should(ContainSubstring("out of cgroups"))
+				})
+
+				It("destroys the container", func() {
+					go action.Perform(result)
+					<-result
+
+					Ω(gordon.DestroyedHandles()).Should(Equal([]string{runOnce.ContainerHandle}))
+				})
+			})
+		})
+
+		Context("when the memory limit cannot be verified", func() {
+			BeforeEach(func() {
+				gordon.GetMemoryLimitError = errors.New("what limit?")
+			})
+
+			It("sends back the error with the reason", func() {
+				go action.Perform(result)
+
+				err := <-result
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("what limit?"))
+			})
+		})
+
+		Context("when limiting disk fails", func() {
+			BeforeEach(func() {
+				gordon.LimitDiskError = errors.New("no quotas here")
+			})
+
+			It("sends back the error with the reason", func() {
+				go action.Perform(result)
+
+				err := <-result
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("failed to limit container disk"))
+				Ω(err.Error()).Should(ContainSubstring("no quotas here"))
+			})
+		})
+
+		Context("when limiting cpu fails", func() {
+			BeforeEach(func() {
+				gordon.LimitCpuError = errors.New("no cpu cgroup")
+			})
+
+			It("sends back the error with the reason", func() {
+				go action.Perform(result)
+
+				err := <-result
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("failed to limit container cpu weight"))
+				Ω(err.Error()).Should(ContainSubstring("no cpu cgroup"))
+			})
+		})
+
+		It("does not map any ports when the RunOnce asks for none", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(gordon.NetInHandles()).Should(BeEmpty())
+			Ω(runOnce.PortMappings).Should(BeEmpty())
+		})
+
+		Context("when the RunOnce asks for inbound ports", func() {
+			BeforeEach(func() {
+				runOnce.InboundPorts = 2
+			})
+
+			It("maps a port into the container for each", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(gordon.NetInHandles()).Should(Equal([]string{runOnce.ContainerHandle, runOnce.ContainerHandle}))
+			})
+
+			It("records the port mappings on the RunOnce and in the registry", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				portMappings := []models.PortMapping{
+					{HostPort: 60001, ContainerPort: 61001},
+					{HostPort: 60002, ContainerPort: 61002},
+				}
+
+				Ω(runOnce.PortMappings).Should(Equal(portMappings))
+
+				updated := taskRegistry.UpdatedRunOnces[len(taskRegistry.UpdatedRunOnces)-1]
+				Ω(updated.PortMappings).Should(Equal(portMappings))
+			})
+
+			Context("when mapping a port fails", func() {
+				BeforeEach(func() {
+					gordon.NetInError = errors.New("out of ports")
+				})
+
+				It("sends back the error with the reason", func() {
+					go action.Perform(result)
+
+					err := <-result
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("failed to map inbound port"))
+					Ω(err.Error()).Should(ContainSubstring("out of ports"))
+				})
+
+				It("destroys the container, as nothing has run in it", func() {
+					go action.Perform(result)
+					<-result
+
+					Ω(gordon.DestroyedHandles()).Should(Equal([]string{runOnce.ContainerHandle}))
+				})
+			})
 		})
 
 		Context("when registering fails", func() {
@@ -77,5 +407,72 @@ var _ = Describe("CreateContainerAction", func() {
 
 			Ω(gordon.DestroyedHandles()).Should(Equal(gordon.CreatedHandles()))
 		})
+
+		It("frees the RunOnce's caches", func() {
+			runOnce.Caches = []models.CacheMount{
+				{Key: "app-123", ContainerPath: "/tmp/cache"},
+			}
+
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(cacheManager.Entries()[0].Writing).Should(BeTrue())
+
+			action.Cleanup()
+
+			Ω(cacheManager.Entries()[0].Writing).Should(BeFalse())
+		})
+
+		Context("when failed containers are retained", func() {
+			BeforeEach(func() {
+				policy, err := retention.NewPolicy(retention.RetainAll, time.Hour)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				runOnce.Caches = []models.CacheMount{
+					{Key: "app-123", ContainerPath: "/tmp/cache"},
+				}
+
+				action = New(
+					&runOnce,
+					steno.NewLogger("test-logger"),
+					gordon,
+					containerfactory.New(gordon, "executor-", containerfactory.NewStacks("penguin", nil)),
+					containerPool,
+					cacheManager,
+					policy,
+					taskRegistry,
+				)
+
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+			})
+
+			It("keeps the container of a failed RunOnce", func() {
+				runOnce.Failed = true
+
+				action.Cleanup()
+
+				Ω(gordon.DestroyedHandles()).Should(BeEmpty())
+				Ω(taskRegistry.RetainedRunOnces).Should(HaveLen(1))
+				Ω(taskRegistry.RetainedRunOnces[0].ContainerHandle).Should(Equal(gordon.CreatedHandles()[0]))
+				Ω(taskRegistry.RetainedTTLs).Should(Equal([]time.Duration{time.Hour}))
+			})
+
+			It("keeps the caches of a failed RunOnce until its container is swept up", func() {
+				runOnce.Failed = true
+
+				action.Cleanup()
+
+				Ω(cacheManager.Entries()[0].Writing).Should(BeTrue())
+			})
+
+			It("destroys the container of a RunOnce that succeeded", func() {
+				action.Cleanup()
+
+				Ω(gordon.DestroyedHandles()).Should(Equal(gordon.CreatedHandles()))
+				Ω(taskRegistry.RetainedRunOnces).Should(BeEmpty())
+				Ω(cacheManager.Entries()[0].Writing).Should(BeFalse())
+			})
+		})
 	})
 })
@@ -5,14 +5,15 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon"
 
 	"github.com/cloudfoundry-incubator/executor/actionrunner/downloader"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/extractor"
 	"github.com/cloudfoundry-incubator/executor/backend_plugin"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 type DownloadAction struct {
@@ -23,6 +24,9 @@ type DownloadAction struct {
 	backendPlugin   backend_plugin.BackendPlugin
 	wardenClient    gordon.Client
 	logger          *steno.Logger
+
+	cancel     chan struct{}
+	cancelOnce *sync.Once
 }
 
 func New(
@@ -42,6 +46,9 @@ func New(
 		backendPlugin:   backendPlugin,
 		wardenClient:    wardenClient,
 		logger:          logger,
+
+		cancel:     make(chan struct{}),
+		cancelOnce: &sync.Once{},
 	}
 }
 
@@ -56,7 +63,12 @@ func (action *DownloadAction) Perform(result chan<- error) {
 	result <- action.perform()
 }
 
-func (action *DownloadAction) Cancel() {}
+// Cancel aborts the download if it is still in progress.
+func (action *DownloadAction) Cancel() {
+	action.cancelOnce.Do(func() {
+		close(action.cancel)
+	})
+}
 
 func (action *DownloadAction) Cleanup() {}
 
@@ -75,7 +87,7 @@ func (action *DownloadAction) perform() error {
 		os.RemoveAll(downloadedFile.Name())
 	}()
 
-	err = action.downloader.Download(url, downloadedFile)
+	err = action.downloader.Download(url, downloadedFile, action.cancel)
 	if err != nil {
 		return err
 	}
@@ -7,9 +7,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon/fake_gordon"
 
 	"github.com/cloudfoundry-incubator/executor/actionrunner/downloader/fakedownloader"
 	"github.com/cloudfoundry-incubator/executor/linuxplugin"
@@ -18,7 +18,6 @@ import (
 
 var _ = Describe("DownloadAction", func() {
 	var action *DownloadAction
-	var result chan error
 
 	var downloadAction models.DownloadAction
 	var containerHandle string
@@ -31,8 +30,6 @@ var _ = Describe("DownloadAction", func() {
 	BeforeEach(func() {
 		var err error
 
-		result = make(chan error)
-
 		downloadAction = models.DownloadAction{
 			From:    "http://mr_jones",
 			To:      "/tmp/Antarctica",
@@ -110,4 +107,14 @@ var _ = Describe("DownloadAction", func() {
 			})
 		})
 	})
+
+	Describe("Cancel", func() {
+		It("aborts the download", func() {
+			perform()
+			Ω(downloader.Cancel).ShouldNot(BeClosed())
+
+			action.Cancel()
+			Ω(downloader.Cancel).Should(BeClosed())
+		})
+	})
 })
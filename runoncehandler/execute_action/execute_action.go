@@ -2,11 +2,13 @@ package execute_action
 
 import (
 	"strconv"
+	"sync"
 
 	"github.com/cloudfoundry-incubator/executor/actionrunner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/logstreamer"
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 	steno "github.com/cloudfoundry/gosteno"
 	"github.com/cloudfoundry/loggregatorlib/emitter"
 )
@@ -16,8 +18,14 @@ type ExecuteAction struct {
 	logger            *steno.Logger
 	bbs               Bbs.ExecutorBBS
 	actionRunner      actionrunner.ActionRunnerInterface
+	taskRegistry      taskregistry.TaskRegistryInterface
 	loggregatorServer string
 	loggregatorSecret string
+
+	resumeProcess *taskregistry.Process
+
+	cancel     chan struct{}
+	cancelOnce *sync.Once
 }
 
 func New(
@@ -25,6 +33,7 @@ func New(
 	logger *steno.Logger,
 	bbs Bbs.ExecutorBBS,
 	actionRunner actionrunner.ActionRunnerInterface,
+	taskRegistry taskregistry.TaskRegistryInterface,
 	loggregatorServer string,
 	loggregatorSecret string,
 ) *ExecuteAction {
@@ -33,13 +42,39 @@ func New(
 		logger:            logger,
 		bbs:               bbs,
 		actionRunner:      actionRunner,
+		taskRegistry:      taskRegistry,
 		loggregatorServer: loggregatorServer,
 		loggregatorSecret: loggregatorSecret,
+
+		cancel:     make(chan struct{}),
+		cancelOnce: &sync.Once{},
 	}
 }
 
+// Resume returns an ExecuteAction for a RunOnce that was already started
+// before the executor restarted.  It picks the actions back up by attaching
+// to the given process, rather than starting the RunOnce again.
+func Resume(
+	runOnce *models.RunOnce,
+	process taskregistry.Process,
+	logger *steno.Logger,
+	bbs Bbs.ExecutorBBS,
+	actionRunner actionrunner.ActionRunnerInterface,
+	taskRegistry taskregistry.TaskRegistryInterface,
+	loggregatorServer string,
+	loggregatorSecret string,
+) *ExecuteAction {
+	action := New(runOnce, logger, bbs, actionRunner, taskRegistry, loggregatorServer, loggregatorSecret)
+	action.resumeProcess = &process
+	return action
+}
+
 func (action ExecuteAction) Perform(result chan<- error) {
-	err := action.bbs.StartRunOnce(*action.runOnce)
+	var err error
+	if action.resumeProcess == nil {
+		err = action.bbs.StartRunOnce(*action.runOnce)
+	}
+
 	if err != nil {
 		action.logger.Warnd(
 			map[string]interface{}{
@@ -48,6 +83,8 @@ func (action ExecuteAction) Perform(result chan<- error) {
 			}, "runonce.start.failed",
 		)
 	} else {
+		action.taskRegistry.SetRunOnceState(action.runOnce.Guid, taskregistry.StateRunning)
+
 		var streamer logstreamer.LogStreamer
 
 		if action.runOnce.Log.SourceName != "" {
@@ -56,22 +93,70 @@ func (action ExecuteAction) Perform(result chan<- error) {
 
 		action.logger.Errord(map[string]interface{}{"result": action.runOnce.Actions}, "execute-action.RUNNIGN!!!!!!!!!!")
 
-		result, err := action.actionRunner.Run(action.runOnce.ContainerHandle, streamer, action.runOnce.Actions)
+		var result string
+		var err error
+		if action.resumeProcess == nil {
+			result, err = action.actionRunner.Run(action.runOnce.ContainerHandle, action.runOnce.PortMappings, streamer, action.runOnce.Actions, action, action.cancel)
+		} else {
+			result, err = action.actionRunner.Resume(action.runOnce.ContainerHandle, action.runOnce.PortMappings, streamer, action.runOnce.Actions, action.resumeProcess.ActionIndex, action.resumeProcess.ProcessID, action, action.cancel)
+		}
 
 		action.logger.Errord(map[string]interface{}{"result": result}, "execute-action.RAN!!!!!!!!!!!!!!")
 
-		action.runOnce.Result = result
-		if err != nil {
-			action.logger.Errord(map[string]interface{}{"runonce-guid": action.runOnce.Guid, "handle": action.runOnce.ContainerHandle, "error": err.Error()}, "runonce.actions.failed")
-			action.runOnce.Failed = true
-			action.runOnce.FailureReason = err.Error()
+		// whoever cancelled the RunOnce is responsible for completing it
+		if !action.isCancelled() {
+			action.runOnce.Result = result
+			if err != nil {
+				action.logger.Errord(map[string]interface{}{"runonce-guid": action.runOnce.Guid, "handle": action.runOnce.ContainerHandle, "error": err.Error()}, "runonce.actions.failed")
+				action.runOnce.Failed = true
+				action.runOnce.FailureReason = err.Error()
+			}
 		}
 	}
 
 	result <- err
 }
 
-func (action ExecuteAction) Cancel() {}
+// ActionStarted records which of the RunOnce's actions is being run.
+func (action ExecuteAction) ActionStarted(actionIndex int) {
+	action.taskRegistry.SetRunOnceAction(action.runOnce.Guid, actionIndex)
+}
+
+// ProcessStarted records the RunOnce's process in the registry, and snapshots
+// the registry so the process can be reattached to if the executor dies.
+func (action ExecuteAction) ProcessStarted(actionIndex int, processID uint32) {
+	action.taskRegistry.RecordProcess(action.runOnce.Guid, taskregistry.Process{
+		ActionIndex: actionIndex,
+		ProcessID:   processID,
+	})
+
+	err := action.taskRegistry.WriteToDisk()
+	if err != nil {
+		action.logger.Warnd(
+			map[string]interface{}{
+				"runonce-guid": action.runOnce.Guid,
+				"error":        err.Error(),
+			}, "runonce.snapshot.failed",
+		)
+	}
+}
+
+// Cancel interrupts the RunOnce's actions, stopping any process or transfer
+// in progress.
+func (action ExecuteAction) Cancel() {
+	action.cancelOnce.Do(func() {
+		close(action.cancel)
+	})
+}
+
+func (action ExecuteAction) isCancelled() bool {
+	select {
+	case <-action.cancel:
+		return true
+	default:
+		return false
+	}
+}
 
 func (action ExecuteAction) Cleanup() {}
 
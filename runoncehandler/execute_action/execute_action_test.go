@@ -9,12 +9,15 @@ import (
 	"github.com/onsi/ginkgo/config"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fakebbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs/fakebbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
 
+	"github.com/cloudfoundry-incubator/executor/action_runner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/fakeactionrunner"
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler/execute_action"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+	"github.com/cloudfoundry-incubator/executor/taskregistry/faketaskregistry"
 )
 
 var _ = Describe("ExecuteAction", func() {
@@ -24,6 +27,7 @@ var _ = Describe("ExecuteAction", func() {
 	var runOnce models.RunOnce
 	var bbs *fakebbs.FakeExecutorBBS
 	var actionRunner *fakeactionrunner.FakeActionRunner // TODO: this may go away
+	var taskRegistry *faketaskregistry.FakeTaskRegistry
 	var loggregatorServer string
 	var loggregatorSecret string
 
@@ -47,11 +51,16 @@ var _ = Describe("ExecuteAction", func() {
 			ExecutorID: "some-executor-id",
 
 			ContainerHandle: "some-container-handle",
+
+			PortMappings: []models.PortMapping{
+				{HostPort: 60001, ContainerPort: 8080},
+			},
 		}
 
 		bbs = fakebbs.NewFakeExecutorBBS()
 
 		actionRunner = fakeactionrunner.New()
+		taskRegistry = faketaskregistry.New()
 
 		loggregatorPort := 3456 + config.GinkgoConfig.ParallelNode
 		loggregatorServer = fmt.Sprintf("127.0.0.1:%d", loggregatorPort)
@@ -62,6 +71,7 @@ var _ = Describe("ExecuteAction", func() {
 			steno.NewLogger("test-logger"),
 			bbs,
 			actionRunner,
+			taskRegistry,
 			loggregatorServer,
 			loggregatorSecret,
 		)
@@ -83,6 +93,39 @@ var _ = Describe("ExecuteAction", func() {
 			Ω(actionRunner.Actions).Should(Equal(runOnce.Actions))
 		})
 
+		It("runs the actions with the container's port mappings", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(actionRunner.PortMappings).Should(Equal(runOnce.PortMappings))
+		})
+
+		It("moves the RunOnce to running in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(taskRegistry.RunOnceStates[runOnce.Guid]).Should(Equal([]taskregistry.LifecycleState{taskregistry.StateRunning}))
+		})
+
+		It("records the action being run in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			actionRunner.Tracker.ActionStarted(0)
+
+			Ω(taskRegistry.RunOnceActions[runOnce.Guid]).Should(Equal([]int{0}))
+		})
+
+		It("records the processes the actions start in the registry", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			actionRunner.Tracker.ProcessStarted(0, 42)
+
+			Ω(taskRegistry.RecordedProcesses[runOnce.Guid]).Should(Equal(taskregistry.Process{ActionIndex: 0, ProcessID: 42}))
+			Ω(taskRegistry.WriteToDiskCalls).Should(Equal(1))
+		})
+
 		It("does not initialize with the streamer by default", func() {
 			go action.Perform(result)
 			Ω(<-result).Should(BeNil())
@@ -156,6 +199,38 @@ var _ = Describe("ExecuteAction", func() {
 			})
 		})
 
+		Context("when resuming a RunOnce", func() {
+			BeforeEach(func() {
+				action = Resume(
+					&runOnce,
+					taskregistry.Process{ActionIndex: 0, ProcessID: 42},
+					steno.NewLogger("test-logger"),
+					bbs,
+					actionRunner,
+					taskRegistry,
+					loggregatorServer,
+					loggregatorSecret,
+				)
+			})
+
+			It("does not start the RunOnce again", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(bbs.StartedRunOnce).Should(BeZero())
+			})
+
+			It("resumes the actions from the recorded process", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(actionRunner.Resumed).Should(BeTrue())
+				Ω(actionRunner.ResumedActionIndex).Should(Equal(0))
+				Ω(actionRunner.ResumedProcessID).Should(Equal(uint32(42)))
+				Ω(actionRunner.ContainerHandle).Should(Equal(runOnce.ContainerHandle))
+			})
+		})
+
 		Context("when starting the RunOnce in the BBS fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -169,4 +244,30 @@ var _ = Describe("ExecuteAction", func() {
 			})
 		})
 	})
+
+	Describe("Cancel", func() {
+		It("interrupts the actions being run", func() {
+			go action.Perform(result)
+			Ω(<-result).Should(BeNil())
+
+			Ω(actionRunner.Cancel).ShouldNot(BeClosed())
+
+			action.Cancel()
+			Ω(actionRunner.Cancel).Should(BeClosed())
+		})
+
+		Context("when the actions are interrupted", func() {
+			BeforeEach(func() {
+				actionRunner.RunError = action_runner.CancelledError
+				action.Cancel()
+			})
+
+			It("leaves failing the RunOnce to whoever cancelled it", func() {
+				go action.Perform(result)
+				Ω(<-result).Should(BeNil())
+
+				Ω(runOnce.Failed).Should(BeFalse())
+			})
+		})
+	})
 })
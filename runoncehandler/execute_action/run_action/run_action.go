@@ -2,40 +2,99 @@ package run_action
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon"
 	"github.com/vito/gordon/warden"
 
+	"github.com/cloudfoundry-incubator/executor/action_runner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/logstreamer"
 	"github.com/cloudfoundry-incubator/executor/backend_plugin"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
+// ProcessTracker is told about the warden process a RunAction starts, so that
+// it can be attached to again later.
+type ProcessTracker interface {
+	ProcessStarted(processID uint32)
+}
+
 type RunAction struct {
 	model           models.RunAction
 	containerHandle string
+	portMappings    []models.PortMapping
 	streamer        logstreamer.LogStreamer
 	backendPlugin   backend_plugin.BackendPlugin
 	wardenClient    gordon.Client
+	processTracker  ProcessTracker
+	killGracePeriod time.Duration
 	logger          *steno.Logger
+
+	attached  bool
+	processID uint32
+
+	cancelled  chan struct{}
+	cancelOnce *sync.Once
 }
 
+// RunActionTimeoutError is returned when a RunAction's process runs past its
+// timeout.  The process is stopped; Killed says whether it had to be killed
+// because it did not exit within the grace period, and if not, ExitStatus is
+// what it exited with.
 type RunActionTimeoutError struct {
-	Action models.RunAction
+	Action     models.RunAction
+	Killed     bool
+	ExitStatus uint32
 }
 
 func (e RunActionTimeoutError) Error() string {
-	return fmt.Sprintf("action timed out after %s", e.Action.Timeout)
+	if e.Killed {
+		return fmt.Sprintf("action timed out after %s and was killed", e.Action.Timeout)
+	}
+
+	return fmt.Sprintf("action timed out after %s and exited with status %d", e.Action.Timeout, e.ExitStatus)
 }
 
 func New(
 	model models.RunAction,
 	containerHandle string,
+	portMappings []models.PortMapping,
+	streamer logstreamer.LogStreamer,
+	backendPlugin backend_plugin.BackendPlugin,
+	wardenClient gordon.Client,
+	processTracker ProcessTracker,
+	killGracePeriod time.Duration,
+	logger *steno.Logger,
+) *RunAction {
+	return &RunAction{
+		model:           model,
+		containerHandle: containerHandle,
+		portMappings:    portMappings,
+		streamer:        streamer,
+		backendPlugin:   backendPlugin,
+		wardenClient:    wardenClient,
+		processTracker:  processTracker,
+		killGracePeriod: killGracePeriod,
+		logger:          logger,
+
+		cancelled:  make(chan struct{}),
+		cancelOnce: &sync.Once{},
+	}
+}
+
+// NewAttached returns a RunAction for a process that is already running in the
+// container (e.g. one started before the executor restarted).  Instead of
+// running the script again, it attaches to the process's output.
+func NewAttached(
+	model models.RunAction,
+	containerHandle string,
+	processID uint32,
 	streamer logstreamer.LogStreamer,
 	backendPlugin backend_plugin.BackendPlugin,
 	wardenClient gordon.Client,
+	killGracePeriod time.Duration,
 	logger *steno.Logger,
 ) *RunAction {
 	return &RunAction{
@@ -44,11 +103,18 @@ func New(
 		streamer:        streamer,
 		backendPlugin:   backendPlugin,
 		wardenClient:    wardenClient,
+		killGracePeriod: killGracePeriod,
 		logger:          logger,
+
+		attached:  true,
+		processID: processID,
+
+		cancelled:  make(chan struct{}),
+		cancelOnce: &sync.Once{},
 	}
 }
 
-func (action *RunAction) Perform(result chan error) {
+func (action *RunAction) Perform(result chan<- error) {
 	action.logger.Infod(
 		map[string]interface{}{
 			"handle": action.containerHandle,
@@ -59,7 +125,15 @@ func (action *RunAction) Perform(result chan error) {
 	result <- action.perform()
 }
 
-func (action *RunAction) Cancel() {}
+// Cancel kills the action's process by stopping the container, and makes
+// Perform return without waiting for the process's exit status.
+func (action *RunAction) Cancel() {
+	action.cancelOnce.Do(func() {
+		close(action.cancelled)
+	})
+
+	action.stop(true)
+}
 
 func (action *RunAction) Cleanup() {}
 
@@ -74,11 +148,7 @@ func (action *RunAction) perform() error {
 	}
 
 	go func() {
-		_, stream, err := action.wardenClient.Run(
-			action.containerHandle,
-			action.backendPlugin.BuildRunScript(action.model),
-		)
-
+		stream, err := action.processStream()
 		if err != nil {
 			errChan <- err
 			return
@@ -91,7 +161,7 @@ func (action *RunAction) perform() error {
 				}
 
 				exitStatusChan <- payload.GetExitStatus()
-				break
+				return
 			}
 
 			if action.streamer != nil {
@@ -103,6 +173,12 @@ func (action *RunAction) perform() error {
 				}
 			}
 		}
+
+		// the stream ended without an exit status (e.g. the process was
+		// killed); flush whatever output it had
+		if action.streamer != nil {
+			action.streamer.Flush()
+		}
 	}()
 
 	select {
@@ -117,8 +193,84 @@ func (action *RunAction) perform() error {
 		return err
 
 	case <-timeoutChan:
-		return RunActionTimeoutError{Action: action.model}
+		return action.stopTimedOutProcess(exitStatusChan)
+
+	case <-action.cancelled:
+		return action_runner.CancelledError
 	}
 
 	panic("unreachable")
 }
+
+// stopTimedOutProcess asks the process to stop, and kills it if it has not
+// exited after the grace period.  Either way, the process's output is flushed
+// once it exits.
+func (action *RunAction) stopTimedOutProcess(exitStatusChan <-chan uint32) error {
+	action.logger.Infod(
+		map[string]interface{}{
+			"handle":  action.containerHandle,
+			"timeout": action.model.Timeout.String(),
+		},
+		"runonce.handle.run-action.timed-out",
+	)
+
+	go action.stop(false)
+
+	select {
+	case exitStatus := <-exitStatusChan:
+		return RunActionTimeoutError{Action: action.model, ExitStatus: exitStatus}
+	case <-time.After(action.killGracePeriod):
+	}
+
+	action.stop(true)
+
+	// give the killed process's output a chance to be flushed
+	select {
+	case <-exitStatusChan:
+	case <-time.After(action.killGracePeriod):
+	}
+
+	return RunActionTimeoutError{Action: action.model, Killed: true}
+}
+
+func (action *RunAction) stop(kill bool) {
+	_, err := action.wardenClient.Stop(action.containerHandle, false, kill)
+	if err != nil {
+		action.logger.Errord(
+			map[string]interface{}{
+				"handle": action.containerHandle,
+				"kill":   kill,
+				"error":  err.Error(),
+			},
+			"runonce.handle.run-action.stop-failed",
+		)
+	}
+}
+
+func (action *RunAction) processStream() (<-chan *warden.ProcessPayload, error) {
+	if action.attached {
+		action.logger.Infod(
+			map[string]interface{}{
+				"handle":     action.containerHandle,
+				"process-id": action.processID,
+			},
+			"runonce.handle.run-action.attaching",
+		)
+
+		return action.wardenClient.Attach(action.containerHandle, action.processID)
+	}
+
+	processID, stream, err := action.wardenClient.Run(
+		action.containerHandle,
+		action.backendPlugin.BuildRunScript(action.model, action.portMappings),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if action.processTracker != nil {
+		action.processTracker.ProcessStarted(processID)
+	}
+
+	return stream, nil
+}
@@ -9,11 +9,12 @@ import (
 	. "github.com/onsi/gomega"
 
 	"code.google.com/p/gogoprotobuf/proto"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon/fake_gordon"
 	"github.com/vito/gordon/warden"
 
+	"github.com/cloudfoundry-incubator/executor/action_runner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/logstreamer"
 	"github.com/cloudfoundry-incubator/executor/actionrunner/logstreamer/fakelogstreamer"
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler/execute_action/run_action"
@@ -21,21 +22,21 @@ import (
 
 var _ = Describe("RunAction", func() {
 	var action *RunAction
-	var result chan error
 
 	var runAction models.RunAction
 	var containerHandle string
+	var portMappings []models.PortMapping
 	var fakeStreamer *fakelogstreamer.FakeLogStreamer
 	var streamer logstreamer.LogStreamer
 	var backendPlugin *linuxplugin.LinuxPlugin
 	var wardenClient *fake_gordon.FakeGordon
+	var processTracker *fakeProcessTracker
+	var killGracePeriod time.Duration
 	var logger *steno.Logger
 
 	var processPayloadStream chan *warden.ProcessPayload
 
 	BeforeEach(func() {
-		result = make(chan error)
-
 		runAction = models.RunAction{
 			Script: "sudo reboot",
 			Env: [][]string{
@@ -45,17 +46,23 @@ var _ = Describe("RunAction", func() {
 
 		containerHandle = "some-container-handle"
 
+		portMappings = nil
+
 		fakeStreamer = fakelogstreamer.New()
 
 		wardenClient = fake_gordon.New()
 
 		backendPlugin = linuxplugin.New()
 
+		processTracker = &fakeProcessTracker{}
+
+		killGracePeriod = 100 * time.Millisecond
+
 		logger = steno.NewLogger("test-logger")
 
 		processPayloadStream = make(chan *warden.ProcessPayload, 1000)
 
-		wardenClient.SetRunReturnValues(0, processPayloadStream, nil)
+		wardenClient.SetRunReturnValues(42, processPayloadStream, nil)
 	})
 
 	successfulExit := &warden.ProcessPayload{ExitStatus: proto.Uint32(0)}
@@ -65,9 +72,12 @@ var _ = Describe("RunAction", func() {
 		action = New(
 			runAction,
 			containerHandle,
+			portMappings,
 			streamer,
 			backendPlugin,
 			wardenClient,
+			processTracker,
+			killGracePeriod,
 			logger,
 		)
 	})
@@ -87,6 +97,31 @@ var _ = Describe("RunAction", func() {
 				Ω(runningScript.Handle).Should(Equal("some-container-handle"))
 				Ω(runningScript.Script).Should(Equal("export A=\"1\"\nsudo reboot"))
 			})
+
+			Context("when the container has port mappings", func() {
+				BeforeEach(func() {
+					portMappings = []models.PortMapping{
+						{HostPort: 60001, ContainerPort: 8080},
+					}
+				})
+
+				It("exports the ports to the script", func() {
+					result := make(chan error, 1)
+					action.Perform(result)
+					Ω(<-result).ShouldNot(HaveOccurred())
+
+					runningScript := wardenClient.ScriptsThatRan()[0]
+					Ω(runningScript.Script).Should(Equal("export PORT=8080\nexport CONTAINER_PORT_0=8080\nexport HOST_PORT_0=60001\nexport A=\"1\"\nsudo reboot"))
+				})
+			})
+
+			It("tells the process tracker which process was started", func() {
+				result := make(chan error, 1)
+				action.Perform(result)
+				Ω(<-result).ShouldNot(HaveOccurred())
+
+				Ω(processTracker.startedProcessIDs).Should(Equal([]uint32{42}))
+			})
 		})
 
 		Context("when the script has a non-zero exit code", func() {
@@ -138,14 +173,57 @@ var _ = Describe("RunAction", func() {
 
 			Context("and the script takes longer than the timeout", func() {
 				It("returns a RunActionTimeoutError", func() {
-					go func() {
-						time.Sleep(1 * time.Second)
-						processPayloadStream <- successfulExit
-					}()
+					result := make(chan error, 1)
+					action.Perform(result)
+					Ω(<-result).Should(Equal(RunActionTimeoutError{Action: runAction, Killed: true}))
+				})
 
+				It("stops the process gracefully, then kills it after the grace period", func() {
 					result := make(chan error, 1)
 					action.Perform(result)
-					Ω(<-result).Should(Equal(RunActionTimeoutError{runAction}))
+					<-result
+
+					stopped := wardenClient.StoppedContainers()
+					Ω(stopped).Should(HaveLen(2))
+					Ω(stopped).Should(ContainElement(&fake_gordon.StoppedContainer{Handle: "some-container-handle", Kill: false}))
+					Ω(stopped).Should(ContainElement(&fake_gordon.StoppedContainer{Handle: "some-container-handle", Kill: true}))
+				})
+
+				Context("when the process exits within the grace period", func() {
+					BeforeEach(func() {
+						killGracePeriod = time.Second
+
+						stream := processPayloadStream
+						go func() {
+							time.Sleep(200 * time.Millisecond)
+							stream <- failedExit
+						}()
+					})
+
+					It("does not kill it", func() {
+						result := make(chan error, 1)
+						action.Perform(result)
+						Ω(<-result).Should(Equal(RunActionTimeoutError{Action: runAction, ExitStatus: 19}))
+
+						Ω(wardenClient.StoppedContainers()).Should(Equal([]*fake_gordon.StoppedContainer{
+							{Handle: "some-container-handle", Kill: false},
+						}))
+					})
+				})
+
+				Context("when given an emitter", func() {
+					BeforeEach(func() {
+						streamer = fakeStreamer
+						close(processPayloadStream)
+					})
+
+					It("flushes the output", func() {
+						result := make(chan error, 1)
+						action.Perform(result)
+						<-result
+
+						Ω(fakeStreamer.Flushed).Should(BeTrue())
+					})
 				})
 			})
 		})
@@ -200,6 +278,129 @@ var _ = Describe("RunAction", func() {
 				action.Perform(result)
 				Ω(<-result).Should(Equal(disaster))
 			})
+
+			It("does not tell the process tracker about a process", func() {
+				result := make(chan error, 1)
+				action.Perform(result)
+				<-result
+
+				Ω(processTracker.startedProcessIDs).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Perform when attached to a running process", func() {
+		var attachedStream chan *warden.ProcessPayload
+
+		BeforeEach(func() {
+			attachedStream = make(chan *warden.ProcessPayload, 1000)
+			wardenClient.SetAttachReturnValues(attachedStream)
+
+			streamer = fakeStreamer
+		})
+
+		JustBeforeEach(func() {
+			action = NewAttached(
+				runAction,
+				containerHandle,
+				42,
+				streamer,
+				backendPlugin,
+				wardenClient,
+				killGracePeriod,
+				logger,
+			)
+		})
+
+		It("attaches to the process instead of running the script again", func() {
+			attachedStream <- successfulExit
+
+			result := make(chan error, 1)
+			action.Perform(result)
+			Ω(<-result).ShouldNot(HaveOccurred())
+
+			Ω(wardenClient.ScriptsThatRan()).Should(BeEmpty())
+
+			attachedProcess := wardenClient.AttachedProcesses()[0]
+			Ω(attachedProcess.Handle).Should(Equal("some-container-handle"))
+			Ω(attachedProcess.ProcessID).Should(Equal(uint32(42)))
+		})
+
+		It("emits the output that comes in after attaching", func() {
+			stdout := warden.ProcessPayload_stdout
+
+			attachedStream <- &warden.ProcessPayload{
+				Source: &stdout,
+				Data:   proto.String("still going"),
+			}
+			attachedStream <- successfulExit
+
+			result := make(chan error, 1)
+			action.Perform(result)
+			Ω(<-result).ShouldNot(HaveOccurred())
+
+			Ω(fakeStreamer.StreamedStdout).Should(ContainElement("still going"))
+			Ω(fakeStreamer.Flushed).Should(BeTrue())
+		})
+
+		It("returns an error with the exit code when the process fails", func() {
+			attachedStream <- failedExit
+
+			result := make(chan error, 1)
+			action.Perform(result)
+
+			err := <-result
+			if Ω(err).Should(HaveOccurred()) {
+				Ω(err.Error()).Should(ContainSubstring("19"))
+			}
+		})
+
+		Context("when attaching fails", func() {
+			disaster := errors.New("no such process")
+
+			BeforeEach(func() {
+				wardenClient.AttachError = disaster
+			})
+
+			It("sends back the error", func() {
+				result := make(chan error, 1)
+				action.Perform(result)
+				Ω(<-result).Should(Equal(disaster))
+			})
+		})
+	})
+
+	Describe("Cancel", func() {
+		It("kills the process by stopping the container", func() {
+			action.Cancel()
+
+			Ω(wardenClient.StoppedContainers()).Should(HaveLen(1))
+
+			stopped := wardenClient.StoppedContainers()[0]
+			Ω(stopped.Handle).Should(Equal("some-container-handle"))
+			Ω(stopped.Background).Should(BeFalse())
+			Ω(stopped.Kill).Should(BeTrue())
+		})
+
+		It("makes Perform return while the process is still running", func() {
+			result := make(chan error, 1)
+			go action.Perform(result)
+
+			Eventually(wardenClient.ScriptsThatRan).Should(HaveLen(1))
+
+			action.Cancel()
+
+			var err error
+			Eventually(result).Should(Receive(&err))
+			Ω(err).Should(Equal(action_runner.CancelledError))
 		})
 	})
 })
+
+type fakeProcessTracker struct {
+	startedProcessIDs []uint32
+}
+
+func (tracker *fakeProcessTracker) ProcessStarted(processID uint32) {
+	tracker.startedProcessIDs = append(tracker.startedProcessIDs, processID)
+}
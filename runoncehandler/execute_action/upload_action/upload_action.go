@@ -5,13 +5,14 @@ import (
 	"net/url"
 	"os"
 	"os/user"
+	"sync"
 
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon"
 
 	"github.com/cloudfoundry-incubator/executor/actionrunner/uploader"
 	"github.com/cloudfoundry-incubator/executor/backend_plugin"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 )
 
 type UploadAction struct {
@@ -22,6 +23,9 @@ type UploadAction struct {
 	backendPlugin   backend_plugin.BackendPlugin
 	wardenClient    gordon.Client
 	logger          *steno.Logger
+
+	cancel     chan struct{}
+	cancelOnce *sync.Once
 }
 
 func New(
@@ -39,6 +43,9 @@ func New(
 		tempDir:         tempDir,
 		wardenClient:    wardenClient,
 		logger:          logger,
+
+		cancel:     make(chan struct{}),
+		cancelOnce: &sync.Once{},
 	}
 }
 
@@ -53,7 +60,12 @@ func (action *UploadAction) Perform(result chan<- error) {
 	result <- action.perform()
 }
 
-func (action *UploadAction) Cancel() {}
+// Cancel aborts the upload if it is still in progress.
+func (action *UploadAction) Cancel() {
+	action.cancelOnce.Do(func() {
+		close(action.cancel)
+	})
+}
 
 func (action *UploadAction) Cleanup() {}
 
@@ -86,5 +98,5 @@ func (action *UploadAction) perform() error {
 		return err
 	}
 
-	return action.uploader.Upload(fileToUpload, url)
+	return action.uploader.Upload(fileToUpload, url, action.cancel)
 }
@@ -8,9 +8,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon/fake_gordon"
 
 	"github.com/cloudfoundry-incubator/executor/actionrunner/uploader/fakeuploader"
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler/execute_action/upload_action"
@@ -18,7 +18,6 @@ import (
 
 var _ = Describe("UploadAction", func() {
 	var action *UploadAction
-	var result chan error
 
 	var uploadAction models.UploadAction
 	var containerHandle string
@@ -30,8 +29,6 @@ var _ = Describe("UploadAction", func() {
 	BeforeEach(func() {
 		var err error
 
-		result = make(chan error)
-
 		uploadAction = models.UploadAction{
 			To:   "http://mr_jones",
 			From: "/Antarctica",
@@ -112,4 +109,14 @@ var _ = Describe("UploadAction", func() {
 			})
 		})
 	})
+
+	Describe("Cancel", func() {
+		It("aborts the upload", func() {
+			perform()
+			Ω(uploader.Cancel).ShouldNot(BeClosed())
+
+			action.Cancel()
+			Ω(uploader.Cancel).Should(BeClosed())
+		})
+	})
 })
@@ -1,32 +1,99 @@
 package fakerunoncehandler
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	"sync"
+
+	"github.com/cloudfoundry-incubator/executor/runoncehandler"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 )
 
 type FakeRunOnceHandler struct {
 	numberOfCalls   int
 	handledRunOnces map[string]string
+	resumedRunOnces map[string]taskregistry.Process
 	Lock            *sync.Mutex
+
+	InFlightRunOnces    []runoncehandler.InFlightRunOnce
+	CancelledRunOnces   []string
+	CancelRunOnceResult bool
+
+	// when set, RunOnce does not return until Cancel is called
+	BlockUntilCancelled bool
+	cancelled           chan bool
+	cancelOnce          *sync.Once
 }
 
 func New() *FakeRunOnceHandler {
 	return &FakeRunOnceHandler{
 		handledRunOnces: make(map[string]string),
+		resumedRunOnces: make(map[string]taskregistry.Process),
 		Lock:            &sync.Mutex{},
+		cancelled:       make(chan bool),
+		cancelOnce:      &sync.Once{},
 	}
 }
 
 func (handler *FakeRunOnceHandler) RunOnce(runOnce models.RunOnce, executorId string) {
 	handler.Lock.Lock()
-	defer handler.Lock.Unlock()
 
 	_, present := handler.handledRunOnces[runOnce.Guid]
 	if !present {
 		handler.numberOfCalls++
 		handler.handledRunOnces[runOnce.Guid] = executorId
 	}
+
+	block := handler.BlockUntilCancelled
+
+	handler.Lock.Unlock()
+
+	if block {
+		<-handler.cancelled
+	}
+}
+
+func (handler *FakeRunOnceHandler) Resume(runOnce models.RunOnce, process taskregistry.Process) {
+	handler.Lock.Lock()
+	defer handler.Lock.Unlock()
+
+	handler.resumedRunOnces[runOnce.Guid] = process
+}
+
+func (handler *FakeRunOnceHandler) ResumedRunOnces() map[string]taskregistry.Process {
+	handler.Lock.Lock()
+	defer handler.Lock.Unlock()
+
+	return handler.resumedRunOnces
+}
+
+func (handler *FakeRunOnceHandler) InFlight() []runoncehandler.InFlightRunOnce {
+	handler.Lock.Lock()
+	defer handler.Lock.Unlock()
+
+	return handler.InFlightRunOnces
+}
+
+func (handler *FakeRunOnceHandler) CancelRunOnce(guid string) bool {
+	handler.Lock.Lock()
+	defer handler.Lock.Unlock()
+
+	handler.CancelledRunOnces = append(handler.CancelledRunOnces, guid)
+	return handler.CancelRunOnceResult
+}
+
+func (handler *FakeRunOnceHandler) Cancel() {
+	handler.cancelOnce.Do(func() {
+		close(handler.cancelled)
+	})
+}
+
+func (handler *FakeRunOnceHandler) WasCancelled() bool {
+	select {
+	case <-handler.cancelled:
+		return true
+	default:
+		return false
+	}
 }
 
 func (handler *FakeRunOnceHandler) NumberOfCalls() int {
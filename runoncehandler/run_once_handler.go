@@ -1,13 +1,21 @@
 package runoncehandler
 
 import (
-	Bbs "github.com/cloudfoundry-incubator/runtime-schema/bbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/gordon"
+	Bbs "github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon"
 
 	"github.com/cloudfoundry-incubator/executor/action_runner"
 	"github.com/cloudfoundry-incubator/executor/actionrunner"
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/containerpool"
+	"github.com/cloudfoundry-incubator/executor/outbox"
+	"github.com/cloudfoundry-incubator/executor/retention"
 	"github.com/cloudfoundry-incubator/executor/runoncehandler/claim_action"
 	"github.com/cloudfoundry-incubator/executor/runoncehandler/complete_action"
 	"github.com/cloudfoundry-incubator/executor/runoncehandler/create_container_action"
@@ -18,12 +26,57 @@ import (
 
 type RunOnceHandlerInterface interface {
 	RunOnce(runOnce models.RunOnce, executorId string)
+	Resume(runOnce models.RunOnce, process taskregistry.Process)
+	InFlight() []InFlightRunOnce
+	CancelRunOnce(guid string) bool
+	Cancel()
+}
+
+// InFlightRunOnce describes a RunOnce that is being handled, and which step of
+// handling it is on.
+type InFlightRunOnce struct {
+	Guid   string `json:"guid"`
+	Action string `json:"action"`
+}
+
+// the steps RunOnce and Resume go through, in order
+var actionNames = []string{
+	"register",
+	"claim",
+	"create-container",
+	"execute",
+	"complete",
 }
 
+// the index of the claim step; once it has been performed the RunOnce is ours
+// to complete
+const claimActionIndex = 1
+
+// the index of the create-container step; if it fails, none of the RunOnce's
+// actions have run and it can be handed to another executor
+const createContainerActionIndex = 2
+
+// the index of the complete step; once it has been performed the RunOnce's
+// completion is in the outbox
+const completeActionIndex = 4
+
+// how long the executor leaves a RunOnce it handed back to pending for other
+// executors, before trying it again itself
+const handBackCooldown = 30 * time.Second
+
+const cancelledFailureReason = "cancelled"
+
+const shutDownFailureReason = "executor shut down before the run once completed"
+
 type RunOnceHandler struct {
-	bbs          Bbs.ExecutorBBS
-	wardenClient gordon.Client
-	actionRunner actionrunner.ActionRunnerInterface
+	bbs              Bbs.ExecutorBBS
+	wardenClient     gordon.Client
+	containerFactory *containerfactory.ContainerFactory
+	containerPool    containerpool.ContainerPoolInterface
+	cacheManager     *cache.Manager
+	retentionPolicy  retention.Policy
+	actionRunner     actionrunner.ActionRunnerInterface
+	outbox           outbox.OutboxInterface
 
 	loggregatorServer string
 	loggregatorSecret string
@@ -32,36 +85,60 @@ type RunOnceHandler struct {
 
 	taskRegistry taskregistry.TaskRegistryInterface
 
-	stack string
+	inFlight     map[string]*action_runner.ActionRunner
+	cancelled    map[string]string
+	handedBack   map[string]time.Time
+	inFlightLock *sync.Mutex
 }
 
 func New(
 	bbs Bbs.ExecutorBBS,
 	wardenClient gordon.Client,
+	containerFactory *containerfactory.ContainerFactory,
+	containerPool containerpool.ContainerPoolInterface,
+	cacheManager *cache.Manager,
+	retentionPolicy retention.Policy,
 	taskRegistry taskregistry.TaskRegistryInterface,
 	actionRunner actionrunner.ActionRunnerInterface,
+	outbox outbox.OutboxInterface,
 	loggregatorServer string,
 	loggregatorSecret string,
-	stack string,
 	logger *steno.Logger,
 ) *RunOnceHandler {
 	return &RunOnceHandler{
 		bbs:               bbs,
 		wardenClient:      wardenClient,
+		containerFactory:  containerFactory,
+		containerPool:     containerPool,
+		cacheManager:      cacheManager,
+		retentionPolicy:   retentionPolicy,
 		taskRegistry:      taskRegistry,
 		actionRunner:      actionRunner,
+		outbox:            outbox,
 		loggregatorServer: loggregatorServer,
 		loggregatorSecret: loggregatorSecret,
 		logger:            logger,
-		stack:             stack,
+		inFlight:          make(map[string]*action_runner.ActionRunner),
+		cancelled:         make(map[string]string),
+		handedBack:        make(map[string]time.Time),
+		inFlightLock:      &sync.Mutex{},
 	}
 }
 
 func (handler *RunOnceHandler) RunOnce(runOnce models.RunOnce, executorID string) {
-	// check for stack compatibility
+	// check for stack compatibility; as before, a RunOnce that does not ask
+	// for a stack is picked up, and run on the executor's default stack
 	// move to task registry?
-	if runOnce.Stack != "" && handler.stack != runOnce.Stack {
-		handler.logger.Errord(map[string]interface{}{"runonce-guid": runOnce.Guid, "desired-stack": runOnce.Stack, "executor-stack": handler.stack}, "runonce.stack.mismatch")
+	stacks := handler.containerFactory.Stacks()
+	if !stacks.Supports(runOnce.Stack) {
+		handler.logger.Errord(map[string]interface{}{"runonce-guid": runOnce.Guid, "desired-stack": runOnce.Stack, "executor-stacks": stacks.Names()}, "runonce.stack.mismatch")
+		return
+	}
+
+	// a RunOnce that was just handed back would likely fail here again (e.g.
+	// while warden is down); give other executors a chance at it first
+	if handler.wasHandedBack(runOnce.Guid) {
+		handler.logger.Debugd(map[string]interface{}{"runonce-guid": runOnce.Guid}, "runonce.recently-handed-back")
 		return
 	}
 
@@ -76,30 +153,303 @@ func (handler *RunOnceHandler) RunOnce(runOnce models.RunOnce, executorID string
 			handler.logger,
 			executorID,
 			handler.bbs,
+			handler.taskRegistry,
 		),
 		create_container_action.New(
 			&runOnce,
 			handler.logger,
 			handler.wardenClient,
+			handler.containerFactory,
+			handler.containerPool,
+			handler.cacheManager,
+			handler.retentionPolicy,
+			handler.taskRegistry,
 		),
 		execute_action.New(
 			&runOnce,
 			handler.logger,
 			handler.bbs,
 			handler.actionRunner,
+			handler.taskRegistry,
 			handler.loggregatorServer,
 			handler.loggregatorSecret,
 		),
 		complete_action.New(
 			&runOnce,
 			handler.logger,
+			handler.outbox,
+			handler.taskRegistry,
+		),
+	})
+
+	handler.perform(&runOnce, runner)
+}
+
+// Resume finishes handling a RunOnce that was already running in its container
+// when the executor restarted.  It reattaches to the RunOnce's process and
+// then completes it, destroys its container and unregisters it as usual.
+func (handler *RunOnceHandler) Resume(runOnce models.RunOnce, process taskregistry.Process) {
+	handler.logger.Infod(map[string]interface{}{
+		"runonce-guid": runOnce.Guid,
+		"handle":       runOnce.ContainerHandle,
+		"process-id":   process.ProcessID,
+	}, "runonce.resuming")
+
+	// the caches are still mounted in the container; they are marked as in
+	// use again so that they are not evicted from under it
+	_, err := handler.cacheManager.Acquire(runOnce.Guid, runOnce.Caches)
+	if err != nil {
+		handler.logger.Errord(map[string]interface{}{
+			"runonce-guid": runOnce.Guid,
+			"error":        err.Error(),
+		}, "runonce.resuming.caches-failed")
+	}
+
+	runner := action_runner.New([]action_runner.Action{
+		alreadyPerformed{register_action.New(
+			runOnce,
+			handler.logger,
+			handler.taskRegistry,
+		)},
+		alreadyPerformed{claim_action.New(
+			&runOnce,
+			handler.logger,
+			runOnce.ExecutorID,
 			handler.bbs,
+			handler.taskRegistry,
+		)},
+		alreadyPerformed{create_container_action.New(
+			&runOnce,
+			handler.logger,
+			handler.wardenClient,
+			handler.containerFactory,
+			handler.containerPool,
+			handler.cacheManager,
+			handler.retentionPolicy,
+			handler.taskRegistry,
+		)},
+		execute_action.Resume(
+			&runOnce,
+			process,
+			handler.logger,
+			handler.bbs,
+			handler.actionRunner,
+			handler.taskRegistry,
+			handler.loggregatorServer,
+			handler.loggregatorSecret,
+		),
+		complete_action.New(
+			&runOnce,
+			handler.logger,
+			handler.outbox,
+			handler.taskRegistry,
 		),
 	})
 
+	handler.perform(&runOnce, runner)
+}
+
+func (handler *RunOnceHandler) perform(runOnce *models.RunOnce, runner *action_runner.ActionRunner) {
+	handler.trackInFlight(runOnce.Guid, runner)
+	defer handler.untrackInFlight(runOnce.Guid)
+
 	result := make(chan error, 1)
 
 	go runner.Perform(result)
 
-	<-result
+	err := <-result
+	if err == nil {
+		return
+	}
+
+	if err == action_runner.CancelledError {
+		failureReason, cancelled := handler.cancellationReason(runOnce.Guid)
+		if cancelled {
+			performed := runner.PerformedActions()
+			if performed > claimActionIndex && performed <= completeActionIndex {
+				handler.completeAsFailed(*runOnce, failureReason)
+			}
+
+			return
+		}
+	}
+
+	if runner.PerformedActions() == createContainerActionIndex {
+		if _, limitFailed := err.(create_container_action.LimitError); limitFailed {
+			// the RunOnce asked for limits warden won't give it; it would fail
+			// the same way anywhere else
+			handler.completeAsFailed(*runOnce, err.Error())
+			return
+		}
+
+		handler.unclaim(*runOnce)
+	}
+}
+
+// unclaim hands a RunOnce that failed before any of its actions ran back to
+// pending, so that another executor can try it.  This executor leaves it alone
+// for handBackCooldown.
+func (handler *RunOnceHandler) unclaim(runOnce models.RunOnce) {
+	handler.recordHandedBack(runOnce.Guid)
+
+	err := handler.bbs.UnclaimRunOnce(runOnce)
+	if err != nil {
+		handler.logger.Errord(
+			map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+				"error":        err.Error(),
+			}, "runonce.unclaim.failed",
+		)
+		return
+	}
+
+	handler.logger.Infod(
+		map[string]interface{}{
+			"runonce-guid": runOnce.Guid,
+		}, "runonce.unclaimed",
+	)
+}
+
+// completeAsFailed records the RunOnce as failed for the outbox to complete,
+// for when it stopped before its complete action could run.
+func (handler *RunOnceHandler) completeAsFailed(runOnce models.RunOnce, failureReason string) {
+	runOnce.Failed = true
+	runOnce.FailureReason = failureReason
+	runOnce.Result = ""
+
+	err := handler.outbox.Complete(runOnce)
+	if err != nil {
+		handler.logger.Errord(
+			map[string]interface{}{
+				"runonce-guid": runOnce.Guid,
+				"error":        err.Error(),
+			}, "runonce.complete.record-failed",
+		)
+	}
+}
+
+func (handler *RunOnceHandler) InFlight() []InFlightRunOnce {
+	handler.inFlightLock.Lock()
+	defer handler.inFlightLock.Unlock()
+
+	inFlight := []InFlightRunOnce{}
+	for guid, runner := range handler.inFlight {
+		action := "cleanup"
+
+		current := runner.CurrentAction()
+		if current >= 0 && current < len(actionNames) {
+			action = actionNames[current]
+		}
+
+		inFlight = append(inFlight, InFlightRunOnce{
+			Guid:   guid,
+			Action: action,
+		})
+	}
+
+	return inFlight
+}
+
+// CancelRunOnce interrupts the RunOnce with the given guid and waits for its
+// cleanups to finish.  If the RunOnce was already claimed it is completed as
+// failed.  It returns false if the RunOnce is not being handled.
+func (handler *RunOnceHandler) CancelRunOnce(guid string) bool {
+	handler.inFlightLock.Lock()
+	runner, found := handler.inFlight[guid]
+	if found {
+		handler.cancelled[guid] = cancelledFailureReason
+	}
+	handler.inFlightLock.Unlock()
+
+	if !found {
+		return false
+	}
+
+	<-runner.Cancel()
+
+	return true
+}
+
+// Cancel interrupts every RunOnce that is still being handled and waits for
+// their cleanups (e.g. destroying containers) to finish.  Those that were
+// already claimed are completed as failed, so that they are not left claimed
+// by an executor that is going away.
+func (handler *RunOnceHandler) Cancel() {
+	handler.inFlightLock.Lock()
+
+	runners := []*action_runner.ActionRunner{}
+	for guid, runner := range handler.inFlight {
+		if _, cancelled := handler.cancelled[guid]; !cancelled {
+			handler.cancelled[guid] = shutDownFailureReason
+		}
+
+		runners = append(runners, runner)
+	}
+
+	handler.inFlightLock.Unlock()
+
+	cancellations := []<-chan bool{}
+	for _, runner := range runners {
+		cancellations = append(cancellations, runner.Cancel())
+	}
+
+	for _, cancelled := range cancellations {
+		<-cancelled
+	}
+}
+
+// alreadyPerformed wraps an action whose work was done before the executor
+// restarted, so that only its cleanup happens.
+type alreadyPerformed struct {
+	action_runner.Action
+}
+
+func (action alreadyPerformed) Perform(result chan<- error) {
+	result <- nil
+}
+
+func (handler *RunOnceHandler) trackInFlight(guid string, runner *action_runner.ActionRunner) {
+	handler.inFlightLock.Lock()
+	defer handler.inFlightLock.Unlock()
+
+	handler.inFlight[guid] = runner
+}
+
+func (handler *RunOnceHandler) untrackInFlight(guid string) {
+	handler.inFlightLock.Lock()
+	defer handler.inFlightLock.Unlock()
+
+	delete(handler.inFlight, guid)
+	delete(handler.cancelled, guid)
+}
+
+func (handler *RunOnceHandler) recordHandedBack(guid string) {
+	handler.inFlightLock.Lock()
+	defer handler.inFlightLock.Unlock()
+
+	now := time.Now()
+
+	for handedBackGuid, handedBackAt := range handler.handedBack {
+		if now.Sub(handedBackAt) >= handBackCooldown {
+			delete(handler.handedBack, handedBackGuid)
+		}
+	}
+
+	handler.handedBack[guid] = now
+}
+
+func (handler *RunOnceHandler) wasHandedBack(guid string) bool {
+	handler.inFlightLock.Lock()
+	defer handler.inFlightLock.Unlock()
+
+	handedBackAt, found := handler.handedBack[guid]
+	return found && time.Since(handedBackAt) < handBackCooldown
+}
+
+func (handler *RunOnceHandler) cancellationReason(guid string) (string, bool) {
+	handler.inFlightLock.Lock()
+	defer handler.inFlightLock.Unlock()
+
+	failureReason, cancelled := handler.cancelled[guid]
+	return failureReason, cancelled
 }
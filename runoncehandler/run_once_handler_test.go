@@ -1,19 +1,27 @@
 package runoncehandler_test
 
 import (
+	"errors"
 	"fmt"
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/config"
 	. "github.com/onsi/gomega"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/bbs/fakebbs"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/connection"
+	"github.com/cloudfoundry-incubator/executor/forks/gordon/fake_gordon"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/bbs/fakebbs"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	steno "github.com/cloudfoundry/gosteno"
-	"github.com/vito/gordon/fake_gordon"
 
 	"github.com/cloudfoundry-incubator/executor/actionrunner/fakeactionrunner"
+	"github.com/cloudfoundry-incubator/executor/cache"
+	"github.com/cloudfoundry-incubator/executor/containerfactory"
+	"github.com/cloudfoundry-incubator/executor/containerpool/fakecontainerpool"
+	"github.com/cloudfoundry-incubator/executor/outbox/fakeoutbox"
+	"github.com/cloudfoundry-incubator/executor/retention"
 	. "github.com/cloudfoundry-incubator/executor/runoncehandler"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 	"github.com/cloudfoundry-incubator/executor/taskregistry/faketaskregistry"
 )
 
@@ -26,20 +34,28 @@ var _ = Describe("RunOnceHandler", func() {
 		fakeTaskRegistry  *faketaskregistry.FakeTaskRegistry
 		gordon            *fake_gordon.FakeGordon
 		actionRunner      *fakeactionrunner.FakeActionRunner
+		outbox            *fakeoutbox.FakeOutbox
 		loggregatorServer string
 		loggregatorSecret string
-		stack             string
+		stacks            containerfactory.Stacks
 	)
 
 	BeforeEach(func() {
 		bbs = fakebbs.NewFakeExecutorBBS()
 		gordon = fake_gordon.New()
 		actionRunner = fakeactionrunner.New()
+		outbox = fakeoutbox.New()
 		fakeTaskRegistry = faketaskregistry.New()
 		loggregatorPort := 3456 + config.GinkgoConfig.ParallelNode
 		loggregatorServer = fmt.Sprintf("127.0.0.1:%d", loggregatorPort)
 		loggregatorSecret = "conspiracy"
-		stack = "penguin"
+		cacheManager, err := cache.New("", 0, steno.NewLogger("test-logger"))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		stacks = containerfactory.NewStacks("penguin", map[string]string{
+			"penguin":    "/rootfs/penguin",
+			"polar-bear": "/rootfs/polar-bear",
+		})
 
 		runOnce = models.RunOnce{
 			Guid:  "totally-unique",
@@ -56,11 +72,15 @@ var _ = Describe("RunOnceHandler", func() {
 		handler = New(
 			bbs,
 			gordon,
+			containerfactory.New(gordon, "executor-", stacks),
+			fakecontainerpool.New(),
+			cacheManager,
+			retention.DefaultPolicy,
 			fakeTaskRegistry,
 			actionRunner,
+			outbox,
 			loggregatorServer,
 			loggregatorSecret,
-			stack,
 			steno.NewLogger("test-logger"),
 		)
 
@@ -79,9 +99,29 @@ var _ = Describe("RunOnceHandler", func() {
 			It("should pick up the RunOnce", func() {
 				Ω(fakeTaskRegistry.RegisteredRunOnces).Should(ContainElement(runOnce))
 			})
+
+			It("should run it on the executor's default stack", func() {
+				Ω(gordon.CreatedContainers()).Should(HaveLen(1))
+				Ω(gordon.CreatedContainers()[0].RootFSPath).Should(Equal("/rootfs/penguin"))
+			})
 		})
 
-		Context("when the RunOnce stack does not match the executor's stack", func() {
+		Context("when the RunOnce is for another of the executor's stacks", func() {
+			BeforeEach(func() {
+				runOnce.Stack = "polar-bear"
+			})
+
+			It("should pick up the RunOnce", func() {
+				Ω(fakeTaskRegistry.RegisteredRunOnces).Should(ContainElement(runOnce))
+			})
+
+			It("should create its container with the stack's rootfs", func() {
+				Ω(gordon.CreatedContainers()).Should(HaveLen(1))
+				Ω(gordon.CreatedContainers()[0].RootFSPath).Should(Equal("/rootfs/polar-bear"))
+			})
+		})
+
+		Context("when the RunOnce stack is not one of the executor's stacks", func() {
 			BeforeEach(func() {
 				runOnce.Stack = "lion"
 			})
@@ -91,4 +131,236 @@ var _ = Describe("RunOnceHandler", func() {
 			})
 		})
 	})
+
+	Describe("when the RunOnce's container cannot be created", func() {
+		BeforeEach(func() {
+			gordon.CreateError = errors.New("warden is away")
+		})
+
+		It("hands the claimed RunOnce back to pending", func() {
+			handler.RunOnce(runOnce, "executor-id")
+
+			Ω(bbs.ClaimedRunOnce.Guid).Should(Equal(runOnce.Guid))
+			Ω(bbs.UnclaimedRunOnce.Guid).Should(Equal(runOnce.Guid))
+		})
+
+		It("does not complete the RunOnce", func() {
+			handler.RunOnce(runOnce, "executor-id")
+
+			Ω(outbox.CompletedRunOnces).Should(BeEmpty())
+		})
+
+		It("does not claim the RunOnce again right after handing it back", func() {
+			handler.RunOnce(runOnce, "executor-id")
+
+			bbs.ClaimedRunOnce = models.RunOnce{}
+			gordon.CreateError = nil
+
+			handler.RunOnce(runOnce, "executor-id")
+
+			Ω(bbs.ClaimedRunOnce).Should(BeZero())
+			Ω(gordon.CreatedHandles()).Should(BeEmpty())
+		})
+
+		It("still handles other RunOnces", func() {
+			handler.RunOnce(runOnce, "executor-id")
+
+			gordon.CreateError = nil
+
+			otherRunOnce := runOnce
+			otherRunOnce.Guid = "some-other-guid"
+			handler.RunOnce(otherRunOnce, "executor-id")
+
+			Ω(bbs.ClaimedRunOnce.Guid).Should(Equal("some-other-guid"))
+		})
+	})
+
+	Describe("when the RunOnce's container cannot be limited", func() {
+		BeforeEach(func() {
+			runOnce.MemoryMB = 256
+		})
+
+		Context("because warden rejects the limit", func() {
+			BeforeEach(func() {
+				gordon.LimitMemoryError = &connection.WardenError{Message: "out of cgroups"}
+			})
+
+			It("completes the RunOnce as failed, with the error as the reason", func() {
+				handler.RunOnce(runOnce, "executor-id")
+
+				Ω(outbox.CompletedRunOnces).Should(HaveLen(1))
+				Ω(outbox.CompletedRunOnces[0].Guid).Should(Equal(runOnce.Guid))
+				Ω(outbox.CompletedRunOnces[0].Failed).Should(BeTrue())
+				Ω(outbox.CompletedRunOnces[0].FailureReason).Should(ContainSubstring("out of cgroups"))
+			})
+
+			It("does not hand the RunOnce back to pending", func() {
+				handler.RunOnce(runOnce, "executor-id")
+
+				Ω(bbs.UnclaimedRunOnce).Should(BeZero())
+			})
+		})
+
+		Context("because warden cannot be reached", func() {
+			BeforeEach(func() {
+				gordon.LimitMemoryError = errors.New("connection reset")
+			})
+
+			It("hands the claimed RunOnce back to pending without completing it", func() {
+				handler.RunOnce(runOnce, "executor-id")
+
+				Ω(bbs.UnclaimedRunOnce.Guid).Should(Equal(runOnce.Guid))
+				Ω(outbox.CompletedRunOnces).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("when the RunOnce cannot be claimed", func() {
+		BeforeEach(func() {
+			bbs.ClaimRunOnceErr = errors.New("someone else got there first")
+		})
+
+		It("does not unclaim the RunOnce", func() {
+			handler.RunOnce(runOnce, "executor-id")
+
+			Ω(bbs.UnclaimedRunOnce).Should(BeZero())
+		})
+	})
+
+	Describe("when the RunOnce's actions fail", func() {
+		BeforeEach(func() {
+			actionRunner.RunError = errors.New("oh no!")
+		})
+
+		It("does not unclaim the RunOnce", func() {
+			handler.RunOnce(runOnce, "executor-id")
+
+			Ω(bbs.UnclaimedRunOnce).Should(BeZero())
+		})
+	})
+
+	Describe("InFlight", func() {
+		It("is empty when no RunOnces are being handled", func() {
+			handler.RunOnce(runOnce, "executor-id")
+			Ω(handler.InFlight()).Should(BeEmpty())
+		})
+	})
+
+	Describe("CancelRunOnce", func() {
+		It("returns false when the RunOnce is not being handled", func() {
+			Ω(handler.CancelRunOnce("some-other-guid")).Should(BeFalse())
+		})
+
+		Context("when the RunOnce is running its actions", func() {
+			var handled chan bool
+
+			BeforeEach(func() {
+				actionRunner.BlockUntilCancelled = true
+
+				handled = make(chan bool)
+				go func() {
+					handler.RunOnce(runOnce, "executor-id")
+					close(handled)
+				}()
+
+				Eventually(handler.InFlight).Should(ContainElement(InFlightRunOnce{
+					Guid:   runOnce.Guid,
+					Action: "execute",
+				}))
+			})
+
+			It("interrupts the actions and cleans up after the RunOnce", func() {
+				Ω(handler.CancelRunOnce(runOnce.Guid)).Should(BeTrue())
+				Eventually(handled).Should(BeClosed())
+
+				Ω(gordon.DestroyedHandles()).Should(HaveLen(1))
+				Ω(fakeTaskRegistry.UnregisteredRunOnces).Should(HaveLen(1))
+			})
+
+			It("completes the RunOnce as failed because it was cancelled", func() {
+				Ω(handler.CancelRunOnce(runOnce.Guid)).Should(BeTrue())
+				Eventually(handled).Should(BeClosed())
+
+				Ω(outbox.CompletedRunOnces).Should(HaveLen(1))
+				Ω(outbox.CompletedRunOnces[0].Guid).Should(Equal(runOnce.Guid))
+				Ω(outbox.CompletedRunOnces[0].Failed).Should(BeTrue())
+				Ω(outbox.CompletedRunOnces[0].FailureReason).Should(Equal("cancelled"))
+			})
+
+			It("completes the RunOnce as failed when every RunOnce is cancelled on shutdown", func() {
+				handler.Cancel()
+				Eventually(handled).Should(BeClosed())
+
+				Ω(outbox.CompletedRunOnces).Should(HaveLen(1))
+				Ω(outbox.CompletedRunOnces[0].Guid).Should(Equal(runOnce.Guid))
+				Ω(outbox.CompletedRunOnces[0].Failed).Should(BeTrue())
+				Ω(outbox.CompletedRunOnces[0].FailureReason).Should(ContainSubstring("shut down"))
+			})
+		})
+	})
+
+	Describe("when the RunOnce is cancelled while it is being completed", func() {
+		var handled chan bool
+
+		BeforeEach(func() {
+			outbox.BlockComplete = make(chan bool)
+
+			handled = make(chan bool)
+			go func() {
+				handler.RunOnce(runOnce, "executor-id")
+				close(handled)
+			}()
+
+			Eventually(handler.InFlight).Should(ContainElement(InFlightRunOnce{
+				Guid:   runOnce.Guid,
+				Action: "complete",
+			}))
+		})
+
+		It("waits for the completion instead of completing it again as cancelled", func() {
+			cancelled := make(chan bool)
+			go func() {
+				Ω(handler.CancelRunOnce(runOnce.Guid)).Should(BeTrue())
+				close(cancelled)
+			}()
+
+			Consistently(cancelled).ShouldNot(BeClosed())
+
+			close(outbox.BlockComplete)
+
+			Eventually(cancelled).Should(BeClosed())
+			Eventually(handled).Should(BeClosed())
+
+			Ω(outbox.CompletedRunOnces).Should(HaveLen(1))
+			Ω(outbox.CompletedRunOnces[0].FailureReason).ShouldNot(Equal("cancelled"))
+			Ω(gordon.DestroyedHandles()).Should(HaveLen(1))
+		})
+	})
+
+	Describe("Resuming a RunOnce", func() {
+		BeforeEach(func() {
+			runOnce.ExecutorID = "executor-id"
+			runOnce.ContainerHandle = "some-container-handle"
+
+			handler.Resume(runOnce, taskregistry.Process{ActionIndex: 0, ProcessID: 42})
+		})
+
+		It("does not register or claim the RunOnce again", func() {
+			Ω(fakeTaskRegistry.RegisteredRunOnces).Should(BeEmpty())
+			Ω(bbs.ClaimedRunOnce).Should(BeZero())
+		})
+
+		It("resumes the actions in the RunOnce's container", func() {
+			Ω(actionRunner.Resumed).Should(BeTrue())
+			Ω(actionRunner.ContainerHandle).Should(Equal("some-container-handle"))
+			Ω(actionRunner.ResumedProcessID).Should(Equal(uint32(42)))
+		})
+
+		It("completes the RunOnce, destroys its container and unregisters it", func() {
+			Ω(outbox.CompletedRunOnces).Should(HaveLen(1))
+			Ω(outbox.CompletedRunOnces[0].Guid).Should(Equal(runOnce.Guid))
+			Ω(gordon.DestroyedHandles()).Should(ContainElement("some-container-handle"))
+			Ω(fakeTaskRegistry.UnregisteredRunOnces).Should(HaveLen(1))
+		})
+	})
 })
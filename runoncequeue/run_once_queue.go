@@ -0,0 +1,156 @@
+package runoncequeue
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var ErrQueueFull = errors.New("run once queue is full")
+var ErrInsufficientResources = errors.New("insufficient resources for run once")
+var ErrQueueStopped = errors.New("run once queue is stopped")
+
+// Stats describes how busy a RunOnceQueue is, and how many RunOnces it has
+// turned away.
+type Stats struct {
+	Workers                       int `json:"workers"`
+	Depth                         int `json:"depth"`
+	MaxDepth                      int `json:"max_depth"`
+	RejectedQueueFull             int `json:"rejected_queue_full"`
+	RejectedInsufficientResources int `json:"rejected_insufficient_resources"`
+}
+
+// RunOnceQueue hands desired RunOnces to a fixed number of workers.  RunOnces
+// that do not fit in the registry alongside the ones already queued or being
+// worked on, or that arrive while the queue is full, are rejected straight
+// away so that another executor can pick them up.
+type RunOnceQueue struct {
+	workers      int
+	taskRegistry *taskregistry.TaskRegistry
+
+	runOnces chan models.RunOnce
+
+	// queued RunOnces, and those handed to a worker, until the worker is done;
+	// the registry does not count them again once they are registered
+	queued  []models.RunOnce
+	stop    chan struct{}
+	stopped bool
+
+	rejectedQueueFull             int
+	rejectedInsufficientResources int
+
+	lock *sync.Mutex
+}
+
+func New(workers int, maxDepth int, taskRegistry *taskregistry.TaskRegistry) *RunOnceQueue {
+	return &RunOnceQueue{
+		workers:      workers,
+		taskRegistry: taskRegistry,
+
+		runOnces: make(chan models.RunOnce, maxDepth),
+		stop:     make(chan struct{}),
+
+		lock: &sync.Mutex{},
+	}
+}
+
+// Start starts the workers, which call work with each RunOnce that is queued,
+// one at a time, until the queue is stopped.  A RunOnce counts against the
+// registry's capacity until it is registered, or work returns.
+func (queue *RunOnceQueue) Start(work func(models.RunOnce)) {
+	for i := 0; i < queue.workers; i++ {
+		go func() {
+			for {
+				select {
+				case runOnce := <-queue.runOnces:
+					work(runOnce)
+					queue.dequeued(runOnce)
+				case <-queue.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Enqueue queues the RunOnce for a worker, or returns ErrInsufficientResources
+// or ErrQueueFull if it is rejected.  Once the queue is stopped it returns
+// ErrQueueStopped.
+func (queue *RunOnceQueue) Enqueue(runOnce models.RunOnce) error {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	if queue.stopped {
+		return ErrQueueStopped
+	}
+
+	if !queue.taskRegistry.HasCapacityFor(runOnce, queue.queued...) {
+		queue.rejectedInsufficientResources++
+
+		return ErrInsufficientResources
+	}
+
+	select {
+	case queue.runOnces <- runOnce:
+		queue.queued = append(queue.queued, runOnce)
+		return nil
+	default:
+		queue.rejectedQueueFull++
+
+		return ErrQueueFull
+	}
+}
+
+// Stop stops the workers once they finish the RunOnces they are on, and
+// returns the RunOnces that were still waiting in the queue.
+func (queue *RunOnceQueue) Stop() []models.RunOnce {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	if !queue.stopped {
+		queue.stopped = true
+		close(queue.stop)
+	}
+
+	queue.queued = nil
+
+	dropped := []models.RunOnce{}
+	for {
+		select {
+		case runOnce := <-queue.runOnces:
+			dropped = append(dropped, runOnce)
+		default:
+			return dropped
+		}
+	}
+}
+
+func (queue *RunOnceQueue) Stats() Stats {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	return Stats{
+		Workers:                       queue.workers,
+		Depth:                         len(queue.runOnces),
+		MaxDepth:                      cap(queue.runOnces),
+		RejectedQueueFull:             queue.rejectedQueueFull,
+		RejectedInsufficientResources: queue.rejectedInsufficientResources,
+	}
+}
+
+// dequeued stops counting the RunOnce against the registry's capacity, now
+// that a worker is done with it
+func (queue *RunOnceQueue) dequeued(runOnce models.RunOnce) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+
+	for i, queuedRunOnce := range queue.queued {
+		if queuedRunOnce.Guid == runOnce.Guid {
+			queue.queued = append(queue.queued[:i], queue.queued[i+1:]...)
+			return
+		}
+	}
+}
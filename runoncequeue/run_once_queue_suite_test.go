@@ -0,0 +1,13 @@
+package runoncequeue_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRunOnceQueue(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RunOnceQueue Suite")
+}
@@ -0,0 +1,214 @@
+package runoncequeue_test
+
+import (
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+
+	. "github.com/cloudfoundry-incubator/executor/runoncequeue"
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
+)
+
+var _ = Describe("RunOnceQueue", func() {
+	var queue *RunOnceQueue
+	var taskRegistry *taskregistry.TaskRegistry
+	var runOnce models.RunOnce
+
+	BeforeEach(func() {
+		registryFileName := fmt.Sprintf("/tmp/executor_registry_%d", config.GinkgoConfig.ParallelNode)
+		taskRegistry = taskregistry.NewTaskRegistry(registryFileName, 256, 1024)
+
+		runOnce = models.RunOnce{
+			Guid:     "totally-unique",
+			MemoryMB: 256,
+			DiskMB:   1024,
+		}
+
+		queue = New(2, 1, taskRegistry)
+	})
+
+	AfterEach(func() {
+		queue.Stop()
+	})
+
+	Describe("Enqueue", func() {
+		It("hands the RunOnce to a worker", func() {
+			worked := make(chan models.RunOnce, 1)
+			queue.Start(func(runOnce models.RunOnce) {
+				worked <- runOnce
+			})
+
+			err := queue.Enqueue(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(<-worked).Should(Equal(runOnce))
+		})
+
+		It("works on no more RunOnces at a time than there are workers", func() {
+			lock := &sync.Mutex{}
+			working := 0
+			mostWorking := 0
+			proceed := make(chan bool)
+
+			queue = New(2, 10, taskRegistry)
+			queue.Start(func(runOnce models.RunOnce) {
+				lock.Lock()
+				working++
+				if working > mostWorking {
+					mostWorking = working
+				}
+				lock.Unlock()
+
+				<-proceed
+
+				lock.Lock()
+				working--
+				lock.Unlock()
+			})
+
+			for i := 0; i < 5; i++ {
+				err := queue.Enqueue(models.RunOnce{Guid: fmt.Sprintf("run-once-%d", i)})
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+
+			Eventually(func() int { return queue.Stats().Depth }).Should(Equal(3))
+
+			for i := 0; i < 5; i++ {
+				proceed <- true
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			Ω(mostWorking).Should(Equal(2))
+		})
+
+		Context("when the RunOnce does not fit in the registry", func() {
+			BeforeEach(func() {
+				runOnce.MemoryMB = 257
+			})
+
+			It("rejects it", func() {
+				err := queue.Enqueue(runOnce)
+				Ω(err).Should(Equal(ErrInsufficientResources))
+
+				Ω(queue.Stats().Depth).Should(Equal(0))
+				Ω(queue.Stats().RejectedInsufficientResources).Should(Equal(1))
+			})
+		})
+
+		Context("when the RunOnces already queued take up the registry's capacity", func() {
+			BeforeEach(func() {
+				queue = New(2, 10, taskRegistry)
+
+				err := queue.Enqueue(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("rejects it", func() {
+				err := queue.Enqueue(models.RunOnce{Guid: "another-one", MemoryMB: 1})
+				Ω(err).Should(Equal(ErrInsufficientResources))
+
+				Ω(queue.Stats().Depth).Should(Equal(1))
+				Ω(queue.Stats().RejectedInsufficientResources).Should(Equal(1))
+			})
+
+			It("keeps counting the RunOnce while a worker has it but has not registered it", func() {
+				worked := make(chan models.RunOnce, 1)
+				proceed := make(chan bool)
+				queue.Start(func(runOnce models.RunOnce) {
+					worked <- runOnce
+					<-proceed
+				})
+
+				Ω(<-worked).Should(Equal(runOnce))
+
+				err := queue.Enqueue(models.RunOnce{Guid: "another-one", MemoryMB: 1})
+				Ω(err).Should(Equal(ErrInsufficientResources))
+
+				close(proceed)
+			})
+
+			It("does not count the RunOnce twice once the worker registers it", func() {
+				runOnce.MemoryMB = 128
+				queue = New(2, 10, taskRegistry)
+
+				err := queue.Enqueue(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				registered := make(chan bool)
+				proceed := make(chan bool)
+				queue.Start(func(runOnce models.RunOnce) {
+					taskRegistry.AddRunOnce(runOnce)
+					registered <- true
+					<-proceed
+				})
+
+				<-registered
+
+				err = queue.Enqueue(models.RunOnce{Guid: "another-one", MemoryMB: 128})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				close(proceed)
+			})
+
+			It("makes room once the worker is done with the RunOnce", func() {
+				worked := make(chan models.RunOnce, 1)
+				queue.Start(func(runOnce models.RunOnce) {
+					worked <- runOnce
+				})
+
+				Ω(<-worked).Should(Equal(runOnce))
+
+				Eventually(func() error {
+					return queue.Enqueue(models.RunOnce{Guid: "another-one", MemoryMB: 1})
+				}).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when the queue is full", func() {
+			BeforeEach(func() {
+				err := queue.Enqueue(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("rejects the RunOnce", func() {
+				err := queue.Enqueue(models.RunOnce{Guid: "another-one"})
+				Ω(err).Should(Equal(ErrQueueFull))
+
+				Ω(queue.Stats().Depth).Should(Equal(1))
+				Ω(queue.Stats().RejectedQueueFull).Should(Equal(1))
+			})
+		})
+	})
+
+	Describe("Stop", func() {
+		It("returns the RunOnces that were never worked on", func() {
+			err := queue.Enqueue(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(queue.Stop()).Should(Equal([]models.RunOnce{runOnce}))
+		})
+
+		It("rejects any more RunOnces", func() {
+			queue.Stop()
+
+			err := queue.Enqueue(runOnce)
+			Ω(err).Should(Equal(ErrQueueStopped))
+		})
+	})
+
+	Describe("Stats", func() {
+		It("reports the number of workers and the queue's bounds", func() {
+			Ω(queue.Stats()).Should(Equal(Stats{
+				Workers:  2,
+				Depth:    0,
+				MaxDepth: 1,
+			}))
+		})
+	})
+})
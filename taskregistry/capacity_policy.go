@@ -0,0 +1,65 @@
+package taskregistry
+
+import (
+	"errors"
+)
+
+var ErrorInvalidOvercommitRatio = errors.New("Overcommit ratios must be greater than 0")
+var ErrorNegativeReservation = errors.New("Reserved memory and disk cannot be negative")
+var ErrorReservationExceedsCapacity = errors.New("Reserved memory or disk leaves no capacity for RunOnces")
+
+// CapacityPolicy turns the memory and disk an executor has into the capacity
+// it hands out to RunOnces.  The reserved amounts are held back for warden and
+// the executor itself; what is left is scaled by the overcommit ratios, so a
+// ratio of 2 lets RunOnces declare twice the disk that is really there.  CPU
+// weight and containers are handed out as they are.
+type CapacityPolicy struct {
+	ReservedMemoryMB      int
+	ReservedDiskMB        int
+	MemoryOvercommitRatio float64
+	DiskOvercommitRatio   float64
+}
+
+// DefaultCapacityPolicy hands out exactly the memory and disk the executor has.
+var DefaultCapacityPolicy = CapacityPolicy{
+	MemoryOvercommitRatio: 1,
+	DiskOvercommitRatio:   1,
+}
+
+// Validate checks that the policy leaves some memory and disk for RunOnces out
+// of the given total.
+func (policy CapacityPolicy) Validate(total Capacity) error {
+	if policy.MemoryOvercommitRatio <= 0 || policy.DiskOvercommitRatio <= 0 {
+		return ErrorInvalidOvercommitRatio
+	}
+
+	if policy.ReservedMemoryMB < 0 || policy.ReservedDiskMB < 0 {
+		return ErrorNegativeReservation
+	}
+
+	capacity := policy.Apply(total)
+	if capacity.MemoryMB <= 0 || capacity.DiskMB <= 0 {
+		return ErrorReservationExceedsCapacity
+	}
+
+	return nil
+}
+
+// Apply returns the capacity RunOnces can be given out of the given total.
+func (policy CapacityPolicy) Apply(total Capacity) Capacity {
+	return Capacity{
+		MemoryMB:   schedulable(total.MemoryMB, policy.ReservedMemoryMB, policy.MemoryOvercommitRatio),
+		DiskMB:     schedulable(total.DiskMB, policy.ReservedDiskMB, policy.DiskOvercommitRatio),
+		CpuWeight:  total.CpuWeight,
+		Containers: total.Containers,
+	}
+}
+
+func schedulable(total int, reserved int, overcommitRatio float64) int {
+	unreserved := total - reserved
+	if unreserved <= 0 {
+		return 0
+	}
+
+	return int(float64(unreserved) * overcommitRatio)
+}
@@ -0,0 +1,111 @@
+package taskregistry_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/onsi/ginkgo/config"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/taskregistry"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CapacityPolicy", func() {
+	var policy CapacityPolicy
+
+	BeforeEach(func() {
+		policy = CapacityPolicy{
+			ReservedMemoryMB:      256,
+			ReservedDiskMB:        1024,
+			MemoryOvercommitRatio: 1,
+			DiskOvercommitRatio:   2,
+		}
+	})
+
+	Describe("Apply", func() {
+		It("holds back the reserved amounts and overcommits what is left", func() {
+			Ω(policy.Apply(Capacity{MemoryMB: 1024, DiskMB: 4096})).Should(Equal(Capacity{MemoryMB: 768, DiskMB: 6144}))
+		})
+
+		It("leaves nothing when everything is reserved", func() {
+			Ω(policy.Apply(Capacity{MemoryMB: 128, DiskMB: 512})).Should(Equal(Capacity{MemoryMB: 0, DiskMB: 0}))
+		})
+
+		It("hands out cpu weight and containers as they are", func() {
+			Ω(policy.Apply(Capacity{MemoryMB: 1024, DiskMB: 4096, CpuWeight: 100, Containers: 10})).Should(Equal(Capacity{MemoryMB: 768, DiskMB: 6144, CpuWeight: 100, Containers: 10}))
+		})
+
+		It("hands out everything by default", func() {
+			Ω(DefaultCapacityPolicy.Apply(Capacity{MemoryMB: 1024, DiskMB: 4096})).Should(Equal(Capacity{MemoryMB: 1024, DiskMB: 4096}))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts a policy that leaves capacity for RunOnces", func() {
+			Ω(policy.Validate(Capacity{MemoryMB: 1024, DiskMB: 4096})).ShouldNot(HaveOccurred())
+		})
+
+		It("rejects overcommit ratios that are not positive", func() {
+			policy.MemoryOvercommitRatio = 0
+			Ω(policy.Validate(Capacity{MemoryMB: 1024, DiskMB: 4096})).Should(Equal(ErrorInvalidOvercommitRatio))
+		})
+
+		It("rejects negative reservations", func() {
+			policy.ReservedDiskMB = -1
+			Ω(policy.Validate(Capacity{MemoryMB: 1024, DiskMB: 4096})).Should(Equal(ErrorNegativeReservation))
+		})
+
+		It("rejects reservations that leave no capacity", func() {
+			policy.ReservedMemoryMB = 1024
+			Ω(policy.Validate(Capacity{MemoryMB: 1024, DiskMB: 4096})).Should(Equal(ErrorReservationExceedsCapacity))
+		})
+	})
+
+	Describe("a registry with a policy", func() {
+		var registryFileName string
+		var taskRegistry *TaskRegistry
+
+		BeforeEach(func() {
+			registryFileName = fmt.Sprintf("/tmp/executor_registry_policy_%d", config.GinkgoConfig.ParallelNode)
+			taskRegistry = NewTaskRegistryWithPolicy(registryFileName, Capacity{MemoryMB: 1024, DiskMB: 4096}, policy)
+		})
+
+		AfterEach(func() {
+			os.Remove(registryFileName)
+			os.Remove(registryFileName + ".previous")
+		})
+
+		It("reports the capacity the policy leaves", func() {
+			Ω(taskRegistry.TotalCapacity()).Should(Equal(Capacity{MemoryMB: 768, DiskMB: 6144}))
+			Ω(taskRegistry.AvailableCapacity()).Should(Equal(Capacity{MemoryMB: 768, DiskMB: 6144}))
+		})
+
+		It("does not hand out reserved memory", func() {
+			err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "a guid", MemoryMB: 769, DiskMB: 1})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("hands out overcommitted disk", func() {
+			err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "a guid", MemoryMB: 1, DiskMB: 6144})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("applies the policy when loading a snapshot", func() {
+			err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "a guid", MemoryMB: 1, DiskMB: 5000})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = taskRegistry.WriteToDisk()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = LoadTaskRegistryFromDiskWithPolicy(registryFileName, Capacity{MemoryMB: 1024, DiskMB: 4096}, DefaultCapacityPolicy)
+			Ω(err).Should(Equal(ErrorNotEnoughDiskWhenLoadingSnapshot))
+
+			loadedTaskRegistry, err := LoadTaskRegistryFromDiskWithPolicy(registryFileName, Capacity{MemoryMB: 1024, DiskMB: 4096}, policy)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loadedTaskRegistry.AvailableCapacity()).Should(Equal(Capacity{MemoryMB: 767, DiskMB: 1144}))
+		})
+	})
+})
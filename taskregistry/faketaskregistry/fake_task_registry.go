@@ -1,17 +1,32 @@
 package faketaskregistry
 
 import (
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+
+	"github.com/cloudfoundry-incubator/executor/taskregistry"
 )
 
 type FakeTaskRegistry struct {
 	RegisteredRunOnces   []models.RunOnce
+	UpdatedRunOnces      []models.RunOnce
 	UnregisteredRunOnces []models.RunOnce
+	RecordedProcesses    map[string]taskregistry.Process
+	RunOnceStates        map[string][]taskregistry.LifecycleState
+	RunOnceActions       map[string][]int
+	RetainedRunOnces     []models.RunOnce
+	RetainedTTLs         []time.Duration
 	AddRunOnceErr        error
+	WriteToDiskCalls     int
 }
 
 func New() *FakeTaskRegistry {
-	return &FakeTaskRegistry{}
+	return &FakeTaskRegistry{
+		RecordedProcesses: make(map[string]taskregistry.Process),
+		RunOnceStates:     make(map[string][]taskregistry.LifecycleState),
+		RunOnceActions:    make(map[string][]int),
+	}
 }
 
 func (fakeRegistry *FakeTaskRegistry) AddRunOnce(runOnce models.RunOnce) error {
@@ -21,8 +36,32 @@ func (fakeRegistry *FakeTaskRegistry) AddRunOnce(runOnce models.RunOnce) error {
 	return fakeRegistry.AddRunOnceErr
 }
 
+func (fakeRegistry *FakeTaskRegistry) UpdateRunOnce(runOnce models.RunOnce) {
+	fakeRegistry.UpdatedRunOnces = append(fakeRegistry.UpdatedRunOnces, runOnce)
+}
+
+func (fakeRegistry *FakeTaskRegistry) RecordProcess(runOnceGuid string, process taskregistry.Process) {
+	fakeRegistry.RecordedProcesses[runOnceGuid] = process
+}
+
+func (fakeRegistry *FakeTaskRegistry) SetRunOnceState(runOnceGuid string, state taskregistry.LifecycleState) {
+	fakeRegistry.RunOnceStates[runOnceGuid] = append(fakeRegistry.RunOnceStates[runOnceGuid], state)
+}
+
+func (fakeRegistry *FakeTaskRegistry) SetRunOnceAction(runOnceGuid string, actionIndex int) {
+	fakeRegistry.RunOnceActions[runOnceGuid] = append(fakeRegistry.RunOnceActions[runOnceGuid], actionIndex)
+}
+
+func (fakeRegistry *FakeTaskRegistry) RetainContainer(runOnce models.RunOnce, ttl time.Duration) {
+	fakeRegistry.RetainedRunOnces = append(fakeRegistry.RetainedRunOnces, runOnce)
+	fakeRegistry.RetainedTTLs = append(fakeRegistry.RetainedTTLs, ttl)
+}
+
 func (fakeRegistry *FakeTaskRegistry) RemoveRunOnce(runOnce models.RunOnce) {
 	fakeRegistry.UnregisteredRunOnces = append(fakeRegistry.UnregisteredRunOnces, runOnce)
 }
 
-func (fakeRegistry *FakeTaskRegistry) WriteToDisk() error { return nil }
+func (fakeRegistry *FakeTaskRegistry) WriteToDisk() error {
+	fakeRegistry.WriteToDiskCalls++
+	return nil
+}
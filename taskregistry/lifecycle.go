@@ -0,0 +1,108 @@
+package taskregistry
+
+import (
+	"time"
+)
+
+// LifecycleState is how far along a registered RunOnce is.
+type LifecycleState string
+
+const (
+	StateRegistered       LifecycleState = "registered"
+	StateClaimed          LifecycleState = "claimed"
+	StateContainerCreated LifecycleState = "container-created"
+	StateRunning          LifecycleState = "running"
+	StateCompleting       LifecycleState = "completing"
+)
+
+// Lifecycle records where a registered RunOnce is in its lifecycle: its
+// state, when it entered each state (in nanoseconds since the epoch), its
+// container, and the index of the action it is running (-1 before the first).
+type Lifecycle struct {
+	State           LifecycleState           `json:"state"`
+	Transitions     map[LifecycleState]int64 `json:"transitions"`
+	ContainerHandle string                   `json:"container_handle"`
+	ActionIndex     int                      `json:"action_index"`
+}
+
+func newLifecycle(containerHandle string) Lifecycle {
+	return Lifecycle{
+		State:           StateRegistered,
+		Transitions:     map[LifecycleState]int64{StateRegistered: time.Now().UnixNano()},
+		ContainerHandle: containerHandle,
+		ActionIndex:     -1,
+	}
+}
+
+func (lifecycle Lifecycle) copy() Lifecycle {
+	transitions := make(map[LifecycleState]int64, len(lifecycle.Transitions))
+	for state, at := range lifecycle.Transitions {
+		transitions[state] = at
+	}
+
+	lifecycle.Transitions = transitions
+	return lifecycle
+}
+
+// SetRunOnceState moves a registered RunOnce to the given state.  RunOnces
+// that are not registered are ignored.
+func (registry *TaskRegistry) SetRunOnceState(runOnceGuid string, state LifecycleState) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	lifecycle, registered := registry.Lifecycles[runOnceGuid]
+	if !registered {
+		return
+	}
+
+	if lifecycle.Transitions == nil {
+		lifecycle.Transitions = map[LifecycleState]int64{}
+	}
+
+	lifecycle.State = state
+	lifecycle.Transitions[state] = time.Now().UnixNano()
+
+	registry.Lifecycles[runOnceGuid] = lifecycle
+	registry.notifyChanged()
+}
+
+// SetRunOnceAction records which of a registered RunOnce's actions it is
+// running.  RunOnces that are not registered are ignored.
+func (registry *TaskRegistry) SetRunOnceAction(runOnceGuid string, actionIndex int) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	lifecycle, registered := registry.Lifecycles[runOnceGuid]
+	if !registered {
+		return
+	}
+
+	lifecycle.ActionIndex = actionIndex
+
+	registry.Lifecycles[runOnceGuid] = lifecycle
+	registry.notifyChanged()
+}
+
+func (registry *TaskRegistry) RunOnceLifecycle(runOnceGuid string) (Lifecycle, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	lifecycle, registered := registry.Lifecycles[runOnceGuid]
+	if !registered {
+		return Lifecycle{}, false
+	}
+
+	return lifecycle.copy(), true
+}
+
+func (registry *TaskRegistry) RunOnceLifecycles() map[string]Lifecycle {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	lifecycles := make(map[string]Lifecycle, len(registry.Lifecycles))
+	for guid, lifecycle := range registry.Lifecycles {
+		lifecycles[guid] = lifecycle.copy()
+	}
+
+	return lifecycles
+}
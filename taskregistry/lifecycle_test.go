@@ -0,0 +1,112 @@
+package taskregistry_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/onsi/ginkgo/config"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/taskregistry"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunOnce lifecycles", func() {
+	var taskRegistry *TaskRegistry
+	var runOnce models.RunOnce
+	var registryFileName string
+
+	BeforeEach(func() {
+		registryFileName = fmt.Sprintf("/tmp/executor_registry_lifecycle_%d", config.GinkgoConfig.ParallelNode)
+		runOnce = models.RunOnce{
+			Guid:     "a guid",
+			MemoryMB: 64,
+			DiskMB:   64,
+		}
+
+		taskRegistry = NewTaskRegistry(registryFileName, 256, 1024)
+
+		err := taskRegistry.AddRunOnce(runOnce)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.Remove(registryFileName)
+		os.Remove(registryFileName + ".previous")
+	})
+
+	It("starts a registered RunOnce off as registered, with no action", func() {
+		lifecycle, found := taskRegistry.RunOnceLifecycle(runOnce.Guid)
+		Ω(found).Should(BeTrue())
+
+		Ω(lifecycle.State).Should(Equal(StateRegistered))
+		Ω(lifecycle.Transitions).Should(HaveKey(StateRegistered))
+		Ω(lifecycle.ActionIndex).Should(Equal(-1))
+	})
+
+	It("records each state the RunOnce moves to, and when", func() {
+		taskRegistry.SetRunOnceState(runOnce.Guid, StateClaimed)
+		taskRegistry.SetRunOnceState(runOnce.Guid, StateContainerCreated)
+
+		lifecycle, _ := taskRegistry.RunOnceLifecycle(runOnce.Guid)
+		Ω(lifecycle.State).Should(Equal(StateContainerCreated))
+		Ω(lifecycle.Transitions).Should(HaveLen(3))
+		Ω(lifecycle.Transitions[StateContainerCreated]).Should(BeNumerically(">=", lifecycle.Transitions[StateClaimed]))
+		Ω(lifecycle.Transitions[StateClaimed]).Should(BeNumerically(">=", lifecycle.Transitions[StateRegistered]))
+	})
+
+	It("records the action the RunOnce is running", func() {
+		taskRegistry.SetRunOnceAction(runOnce.Guid, 2)
+
+		lifecycle, _ := taskRegistry.RunOnceLifecycle(runOnce.Guid)
+		Ω(lifecycle.ActionIndex).Should(Equal(2))
+	})
+
+	It("records the RunOnce's container handle", func() {
+		runOnce.ContainerHandle = "some-container-handle"
+		taskRegistry.UpdateRunOnce(runOnce)
+
+		lifecycle, _ := taskRegistry.RunOnceLifecycle(runOnce.Guid)
+		Ω(lifecycle.ContainerHandle).Should(Equal("some-container-handle"))
+	})
+
+	It("ignores RunOnces that are not registered", func() {
+		taskRegistry.SetRunOnceState("another guid", StateClaimed)
+		taskRegistry.SetRunOnceAction("another guid", 1)
+
+		_, found := taskRegistry.RunOnceLifecycle("another guid")
+		Ω(found).Should(BeFalse())
+	})
+
+	It("forgets the lifecycle when the RunOnce is removed", func() {
+		taskRegistry.RemoveRunOnce(runOnce)
+
+		_, found := taskRegistry.RunOnceLifecycle(runOnce.Guid)
+		Ω(found).Should(BeFalse())
+		Ω(taskRegistry.RunOnceLifecycles()).Should(BeEmpty())
+	})
+
+	It("hands out copies that do not change with the registry", func() {
+		lifecycles := taskRegistry.RunOnceLifecycles()
+
+		taskRegistry.SetRunOnceState(runOnce.Guid, StateClaimed)
+
+		Ω(lifecycles[runOnce.Guid].State).Should(Equal(StateRegistered))
+		Ω(lifecycles[runOnce.Guid].Transitions).ShouldNot(HaveKey(StateClaimed))
+	})
+
+	It("is saved with the registry", func() {
+		taskRegistry.SetRunOnceState(runOnce.Guid, StateRunning)
+		taskRegistry.SetRunOnceAction(runOnce.Guid, 1)
+
+		err := taskRegistry.WriteToDisk()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		loadedTaskRegistry, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(loadedTaskRegistry.RunOnceLifecycles()).Should(Equal(taskRegistry.RunOnceLifecycles()))
+	})
+})
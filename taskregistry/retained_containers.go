@@ -0,0 +1,97 @@
+package taskregistry
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+)
+
+// RetainedContainer is the container of a failed RunOnce, kept around so that
+// it can be looked into.  It holds on to the RunOnce's resources, and the
+// caches mounted into it, until it is released.  Times are in nanoseconds
+// since the epoch.
+type RetainedContainer struct {
+	RunOnceGuid     string              `json:"run_once_guid"`
+	ContainerHandle string              `json:"container_handle"`
+	FailureReason   string              `json:"failure_reason"`
+	MemoryMB        int                 `json:"memory_mb"`
+	DiskMB          int                 `json:"disk_mb"`
+	CpuWeight       uint                `json:"cpu_weight"`
+	Caches          []models.CacheMount `json:"caches,omitempty"`
+	RetainedAt      int64               `json:"retained_at"`
+	ExpiresAt       int64               `json:"expires_at"`
+}
+
+// RetainContainer keeps the RunOnce's container, and the resources it
+// declared, until it is released.
+func (registry *TaskRegistry) RetainContainer(runOnce models.RunOnce, ttl time.Duration) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	now := time.Now()
+
+	registry.RetainedContainers[runOnce.Guid] = RetainedContainer{
+		RunOnceGuid:     runOnce.Guid,
+		ContainerHandle: runOnce.ContainerHandle,
+		FailureReason:   runOnce.FailureReason,
+		MemoryMB:        runOnce.MemoryMB,
+		DiskMB:          runOnce.DiskMB,
+		CpuWeight:       runOnce.CpuWeight,
+		Caches:          runOnce.Caches,
+		RetainedAt:      now.UnixNano(),
+		ExpiresAt:       now.Add(ttl).UnixNano(),
+	}
+
+	registry.notifyChanged()
+}
+
+// ReleaseRetainedContainer forgets the retained container of the RunOnce with
+// the given guid, freeing its resources.
+func (registry *TaskRegistry) ReleaseRetainedContainer(runOnceGuid string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	_, retained := registry.RetainedContainers[runOnceGuid]
+	if !retained {
+		return
+	}
+
+	delete(registry.RetainedContainers, runOnceGuid)
+
+	select {
+	case registry.capacityFreed <- struct{}{}:
+	default:
+	}
+
+	registry.notifyChanged()
+}
+
+func (registry *TaskRegistry) RetainedContainer(runOnceGuid string) (RetainedContainer, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	retainedContainer, retained := registry.RetainedContainers[runOnceGuid]
+	return retainedContainer, retained
+}
+
+// ListRetainedContainers returns the retained containers, oldest first.
+func (registry *TaskRegistry) ListRetainedContainers() []RetainedContainer {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	retainedContainers := []RetainedContainer{}
+	for _, retainedContainer := range registry.RetainedContainers {
+		retainedContainers = append(retainedContainers, retainedContainer)
+	}
+
+	sort.Sort(byRetainedAt(retainedContainers))
+
+	return retainedContainers
+}
+
+type byRetainedAt []RetainedContainer
+
+func (s byRetainedAt) Len() int           { return len(s) }
+func (s byRetainedAt) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byRetainedAt) Less(i, j int) bool { return s[i].RetainedAt < s[j].RetainedAt }
@@ -0,0 +1,90 @@
+package taskregistry_test
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/taskregistry"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retained containers", func() {
+	var taskRegistry *TaskRegistry
+	var registryFileName string
+	var runOnce models.RunOnce
+
+	BeforeEach(func() {
+		registryFileName = fmt.Sprintf("/tmp/executor_registry_retained_%d", config.GinkgoConfig.ParallelNode)
+		taskRegistry = NewTaskRegistryWithPolicy(registryFileName, Capacity{
+			MemoryMB:   256,
+			DiskMB:     1024,
+			CpuWeight:  100,
+			Containers: 2,
+		}, DefaultCapacityPolicy)
+
+		runOnce = models.RunOnce{
+			Guid:            "a guid",
+			MemoryMB:        64,
+			DiskMB:          128,
+			CpuWeight:       10,
+			ContainerHandle: "some-handle",
+			Failed:          true,
+			FailureReason:   "it broke",
+			Caches: []models.CacheMount{
+				{Key: "app-123", ContainerPath: "/tmp/cache"},
+			},
+		}
+
+		taskRegistry.RetainContainer(runOnce, time.Hour)
+	})
+
+	AfterEach(func() {
+		os.Remove(registryFileName)
+		os.Remove(registryFileName + ".previous")
+	})
+
+	It("lists the retained container with its RunOnce's guid", func() {
+		retainedContainers := taskRegistry.ListRetainedContainers()
+		Ω(retainedContainers).Should(HaveLen(1))
+
+		retainedContainer := retainedContainers[0]
+		Ω(retainedContainer.RunOnceGuid).Should(Equal("a guid"))
+		Ω(retainedContainer.ContainerHandle).Should(Equal("some-handle"))
+		Ω(retainedContainer.FailureReason).Should(Equal("it broke"))
+		Ω(retainedContainer.Caches).Should(Equal(runOnce.Caches))
+		Ω(retainedContainer.ExpiresAt - retainedContainer.RetainedAt).Should(BeNumerically("==", time.Hour))
+	})
+
+	It("keeps holding on to the RunOnce's resources", func() {
+		Ω(taskRegistry.AvailableCapacity()).Should(Equal(Capacity{
+			MemoryMB:   192,
+			DiskMB:     896,
+			CpuWeight:  90,
+			Containers: 1,
+		}))
+	})
+
+	It("frees the resources when the container is released", func() {
+		taskRegistry.ReleaseRetainedContainer("a guid")
+
+		Ω(taskRegistry.ListRetainedContainers()).Should(BeEmpty())
+		Ω(taskRegistry.AvailableCapacity()).Should(Equal(taskRegistry.TotalCapacity()))
+		Ω(taskRegistry.CapacityFreed()).Should(Receive())
+	})
+
+	It("is saved with the registry", func() {
+		err := taskRegistry.WriteToDisk()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		loadedTaskRegistry, err := LoadTaskRegistryFromDiskWithPolicy(registryFileName, taskRegistry.TotalCapacity(), DefaultCapacityPolicy)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(loadedTaskRegistry.ListRetainedContainers()).Should(Equal(taskRegistry.ListRetainedContainers()))
+	})
+})
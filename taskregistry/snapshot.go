@@ -0,0 +1,180 @@
+package taskregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+)
+
+var ErrorRegistrySnapshotVersionUnsupported = errors.New("Registry snapshot was written by a newer executor")
+
+// CurrentSnapshotVersion is the version of the snapshots this executor writes.
+// Bump it, and add a migration from the previous version, whenever the format
+// of the snapshot (including models.RunOnce) changes.
+const CurrentSnapshotVersion = 3
+
+// snapshot is what is written to disk.  Its fields keep the names they had
+// before snapshots were versioned, so that executors from before then can
+// still read them after a downgrade.
+type snapshot struct {
+	Version            int
+	ExecutorMemoryMB   int
+	ExecutorDiskMB     int
+	RunOnces           map[string]models.RunOnce
+	Processes          map[string]Process
+	Lifecycles         map[string]Lifecycle
+	RetainedContainers map[string]RetainedContainer
+}
+
+// a migration upgrades a decoded snapshot from one version to the next
+type migration func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error)
+
+// migrations[n] upgrades a snapshot of version n to version n+1
+var migrations = []migration{
+	// version 0 is the unversioned json of the TaskRegistry itself, which
+	// may predate Processes
+	func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		if _, found := fields["Processes"]; !found {
+			fields["Processes"] = json.RawMessage("{}")
+		}
+
+		return fields, nil
+	},
+
+	// version 2 adds each RunOnce's lifecycle, worked out from what version 1
+	// recorded: a RunOnce with a process was running it, and one with a
+	// container had at least created it.  when each transition happened is
+	// not known.
+	func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		var runOnces map[string]models.RunOnce
+		var processes map[string]Process
+
+		err := unmarshalField(fields, "RunOnces", &runOnces)
+		if err != nil {
+			return nil, err
+		}
+
+		err = unmarshalField(fields, "Processes", &processes)
+		if err != nil {
+			return nil, err
+		}
+
+		lifecycles := map[string]Lifecycle{}
+		for guid, runOnce := range runOnces {
+			lifecycle := Lifecycle{
+				State:           StateRegistered,
+				Transitions:     map[LifecycleState]int64{},
+				ContainerHandle: runOnce.ContainerHandle,
+				ActionIndex:     -1,
+			}
+
+			if runOnce.ContainerHandle != "" {
+				lifecycle.State = StateContainerCreated
+			}
+
+			process, hasProcess := processes[guid]
+			if hasProcess {
+				lifecycle.State = StateRunning
+				lifecycle.ActionIndex = process.ActionIndex
+			}
+
+			lifecycles[guid] = lifecycle
+		}
+
+		encoded, err := json.Marshal(lifecycles)
+		if err != nil {
+			return nil, err
+		}
+
+		fields["Lifecycles"] = json.RawMessage(encoded)
+
+		return fields, nil
+	},
+
+	// version 3 adds the containers of failed RunOnces kept for debugging
+	func(fields map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+		fields["RetainedContainers"] = json.RawMessage("{}")
+
+		return fields, nil
+	},
+}
+
+func unmarshalField(fields map[string]json.RawMessage, name string, value interface{}) error {
+	raw, found := fields[name]
+	if !found || string(raw) == "null" {
+		return nil
+	}
+
+	err := json.Unmarshal(raw, value)
+	if err != nil {
+		return ErrorRegistrySnapshotHasInvalidJSON
+	}
+
+	return nil
+}
+
+func (registry *TaskRegistry) snapshot() snapshot {
+	return snapshot{
+		Version:            CurrentSnapshotVersion,
+		ExecutorMemoryMB:   registry.ExecutorMemoryMB,
+		ExecutorDiskMB:     registry.ExecutorDiskMB,
+		RunOnces:           registry.RunOnces,
+		Processes:          registry.Processes,
+		Lifecycles:         registry.Lifecycles,
+		RetainedContainers: registry.RetainedContainers,
+	}
+}
+
+func readSnapshot(fileName string) (snapshot, error) {
+	bytes, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return snapshot{}, ErrorRegistrySnapshotDoesNotExist
+	}
+
+	return decodeSnapshot(bytes)
+}
+
+func decodeSnapshot(bytes []byte) (snapshot, error) {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(bytes, &fields)
+	if err != nil || fields == nil {
+		return snapshot{}, ErrorRegistrySnapshotHasInvalidJSON
+	}
+
+	version := 0
+	if rawVersion, found := fields["Version"]; found {
+		err := json.Unmarshal(rawVersion, &version)
+		if err != nil {
+			return snapshot{}, ErrorRegistrySnapshotHasInvalidJSON
+		}
+	}
+
+	if version > CurrentSnapshotVersion {
+		return snapshot{}, ErrorRegistrySnapshotVersionUnsupported
+	}
+
+	for ; version < CurrentSnapshotVersion; version++ {
+		fields, err = migrations[version](fields)
+		if err != nil {
+			return snapshot{}, err
+		}
+	}
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	var decoded snapshot
+	err = json.Unmarshal(migrated, &decoded)
+	if err != nil {
+		return snapshot{}, ErrorRegistrySnapshotHasInvalidJSON
+	}
+
+	decoded.Version = CurrentSnapshotVersion
+
+	return decoded, nil
+}
@@ -0,0 +1,155 @@
+package taskregistry_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/onsi/ginkgo/config"
+
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	. "github.com/cloudfoundry-incubator/executor/taskregistry"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry snapshots", func() {
+	var registryFileName string
+
+	BeforeEach(func() {
+		registryFileName = fmt.Sprintf("/tmp/executor_registry_snapshot_%d", config.GinkgoConfig.ParallelNode)
+	})
+
+	AfterEach(func() {
+		os.Remove(registryFileName)
+		os.Remove(registryFileName + ".previous")
+	})
+
+	It("records the snapshot format version", func() {
+		err := NewTaskRegistry(registryFileName, 256, 1024).WriteToDisk()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		bytes, err := ioutil.ReadFile(registryFileName)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var fields map[string]interface{}
+		err = json.Unmarshal(bytes, &fields)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(fields["Version"]).Should(BeNumerically("==", CurrentSnapshotVersion))
+	})
+
+	It("keeps the fields that unversioned executors read", func() {
+		registry := NewTaskRegistry(registryFileName, 256, 1024)
+		registry.AddRunOnce(models.RunOnce{Guid: "a guid", MemoryMB: 1, DiskMB: 1})
+
+		err := registry.WriteToDisk()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		bytes, err := ioutil.ReadFile(registryFileName)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var legacy struct {
+			RunOnces  map[string]models.RunOnce
+			Processes map[string]Process
+		}
+		err = json.Unmarshal(bytes, &legacy)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(legacy.RunOnces).Should(HaveKey("a guid"))
+	})
+
+	Context("when the snapshot was written before snapshots were versioned", func() {
+		BeforeEach(func() {
+			legacySnapshot := `{
+				"ExecutorMemoryMB": 256,
+				"ExecutorDiskMB": 1024,
+				"RunOnces": {"a guid": {"guid": "a guid", "memory_mb": 64, "disk_mb": 64}}
+			}`
+
+			err := ioutil.WriteFile(registryFileName, []byte(legacySnapshot), os.ModePerm)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("upgrades it on load", func() {
+			registry, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(registry.RunOnces).Should(HaveKey("a guid"))
+			Ω(registry.RunOnces["a guid"].MemoryMB).Should(Equal(64))
+			Ω(registry.Processes).ShouldNot(BeNil())
+			Ω(registry.Processes).Should(BeEmpty())
+		})
+
+		It("works out the RunOnces' lifecycles", func() {
+			registry, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			lifecycle, found := registry.RunOnceLifecycle("a guid")
+			Ω(found).Should(BeTrue())
+			Ω(lifecycle.State).Should(Equal(StateRegistered))
+			Ω(lifecycle.ActionIndex).Should(Equal(-1))
+		})
+	})
+
+	Context("when the snapshot was written before RunOnce lifecycles were recorded", func() {
+		BeforeEach(func() {
+			versionOneSnapshot := `{
+				"Version": 1,
+				"ExecutorMemoryMB": 256,
+				"ExecutorDiskMB": 1024,
+				"RunOnces": {
+					"registered": {"guid": "registered", "memory_mb": 1, "disk_mb": 1},
+					"in a container": {"guid": "in a container", "memory_mb": 1, "disk_mb": 1, "container_handle": "handle-1"},
+					"running": {"guid": "running", "memory_mb": 1, "disk_mb": 1, "container_handle": "handle-2"}
+				},
+				"Processes": {"running": {"ActionIndex": 3, "ProcessID": 42}}
+			}`
+
+			err := ioutil.WriteFile(registryFileName, []byte(versionOneSnapshot), os.ModePerm)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("works out the RunOnces' lifecycles from their containers and processes", func() {
+			registry, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			lifecycles := registry.RunOnceLifecycles()
+			Ω(lifecycles).Should(HaveLen(3))
+
+			Ω(lifecycles["registered"].State).Should(Equal(StateRegistered))
+			Ω(lifecycles["registered"].ActionIndex).Should(Equal(-1))
+
+			Ω(lifecycles["in a container"].State).Should(Equal(StateContainerCreated))
+			Ω(lifecycles["in a container"].ContainerHandle).Should(Equal("handle-1"))
+
+			Ω(lifecycles["running"].State).Should(Equal(StateRunning))
+			Ω(lifecycles["running"].ContainerHandle).Should(Equal("handle-2"))
+			Ω(lifecycles["running"].ActionIndex).Should(Equal(3))
+		})
+	})
+
+	Context("when the snapshot was written by a newer executor", func() {
+		BeforeEach(func() {
+			futureSnapshot := fmt.Sprintf(`{"Version": %d, "RunOnces": {}}`, CurrentSnapshotVersion+1)
+
+			err := ioutil.WriteFile(registryFileName, []byte(futureSnapshot), os.ModePerm)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("refuses to load it", func() {
+			_, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			Ω(err).Should(Equal(ErrorRegistrySnapshotVersionUnsupported))
+		})
+
+		It("does not fall back to the previous snapshot", func() {
+			err := ioutil.WriteFile(registryFileName+".previous", []byte(`{"RunOnces": {}}`), os.ModePerm)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			Ω(err).Should(Equal(ErrorRegistrySnapshotVersionUnsupported))
+		})
+	})
+})
@@ -4,44 +4,97 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"sync"
+	"time"
 
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
+
+	"github.com/cloudfoundry-incubator/executor/atomicfile"
 )
 
 var ErrorRegistrySnapshotDoesNotExist = errors.New("Registry snapshot does not exist")
 var ErrorRegistrySnapshotHasInvalidJSON = errors.New("Registry snapshot has invalid JSON")
 var ErrorNotEnoughMemoryWhenLoadingSnapshot = errors.New("Insufficient memory when loading snapshot")
 var ErrorNotEnoughDiskWhenLoadingSnapshot = errors.New("Insufficient disk when loading snapshot")
+var ErrorNotEnoughCpuWeightWhenLoadingSnapshot = errors.New("Insufficient cpu weight when loading snapshot")
+var ErrorTooManyContainersWhenLoadingSnapshot = errors.New("Too many containers when loading snapshot")
 
 type TaskRegistryInterface interface {
 	AddRunOnce(runOnce models.RunOnce) error
+	UpdateRunOnce(runOnce models.RunOnce)
 	RemoveRunOnce(runOnce models.RunOnce)
+	RecordProcess(runOnceGuid string, process Process)
+	SetRunOnceState(runOnceGuid string, state LifecycleState)
+	SetRunOnceAction(runOnceGuid string, actionIndex int)
+	RetainContainer(runOnce models.RunOnce, ttl time.Duration)
 	WriteToDisk() error
 }
 
+// Process identifies the warden process a RunOnce is running, so that its
+// output can be picked up again after the executor restarts.
+type Process struct {
+	ActionIndex int
+	ProcessID   uint32
+}
+
+// Capacity is an amount of each resource the registry accounts for.  A total
+// CpuWeight or Containers of 0 means that resource is not limited, and none of
+// it is reported as available.
+type Capacity struct {
+	MemoryMB   int `json:"memory_mb"`
+	DiskMB     int `json:"disk_mb"`
+	CpuWeight  int `json:"cpu_weight"`
+	Containers int `json:"containers"`
+}
+
 type TaskRegistry struct {
-	ExecutorMemoryMB int
-	ExecutorDiskMB   int
-	RunOnces         map[string]models.RunOnce
-	lock             *sync.Mutex
-	fileName         string
+	ExecutorMemoryMB      int
+	ExecutorDiskMB        int
+	ExecutorCpuWeight     int
+	ExecutorMaxContainers int
+	RunOnces              map[string]models.RunOnce
+	Processes             map[string]Process
+	Lifecycles            map[string]Lifecycle
+	RetainedContainers    map[string]RetainedContainer
+	lock                  *sync.Mutex
+	fileName              string
+	capacityFreed         chan struct{}
+	changed               chan struct{}
 }
 
 func NewTaskRegistry(fileName string, memoryMB int, diskMB int) *TaskRegistry {
+	return NewTaskRegistryWithPolicy(fileName, Capacity{MemoryMB: memoryMB, DiskMB: diskMB}, DefaultCapacityPolicy)
+}
+
+// NewTaskRegistryWithPolicy returns a registry that hands out the capacity the
+// policy leaves out of the executor's total capacity.
+func NewTaskRegistryWithPolicy(fileName string, total Capacity, policy CapacityPolicy) *TaskRegistry {
+	capacity := policy.Apply(total)
+
 	return &TaskRegistry{
-		ExecutorMemoryMB: memoryMB,
-		ExecutorDiskMB:   diskMB,
-		RunOnces:         make(map[string]models.RunOnce),
-		lock:             &sync.Mutex{},
-		fileName:         fileName,
+		ExecutorMemoryMB:      capacity.MemoryMB,
+		ExecutorDiskMB:        capacity.DiskMB,
+		ExecutorCpuWeight:     capacity.CpuWeight,
+		ExecutorMaxContainers: capacity.Containers,
+		RunOnces:              make(map[string]models.RunOnce),
+		Processes:             make(map[string]Process),
+		Lifecycles:            make(map[string]Lifecycle),
+		RetainedContainers:    make(map[string]RetainedContainer),
+		lock:                  &sync.Mutex{},
+		fileName:              fileName,
+		capacityFreed:         make(chan struct{}, 1),
+		changed:               make(chan struct{}, 1),
 	}
 }
 
 func LoadTaskRegistryFromDisk(filename string, memoryMB int, diskMB int) (*TaskRegistry, error) {
-	taskRegistry := NewTaskRegistry(filename, memoryMB, diskMB)
+	return LoadTaskRegistryFromDiskWithPolicy(filename, Capacity{MemoryMB: memoryMB, DiskMB: diskMB}, DefaultCapacityPolicy)
+}
+
+func LoadTaskRegistryFromDiskWithPolicy(filename string, total Capacity, policy CapacityPolicy) (*TaskRegistry, error) {
+	taskRegistry := NewTaskRegistryWithPolicy(filename, total, policy)
 	err := taskRegistry.hydrateFromDisk()
 	if err != nil {
 		return nil, err
@@ -54,45 +107,275 @@ func (registry *TaskRegistry) AddRunOnce(runOnce models.RunOnce) error {
 	defer registry.lock.Unlock()
 
 	if !registry.hasCapacityForRunOnce(runOnce) {
-		return fmt.Errorf("insufficient resources to claim run once: Desired %d (memory) %d (disk).  Have %d (memory) %d (disk).", runOnce.MemoryMB, runOnce.DiskMB, registry.availableMemoryMB(), registry.availableDiskMB())
+		available := registry.availableCapacity()
+		return fmt.Errorf("insufficient resources to claim run once: Desired %d (memory) %d (disk) %d (cpu weight).  Have %d (memory) %d (disk) %d (cpu weight) %d (containers).", runOnce.MemoryMB, runOnce.DiskMB, runOnce.CpuWeight, available.MemoryMB, available.DiskMB, available.CpuWeight, available.Containers)
 	}
 	registry.RunOnces[runOnce.Guid] = runOnce
+	registry.Lifecycles[runOnce.Guid] = newLifecycle(runOnce.ContainerHandle)
+	registry.notifyChanged()
 	return nil
 }
 
+// UpdateRunOnce replaces a registered RunOnce, e.g. once it has been given a
+// container.  RunOnces that are not registered are ignored.
+func (registry *TaskRegistry) UpdateRunOnce(runOnce models.RunOnce) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	_, registered := registry.RunOnces[runOnce.Guid]
+	if registered {
+		registry.RunOnces[runOnce.Guid] = runOnce
+
+		lifecycle := registry.Lifecycles[runOnce.Guid]
+		lifecycle.ContainerHandle = runOnce.ContainerHandle
+		registry.Lifecycles[runOnce.Guid] = lifecycle
+
+		registry.notifyChanged()
+	}
+}
+
 func (registry *TaskRegistry) RemoveRunOnce(runOnce models.RunOnce) {
 	registry.lock.Lock()
 	defer registry.lock.Unlock()
 
+	_, registered := registry.RunOnces[runOnce.Guid]
+
 	delete(registry.RunOnces, runOnce.Guid)
+	delete(registry.Processes, runOnce.Guid)
+	delete(registry.Lifecycles, runOnce.Guid)
+
+	if registered {
+		select {
+		case registry.capacityFreed <- struct{}{}:
+		default:
+		}
+
+		registry.notifyChanged()
+	}
+}
+
+// CapacityFreed receives after a registered RunOnce is removed.  Removals that
+// happen before the last one is received are collapsed into one.
+func (registry *TaskRegistry) CapacityFreed() <-chan struct{} {
+	return registry.capacityFreed
+}
+
+func (registry *TaskRegistry) RecordProcess(runOnceGuid string, process Process) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.Processes[runOnceGuid] = process
+	registry.notifyChanged()
+}
+
+func (registry *TaskRegistry) Process(runOnceGuid string) (Process, bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	process, found := registry.Processes[runOnceGuid]
+	return process, found
+}
+
+func (registry *TaskRegistry) TotalCapacity() Capacity {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return Capacity{
+		MemoryMB:   registry.ExecutorMemoryMB,
+		DiskMB:     registry.ExecutorDiskMB,
+		CpuWeight:  registry.ExecutorCpuWeight,
+		Containers: registry.ExecutorMaxContainers,
+	}
+}
+
+func (registry *TaskRegistry) AvailableCapacity() Capacity {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return registry.availableCapacity()
+}
+
+// HasCapacityFor reports whether the RunOnce would fit in the capacity that
+// is currently available, once the pending RunOnces (e.g. those waiting to be
+// registered) have taken theirs.  Pending RunOnces that are already registered
+// are only counted once.  It does not reserve anything.
+func (registry *TaskRegistry) HasCapacityFor(runOnce models.RunOnce, pending ...models.RunOnce) bool {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	available := registry.availableCapacity()
+	for _, pendingRunOnce := range pending {
+		_, registered := registry.RunOnces[pendingRunOnce.Guid]
+		if registered {
+			continue
+		}
+
+		available.MemoryMB -= pendingRunOnce.MemoryMB
+		available.DiskMB -= pendingRunOnce.DiskMB
+		available.CpuWeight -= int(pendingRunOnce.CpuWeight)
+		available.Containers--
+	}
+
+	if runOnce.MemoryMB > available.MemoryMB || runOnce.DiskMB > available.DiskMB {
+		return false
+	}
+
+	if registry.ExecutorCpuWeight > 0 && int(runOnce.CpuWeight) > available.CpuWeight {
+		return false
+	}
+
+	if registry.ExecutorMaxContainers > 0 && available.Containers < 1 {
+		return false
+	}
+
+	return true
+}
+
+func (registry *TaskRegistry) RegisteredRunOnces() []models.RunOnce {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	runOnces := []models.RunOnce{}
+	for _, runOnce := range registry.RunOnces {
+		runOnces = append(runOnces, runOnce)
+	}
+
+	return runOnces
+}
+
+// ContainerHandles returns the handles of the containers the registry knows
+// about: those of registered RunOnces, and those retained for debugging.
+func (registry *TaskRegistry) ContainerHandles() []string {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	seen := make(map[string]bool)
+	handles := []string{}
+
+	add := func(handle string) {
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+
+	for _, runOnce := range registry.RunOnces {
+		add(runOnce.ContainerHandle)
+	}
+
+	for _, retainedContainer := range registry.RetainedContainers {
+		add(retainedContainer.ContainerHandle)
+	}
+
+	return handles
 }
 
+// WriteToDisk replaces the snapshot atomically: it is written to a temporary
+// file which is synced and renamed over the old one, so a crash mid-write never
+// leaves a partial snapshot.  The old snapshot is kept as the previous one.
 func (registry *TaskRegistry) WriteToDisk() error {
 	registry.lock.Lock()
 	defer registry.lock.Unlock()
 
-	data, err := json.Marshal(registry)
+	data, err := json.Marshal(registry.snapshot())
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(registry.fileName, data, os.ModePerm)
+
+	return writeSnapshot(registry.fileName, data)
+}
+
+// StartSnapshotting writes the registry to disk whenever it changes.  With an
+// interval of 0 every change is written straight away; otherwise changes are
+// written at most once per interval.
+func (registry *TaskRegistry) StartSnapshotting(interval time.Duration, logger *steno.Logger) chan<- bool {
+	stop := make(chan bool, 1)
+
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			tick = ticker.C
+		}
+
+		dirty := false
+
+		for {
+			select {
+			case <-registry.changed:
+				if interval > 0 {
+					dirty = true
+					continue
+				}
+
+			case <-tick:
+				if !dirty {
+					continue
+				}
+
+			case <-stop:
+				return
+			}
+
+			dirty = false
+
+			err := registry.WriteToDisk()
+			if err != nil {
+				logger.Errord(map[string]interface{}{
+					"error":            err.Error(),
+					"snapshotLocation": registry.fileName,
+				}, "executor.snapshot.write-failed")
+			}
+		}
+	}()
+
+	return stop
+}
+
+func (registry *TaskRegistry) notifyChanged() {
+	select {
+	case registry.changed <- struct{}{}:
+	default:
+	}
 }
 
+// hydrateFromDisk loads the snapshot, falling back to the previous one if the
+// snapshot is missing or corrupt.
 func (registry *TaskRegistry) hydrateFromDisk() error {
 	registry.lock.Lock()
 	defer registry.lock.Unlock()
 
-	var loadedTaskRegistry *TaskRegistry
-	bytes, err := ioutil.ReadFile(registry.fileName)
-	if err != nil {
-		return ErrorRegistrySnapshotDoesNotExist
+	loadedSnapshot, err := readSnapshot(registry.fileName)
+	if err == ErrorRegistrySnapshotDoesNotExist || err == ErrorRegistrySnapshotHasInvalidJSON {
+		var previousErr error
+
+		loadedSnapshot, previousErr = readSnapshot(previousSnapshotFileName(registry.fileName))
+		if previousErr == nil {
+			err = nil
+		}
 	}
-	err = json.Unmarshal(bytes, &loadedTaskRegistry)
+
 	if err != nil {
-		return ErrorRegistrySnapshotHasInvalidJSON
+		return err
 	}
 
-	registry.RunOnces = loadedTaskRegistry.RunOnces
+	if loadedSnapshot.RunOnces != nil {
+		registry.RunOnces = loadedSnapshot.RunOnces
+	}
+
+	if loadedSnapshot.Processes != nil {
+		registry.Processes = loadedSnapshot.Processes
+	}
+
+	if loadedSnapshot.Lifecycles != nil {
+		registry.Lifecycles = loadedSnapshot.Lifecycles
+	}
+
+	if loadedSnapshot.RetainedContainers != nil {
+		registry.RetainedContainers = loadedSnapshot.RetainedContainers
+	}
 
 	if registry.availableMemoryMB() < 0 {
 		return ErrorNotEnoughMemoryWhenLoadingSnapshot
@@ -102,9 +385,37 @@ func (registry *TaskRegistry) hydrateFromDisk() error {
 		return ErrorNotEnoughDiskWhenLoadingSnapshot
 	}
 
+	if registry.ExecutorCpuWeight > 0 && registry.availableCpuWeight() < 0 {
+		return ErrorNotEnoughCpuWeightWhenLoadingSnapshot
+	}
+
+	if registry.ExecutorMaxContainers > 0 && registry.availableContainers() < 0 {
+		return ErrorTooManyContainersWhenLoadingSnapshot
+	}
+
 	return nil
 }
 
+// writeSnapshot replaces the snapshot with data, keeping the last good one in
+// case this one is lost
+func writeSnapshot(fileName string, data []byte) error {
+	info, err := os.Stat(fileName)
+	if err == nil && !info.Mode().IsRegular() {
+		return fmt.Errorf("registry snapshot %s is not a regular file", fileName)
+	}
+
+	err = os.Rename(fileName, previousSnapshotFileName(fileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return atomicfile.WriteFile(fileName, data)
+}
+
+func previousSnapshotFileName(fileName string) string {
+	return fileName + ".previous"
+}
+
 func (registry *TaskRegistry) hasCapacityForRunOnce(runOnce models.RunOnce) bool {
 	if runOnce.MemoryMB > registry.availableMemoryMB() {
 		return false
@@ -114,14 +425,42 @@ func (registry *TaskRegistry) hasCapacityForRunOnce(runOnce models.RunOnce) bool
 		return false
 	}
 
+	if registry.ExecutorCpuWeight > 0 && int(runOnce.CpuWeight) > registry.availableCpuWeight() {
+		return false
+	}
+
+	if registry.ExecutorMaxContainers > 0 && registry.availableContainers() < 1 {
+		return false
+	}
+
 	return true
 }
 
+func (registry *TaskRegistry) availableCapacity() Capacity {
+	available := Capacity{
+		MemoryMB: registry.availableMemoryMB(),
+		DiskMB:   registry.availableDiskMB(),
+	}
+
+	if registry.ExecutorCpuWeight > 0 {
+		available.CpuWeight = registry.availableCpuWeight()
+	}
+
+	if registry.ExecutorMaxContainers > 0 {
+		available.Containers = registry.availableContainers()
+	}
+
+	return available
+}
+
 func (registry *TaskRegistry) availableMemoryMB() int {
 	usedMemory := 0
 	for _, r := range registry.RunOnces {
 		usedMemory = usedMemory + r.MemoryMB
 	}
+	for _, c := range registry.RetainedContainers {
+		usedMemory = usedMemory + c.MemoryMB
+	}
 	return registry.ExecutorMemoryMB - usedMemory
 }
 
@@ -130,5 +469,23 @@ func (registry *TaskRegistry) availableDiskMB() int {
 	for _, r := range registry.RunOnces {
 		usedDisk = usedDisk + r.DiskMB
 	}
+	for _, c := range registry.RetainedContainers {
+		usedDisk = usedDisk + c.DiskMB
+	}
 	return registry.ExecutorDiskMB - usedDisk
 }
+
+func (registry *TaskRegistry) availableCpuWeight() int {
+	usedCpuWeight := 0
+	for _, r := range registry.RunOnces {
+		usedCpuWeight = usedCpuWeight + int(r.CpuWeight)
+	}
+	for _, c := range registry.RetainedContainers {
+		usedCpuWeight = usedCpuWeight + int(c.CpuWeight)
+	}
+	return registry.ExecutorCpuWeight - usedCpuWeight
+}
+
+func (registry *TaskRegistry) availableContainers() int {
+	return registry.ExecutorMaxContainers - len(registry.RunOnces) - len(registry.RetainedContainers)
+}
@@ -1,13 +1,16 @@
 package taskregistry_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/onsi/ginkgo/config"
 	"io/ioutil"
 	"os"
+	"time"
 
+	"github.com/cloudfoundry-incubator/executor/forks/runtime-schema/models"
 	. "github.com/cloudfoundry-incubator/executor/taskregistry"
-	"github.com/cloudfoundry-incubator/runtime-schema/models"
+	steno "github.com/cloudfoundry/gosteno"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,6 +33,7 @@ var _ = Describe("TaskRegistry", func() {
 
 	AfterEach(func() {
 		os.Remove(registryFileName)
+		os.Remove(registryFileName + ".previous")
 	})
 
 	Describe("AddRunOnce", func() {
@@ -62,6 +66,47 @@ var _ = Describe("TaskRegistry", func() {
 				Ω(taskRegistry.RunOnces).To(HaveLen(1))
 			})
 		})
+
+		Context("when cpu weight and containers are limited", func() {
+			BeforeEach(func() {
+				taskRegistry = NewTaskRegistryWithPolicy(registryFileName, Capacity{
+					MemoryMB:   256,
+					DiskMB:     1024,
+					CpuWeight:  100,
+					Containers: 2,
+				}, DefaultCapacityPolicy)
+
+				err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "first", CpuWeight: 60})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("Returns an error and adds nothing when the new RunOnce needs more cpu weight than is available", func() {
+				err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "second", CpuWeight: 41})
+				Ω(err).Should(HaveOccurred())
+				Ω(taskRegistry.RunOnces).To(HaveLen(1))
+			})
+
+			It("Returns an error and adds nothing when the executor has as many containers as it may", func() {
+				err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "second", CpuWeight: 40})
+				Ω(err).ShouldNot(HaveOccurred())
+
+				err = taskRegistry.AddRunOnce(models.RunOnce{Guid: "third"})
+				Ω(err).Should(HaveOccurred())
+				Ω(taskRegistry.RunOnces).To(HaveLen(2))
+			})
+
+			It("reports the cpu weight and containers left", func() {
+				Ω(taskRegistry.TotalCapacity()).To(Equal(Capacity{MemoryMB: 256, DiskMB: 1024, CpuWeight: 100, Containers: 2}))
+				Ω(taskRegistry.AvailableCapacity()).To(Equal(Capacity{MemoryMB: 256, DiskMB: 1024, CpuWeight: 40, Containers: 1}))
+			})
+		})
+
+		Context("when cpu weight and containers are not limited", func() {
+			It("accepts RunOnces whatever cpu weight they declare", func() {
+				err := taskRegistry.AddRunOnce(models.RunOnce{Guid: "heavy", CpuWeight: 10000})
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
 	})
 
 	Describe("RemoveRunOnce", func() {
@@ -76,6 +121,137 @@ var _ = Describe("TaskRegistry", func() {
 			err := taskRegistry.AddRunOnce(runOnce)
 			Ω(err).ShouldNot(HaveOccurred())
 		})
+
+		It("should notify that capacity has been freed", func() {
+			taskRegistry.RemoveRunOnce(runOnce)
+
+			Ω(taskRegistry.CapacityFreed()).Should(Receive())
+		})
+
+		It("should not notify when the RunOnce was not registered", func() {
+			taskRegistry.RemoveRunOnce(models.RunOnce{Guid: "not-registered"})
+
+			Ω(taskRegistry.CapacityFreed()).ShouldNot(Receive())
+		})
+	})
+
+	Describe("UpdateRunOnce", func() {
+		It("replaces a registered RunOnce", func() {
+			err := taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			runOnce.ContainerHandle = "some-container-handle"
+			taskRegistry.UpdateRunOnce(runOnce)
+
+			Ω(taskRegistry.RunOnces[runOnce.Guid]).To(Equal(runOnce))
+		})
+
+		It("does not register a RunOnce that was not registered", func() {
+			taskRegistry.UpdateRunOnce(runOnce)
+			Ω(taskRegistry.RunOnces).To(BeEmpty())
+		})
+	})
+
+	Describe("RecordProcess", func() {
+		BeforeEach(func() {
+			err := taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			taskRegistry.RecordProcess(runOnce.Guid, Process{ActionIndex: 2, ProcessID: 42})
+		})
+
+		It("records the RunOnce's process", func() {
+			Ω(taskRegistry.Processes[runOnce.Guid]).To(Equal(Process{ActionIndex: 2, ProcessID: 42}))
+		})
+
+		It("looks up the RunOnce's process", func() {
+			process, found := taskRegistry.Process(runOnce.Guid)
+			Ω(found).Should(BeTrue())
+			Ω(process).To(Equal(Process{ActionIndex: 2, ProcessID: 42}))
+
+			_, found = taskRegistry.Process("some other guid")
+			Ω(found).Should(BeFalse())
+		})
+
+		It("forgets the process when the RunOnce is removed", func() {
+			taskRegistry.RemoveRunOnce(runOnce)
+			Ω(taskRegistry.Processes).To(BeEmpty())
+		})
+	})
+
+	Describe("Capacity", func() {
+		BeforeEach(func() {
+			err := taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("reports the executor's total capacity", func() {
+			Ω(taskRegistry.TotalCapacity()).To(Equal(Capacity{MemoryMB: 256, DiskMB: 1024}))
+		})
+
+		It("reports the capacity not used by registered RunOnces", func() {
+			Ω(taskRegistry.AvailableCapacity()).To(Equal(Capacity{MemoryMB: 1, DiskMB: 1}))
+		})
+
+		It("reports whether a RunOnce would fit in the available capacity", func() {
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{MemoryMB: 1, DiskMB: 1})).To(BeTrue())
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{MemoryMB: 2, DiskMB: 1})).To(BeFalse())
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{MemoryMB: 1, DiskMB: 2})).To(BeFalse())
+		})
+
+		It("counts the capacity that pending RunOnces will take", func() {
+			pending := models.RunOnce{MemoryMB: 1, DiskMB: 0}
+
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{DiskMB: 1}, pending)).To(BeTrue())
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{MemoryMB: 1}, pending)).To(BeFalse())
+		})
+
+		It("does not count pending RunOnces that are already registered twice", func() {
+			pending := models.RunOnce{Guid: "pending-guid", MemoryMB: 1, DiskMB: 0}
+
+			err := taskRegistry.AddRunOnce(pending)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{DiskMB: 1}, pending)).To(BeTrue())
+			Ω(taskRegistry.HasCapacityFor(models.RunOnce{MemoryMB: 1}, pending)).To(BeFalse())
+		})
+	})
+
+	Describe("RegisteredRunOnces", func() {
+		It("returns the registered RunOnces", func() {
+			err := taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(taskRegistry.RegisteredRunOnces()).To(Equal([]models.RunOnce{runOnce}))
+		})
+	})
+
+	Describe("ContainerHandles", func() {
+		It("returns the handles of registered RunOnces' containers", func() {
+			err := taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(taskRegistry.ContainerHandles()).Should(BeEmpty())
+
+			runOnce.ContainerHandle = "running-handle"
+			taskRegistry.UpdateRunOnce(runOnce)
+
+			Ω(taskRegistry.ContainerHandles()).Should(Equal([]string{"running-handle"}))
+		})
+
+		It("returns the handles of retained containers", func() {
+			runOnce.ContainerHandle = "retained-handle"
+			runOnce.Failed = true
+
+			err := taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			taskRegistry.RetainContainer(runOnce, time.Hour)
+			Ω(taskRegistry.ContainerHandles()).Should(Equal([]string{"retained-handle"}))
+
+			taskRegistry.RemoveRunOnce(runOnce)
+			Ω(taskRegistry.ContainerHandles()).Should(Equal([]string{"retained-handle"}))
+		})
 	})
 
 	Describe("WriteToDisk", func() {
@@ -83,6 +259,97 @@ var _ = Describe("TaskRegistry", func() {
 			taskRegistry = NewTaskRegistry("/tmp", 256, 1024)
 			Ω(taskRegistry.WriteToDisk()).To(HaveOccurred())
 		})
+
+		It("keeps the previous snapshot", func() {
+			err := taskRegistry.WriteToDisk()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = taskRegistry.AddRunOnce(runOnce)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			err = taskRegistry.WriteToDisk()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			previousRegistry, err := LoadTaskRegistryFromDisk(registryFileName+".previous", 256, 1024)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(previousRegistry.RunOnces).Should(BeEmpty())
+
+			loadedRegistry, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loadedRegistry.RunOnces).Should(HaveLen(1))
+		})
+
+		It("leaves no temporary file behind", func() {
+			err := taskRegistry.WriteToDisk()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = os.Stat(registryFileName + ".tmp")
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		It("writes a snapshot only the executor's user can read", func() {
+			err := taskRegistry.WriteToDisk()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			info, err := os.Stat(registryFileName)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(info.Mode().Perm()).Should(Equal(os.FileMode(0600)))
+		})
+	})
+
+	Describe("StartSnapshotting", func() {
+		var stop chan<- bool
+
+		AfterEach(func() {
+			stop <- true
+		})
+
+		loadedRunOnces := func() map[string]models.RunOnce {
+			loadedRegistry, err := LoadTaskRegistryFromDisk(registryFileName, 256, 1024)
+			if err != nil {
+				return nil
+			}
+
+			return loadedRegistry.RunOnces
+		}
+
+		Context("with no interval", func() {
+			BeforeEach(func() {
+				stop = taskRegistry.StartSnapshotting(0, steno.NewLogger("test-logger"))
+			})
+
+			It("writes the registry when a RunOnce is added", func() {
+				err := taskRegistry.AddRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(loadedRunOnces).Should(HaveLen(1))
+			})
+
+			It("writes the registry when a RunOnce is removed", func() {
+				err := taskRegistry.AddRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Eventually(loadedRunOnces).Should(HaveLen(1))
+
+				taskRegistry.RemoveRunOnce(runOnce)
+
+				Eventually(loadedRunOnces).Should(BeEmpty())
+			})
+		})
+
+		Context("with an interval", func() {
+			BeforeEach(func() {
+				stop = taskRegistry.StartSnapshotting(200*time.Millisecond, steno.NewLogger("test-logger"))
+			})
+
+			It("writes the changed registry once the interval has passed", func() {
+				err := taskRegistry.AddRunOnce(runOnce)
+				Ω(err).ShouldNot(HaveOccurred())
+
+				Ω(loadedRunOnces()).Should(BeNil())
+				Eventually(loadedRunOnces).Should(HaveLen(1))
+			})
+		})
 	})
 
 	Describe("LoadTaskRegistryFromDisk", func() {
@@ -98,6 +365,7 @@ var _ = Describe("TaskRegistry", func() {
 					DiskMB:   1024,
 				}
 				diskRegistry.AddRunOnce(runOnce)
+				diskRegistry.RecordProcess(runOnce.Guid, Process{ActionIndex: 1, ProcessID: 7})
 				err := diskRegistry.WriteToDisk()
 				Ω(err).ShouldNot(HaveOccurred())
 			})
@@ -108,6 +376,7 @@ var _ = Describe("TaskRegistry", func() {
 
 				Ω(loadedTaskRegistry.RunOnces).To(HaveLen(1))
 				Ω(loadedTaskRegistry.RunOnces["a guid"]).To(Equal(runOnce))
+				Ω(loadedTaskRegistry.Processes["a guid"]).To(Equal(Process{ActionIndex: 1, ProcessID: 7}))
 
 				err = loadedTaskRegistry.AddRunOnce(models.RunOnce{Guid: "another guid", MemoryMB: 1, DiskMB: 1})
 				Ω(err).ShouldNot(HaveOccurred())
@@ -136,6 +405,28 @@ var _ = Describe("TaskRegistry", func() {
 						Ω(err).Should(Equal(ErrorNotEnoughDiskWhenLoadingSnapshot))
 					})
 				})
+
+				Context("when there are more registered tasks than containers allowed", func() {
+					It("should log and return an error", func() {
+						diskRegistry.AddRunOnce(models.RunOnce{Guid: "another guid"})
+						err := diskRegistry.WriteToDisk()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						_, err = LoadTaskRegistryFromDiskWithPolicy(registryFileName, Capacity{MemoryMB: 512, DiskMB: 2048, Containers: 1}, DefaultCapacityPolicy)
+						Ω(err).Should(Equal(ErrorTooManyContainersWhenLoadingSnapshot))
+					})
+				})
+
+				Context("when there is insufficient cpu weight for the registered tasks", func() {
+					It("should log and return an error", func() {
+						diskRegistry.AddRunOnce(models.RunOnce{Guid: "another guid", CpuWeight: 10})
+						err := diskRegistry.WriteToDisk()
+						Ω(err).ShouldNot(HaveOccurred())
+
+						_, err = LoadTaskRegistryFromDiskWithPolicy(registryFileName, Capacity{MemoryMB: 512, DiskMB: 2048, CpuWeight: 9}, DefaultCapacityPolicy)
+						Ω(err).Should(Equal(ErrorNotEnoughCpuWeightWhenLoadingSnapshot))
+					})
+				})
 			})
 		})
 
@@ -148,6 +439,31 @@ var _ = Describe("TaskRegistry", func() {
 				_, err := LoadTaskRegistryFromDisk(registryFileName, 4096, 4096)
 				Ω(err).Should(Equal(ErrorRegistrySnapshotHasInvalidJSON))
 			})
+
+			Context("and there is a previous snapshot", func() {
+				BeforeEach(func() {
+					diskRegistry = NewTaskRegistry(registryFileName+".previous", 512, 2048)
+					runOnce = models.RunOnce{
+						Guid:     "a guid",
+						MemoryMB: 256,
+						DiskMB:   1024,
+					}
+					diskRegistry.AddRunOnce(runOnce)
+
+					data, err := json.Marshal(diskRegistry)
+					Ω(err).ShouldNot(HaveOccurred())
+
+					err = ioutil.WriteFile(registryFileName+".previous", data, os.ModePerm)
+					Ω(err).ShouldNot(HaveOccurred())
+				})
+
+				It("should load the previous snapshot instead", func() {
+					loadedTaskRegistry, err := LoadTaskRegistryFromDisk(registryFileName, 512, 2048)
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(loadedTaskRegistry.RunOnces).Should(HaveLen(1))
+					Ω(loadedTaskRegistry.RunOnces["a guid"]).Should(Equal(runOnce))
+				})
+			})
 		})
 
 		Context("When there is not a task registry on disk", func() {